@@ -0,0 +1,517 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// accountPollInterval 账户推送不可用时轮询订单状态的间隔
+const accountPollInterval = 500 * time.Millisecond
+
+// accountStreamPollInterval 账户推送正常时兜底查询订单状态的间隔，防止推送丢失导致一直等待
+const accountStreamPollInterval = 5 * time.Second
+
+// accountOrderRetention 订单状态在缓存中保留的时间
+const accountOrderRetention = 10 * time.Minute
+
+// ErrInsufficientBalance 可用余额（扣除其他进行中交易占用的部分）不足
+var ErrInsufficientBalance = errors.New("可用余额不足")
+
+// AccountManager 账户管理器
+// 通过用户数据推送维护余额和订单状态缓存，推送断开或交易所不支持推送时回退到REST查询。
+// 进行中的交易按交易ID占用起始资产，占用的部分不再计入可用余额，避免多个机器人同时使用同一笔资金
+type AccountManager struct {
+	client       exchange.Exchange
+	balances     map[string]*exchange.Balance     // 资产余额缓存
+	reservations map[string]*BalanceReservation   // 交易ID -> 占用的余额
+	orders       map[string]*cachedOrder          // 订单状态缓存，键为 交易对/订单ID
+	fills        map[string][]*exchange.Execution // 推送的成交明细，键同 orders
+	waiters      map[string][]chan struct{}       // 等待订单状态变化的通知通道
+	mu           sync.RWMutex
+	stream       exchange.Stream // 账户推送连接
+	streamActive bool            // 账户推送是否正常
+	pushBalances bool            // 推送是否包含余额变化，为 false 时余额始终通过REST查询
+	stopChan     chan struct{}
+}
+
+// BalanceReservation 进行中的交易占用的余额
+type BalanceReservation struct {
+	TradeID   string    `json:"trade_id"`
+	Asset     string    `json:"asset"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AssetBalance 资产余额及占用情况
+type AssetBalance struct {
+	Asset     string  `json:"asset"`
+	Free      float64 `json:"free"`
+	Locked    float64 `json:"locked"`   // 交易所挂单冻结
+	Reserved  float64 `json:"reserved"` // 进行中的交易占用
+	Available float64 `json:"available"`
+}
+
+// cachedOrder 缓存的订单状态
+type cachedOrder struct {
+	order      *exchange.Order
+	receivedAt time.Time
+}
+
+// NewAccountManager 创建账户管理器
+func NewAccountManager(client exchange.Exchange) *AccountManager {
+	return &AccountManager{
+		client:       client,
+		balances:     make(map[string]*exchange.Balance),
+		reservations: make(map[string]*BalanceReservation),
+		orders:       make(map[string]*cachedOrder),
+		fills:        make(map[string][]*exchange.Execution),
+		waiters:      make(map[string][]chan struct{}),
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start 启动账户管理器，先通过REST获取余额，再订阅账户推送
+func (a *AccountManager) Start() error {
+	if err := a.refreshBalances(); err != nil {
+		return fmt.Errorf("获取账户余额失败: %w", err)
+	}
+	a.startStream()
+
+	go a.cleanupLoop()
+
+	log.Println("✓ 账户管理器已启动")
+	return nil
+}
+
+// Stop 停止账户管理器
+func (a *AccountManager) Stop() {
+	if a.stream != nil {
+		a.stream.Stop()
+	}
+	close(a.stopChan)
+	log.Println("✓ 账户管理器已停止")
+}
+
+// startStream 订阅账户推送，优先使用同时推送订单和余额的用户数据流
+func (a *AccountManager) startStream() {
+	var stream exchange.Stream
+	var err error
+
+	switch client := a.client.(type) {
+	case exchange.UserDataSubscriber:
+		stream, err = client.SubscribeUserData(exchange.UserDataHandler{
+			OnOrder:     a.applyOrder,
+			OnExecution: a.applyExecution,
+			OnBalances:  a.applyBalances,
+		})
+		a.pushBalances = err == nil
+	case exchange.OrderSubscriber:
+		stream, err = client.SubscribeOrders(a.applyOrder)
+	default:
+		log.Println("交易所不支持账户推送，使用REST轮询")
+		return
+	}
+	if err != nil {
+		log.Printf("订阅账户推送失败，使用REST轮询: %v", err)
+		return
+	}
+
+	stream.SetStateHandler(func(connected bool) {
+		a.mu.Lock()
+		a.streamActive = connected
+		a.mu.Unlock()
+
+		if !connected {
+			log.Println("账户推送已断开，回退到REST轮询")
+			return
+		}
+		log.Println("✓ 账户推送已连接")
+
+		// 断开期间的余额变化不会补发，连接后重新获取
+		if a.pushBalances {
+			go func() {
+				if err := a.refreshBalances(); err != nil {
+					log.Printf("刷新账户余额失败: %v", err)
+				}
+			}()
+		}
+	})
+
+	a.stream = stream
+	stream.Start()
+}
+
+// IsStreamActive 检查账户推送是否正常
+func (a *AccountManager) IsStreamActive() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.streamActive
+}
+
+// refreshBalances 通过REST获取全部余额并替换缓存
+func (a *AccountManager) refreshBalances() error {
+	balances, err := a.client.GetBalances()
+	if err != nil {
+		return err
+	}
+
+	cache := make(map[string]*exchange.Balance, len(balances))
+	for _, balance := range balances {
+		cache[balance.Asset] = balance
+	}
+
+	a.mu.Lock()
+	a.balances = cache
+	a.mu.Unlock()
+	return nil
+}
+
+// applyBalances 应用推送的余额变化
+func (a *AccountManager) applyBalances(balances []*exchange.Balance) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, balance := range balances {
+		a.balances[balance.Asset] = balance
+	}
+}
+
+// balancesLive 检查余额缓存是否由推送实时更新
+func (a *AccountManager) balancesLive() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.pushBalances && a.streamActive
+}
+
+// GetBalance 获取指定资产余额，推送不可用时查询REST接口
+func (a *AccountManager) GetBalance(asset string) (*exchange.Balance, error) {
+	if !a.balancesLive() {
+		balance, err := a.client.GetBalance(asset)
+		if err != nil {
+			return nil, err
+		}
+		a.applyBalances([]*exchange.Balance{balance})
+		return balance, nil
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if balance, ok := a.balances[asset]; ok {
+		return balance, nil
+	}
+	return &exchange.Balance{Asset: asset}, nil
+}
+
+// GetBalances 获取全部资产余额，推送不可用时查询REST接口
+func (a *AccountManager) GetBalances() ([]*exchange.Balance, error) {
+	if !a.balancesLive() {
+		if err := a.refreshBalances(); err != nil {
+			return nil, err
+		}
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]*exchange.Balance, 0, len(a.balances))
+	for _, balance := range a.balances {
+		result = append(result, balance)
+	}
+	return result, nil
+}
+
+// reservedAmount 资产被占用的总额，调用方需持有锁
+func (a *AccountManager) reservedAmount(asset string) float64 {
+	total := 0.0
+	for _, reservation := range a.reservations {
+		if reservation.Asset == asset {
+			total += reservation.Amount
+		}
+	}
+	return total
+}
+
+// Available 获取资产扣除占用后的可用余额
+func (a *AccountManager) Available(asset string) (float64, error) {
+	balance, err := a.GetBalance(asset)
+	if err != nil {
+		return 0, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return balance.Free - a.reservedAmount(asset), nil
+}
+
+// CachedAvailable 从缓存获取资产扣除占用后的可用余额，不查询REST接口，供行情驱动的计算使用
+func (a *AccountManager) CachedAvailable(asset string) float64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	balance, ok := a.balances[asset]
+	if !ok {
+		return 0
+	}
+	return balance.Free - a.reservedAmount(asset)
+}
+
+// Reserve 为交易占用资产，可用余额不足时返回 ErrInsufficientBalance
+// 交易执行期间成交会使交易所的可用余额减少，而占用直到 Release 才解除，因此可用余额会被低估，不会被高估
+func (a *AccountManager) Reserve(asset string, amount float64, tradeID string) error {
+	balance, err := a.GetBalance(asset)
+	if err != nil {
+		return fmt.Errorf("获取 %s 余额失败: %w", asset, err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.reservations[tradeID]; ok {
+		return fmt.Errorf("交易 %s 已占用余额", tradeID)
+	}
+
+	reserved := a.reservedAmount(asset)
+	if available := balance.Free - reserved; amount > available {
+		return fmt.Errorf("%w: %s 可用 %.8f (已占用 %.8f), 需要 %.8f", ErrInsufficientBalance, asset, available, reserved, amount)
+	}
+
+	a.reservations[tradeID] = &BalanceReservation{
+		TradeID:   tradeID,
+		Asset:     asset,
+		Amount:    amount,
+		CreatedAt: time.Now(),
+	}
+	return nil
+}
+
+// Release 解除交易占用的余额，交易未占用余额时不做任何操作
+func (a *AccountManager) Release(tradeID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.reservations, tradeID)
+}
+
+// Reservations 获取全部进行中的余额占用
+func (a *AccountManager) Reservations() []*BalanceReservation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]*BalanceReservation, 0, len(a.reservations))
+	for _, reservation := range a.reservations {
+		result = append(result, reservation)
+	}
+	return result
+}
+
+// AssetBalances 获取各资产的可用、冻结和占用余额，按资产名称排序，省略余额为0的资产
+func (a *AccountManager) AssetBalances() ([]*AssetBalance, error) {
+	balances, err := a.GetBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]*AssetBalance, 0, len(balances))
+	for _, balance := range balances {
+		reserved := a.reservedAmount(balance.Asset)
+		if balance.Free == 0 && balance.Locked == 0 && reserved == 0 {
+			continue
+		}
+		result = append(result, &AssetBalance{
+			Asset:     balance.Asset,
+			Free:      balance.Free,
+			Locked:    balance.Locked,
+			Reserved:  reserved,
+			Available: balance.Free - reserved,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Asset < result[j].Asset
+	})
+	return result, nil
+}
+
+// orderKey 订单缓存的键，Binance的订单ID只在交易对内唯一
+func orderKey(symbol, orderID string) string {
+	return symbol + "/" + orderID
+}
+
+// isOrderFinal 检查订单是否已结束
+func isOrderFinal(status string) bool {
+	switch status {
+	case exchange.OrderStatusFilled, exchange.OrderStatusCanceled, exchange.OrderStatusRejected, exchange.OrderStatusExpired:
+		return true
+	}
+	return false
+}
+
+// applyOrder 更新订单状态缓存并通知等待该订单的调用方
+// 推送和REST结果可能乱序到达，忽略比缓存更旧的状态
+func (a *AccountManager) applyOrder(order *exchange.Order) {
+	key := orderKey(order.Symbol, order.OrderID)
+
+	a.mu.Lock()
+	if cached, ok := a.orders[key]; ok {
+		if isOrderFinal(cached.order.Status) && !isOrderFinal(order.Status) ||
+			order.UpdateTime.Before(cached.order.UpdateTime) {
+			a.mu.Unlock()
+			return
+		}
+	}
+	a.orders[key] = &cachedOrder{order: order, receivedAt: time.Now()}
+	waiters := a.waiters[key]
+	a.mu.Unlock()
+
+	for _, notify := range waiters {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// applyExecution 缓存订单的成交明细
+func (a *AccountManager) applyExecution(execution *exchange.Execution) {
+	key := orderKey(execution.Symbol, execution.OrderID)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fills[key] = append(a.fills[key], execution)
+}
+
+// GetFills 获取推送的订单成交明细，推送不可用或交易所不推送成交明细时为空
+func (a *AccountManager) GetFills(symbol, orderID string) []*exchange.Execution {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	fills := a.fills[orderKey(symbol, orderID)]
+	return append([]*exchange.Execution(nil), fills...)
+}
+
+// GetOrder 获取缓存的订单状态，未缓存时返回nil
+func (a *AccountManager) GetOrder(symbol, orderID string) *exchange.Order {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if cached, ok := a.orders[orderKey(symbol, orderID)]; ok {
+		return cached.order
+	}
+	return nil
+}
+
+// WaitForOrder 等待订单状态满足 done
+// 推送正常时由推送唤醒，只低频查询REST兜底；推送不可用时按 accountPollInterval 轮询。
+// 超时或查询返回致命错误时，返回最后获取到的订单状态（可能为nil）和错误
+func (a *AccountManager) WaitForOrder(symbol, orderID string, timeout time.Duration, done func(*exchange.Order) bool) (*exchange.Order, error) {
+	key := orderKey(symbol, orderID)
+	notify := make(chan struct{}, 1)
+
+	a.mu.Lock()
+	a.waiters[key] = append(a.waiters[key], notify)
+	a.mu.Unlock()
+	defer a.removeWaiter(key, notify)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	// 推送正常时下单后的第一次状态变化通常很快到达，不立即查询
+	var lastPoll time.Time
+	if a.IsStreamActive() {
+		lastPoll = time.Now()
+	}
+
+	var last *exchange.Order
+	for {
+		if order := a.GetOrder(symbol, orderID); order != nil {
+			last = order
+			if done(order) {
+				return order, nil
+			}
+		}
+
+		interval := accountPollInterval
+		if a.IsStreamActive() {
+			interval = accountStreamPollInterval
+		}
+
+		if time.Since(lastPoll) >= interval {
+			lastPoll = time.Now()
+			order, err := a.client.GetOrder(symbol, orderID)
+			if err == nil {
+				a.applyOrder(order)
+				continue
+			}
+
+			log.Printf("查询订单失败: %v", err)
+			// 交易所明确返回的订单不存在、密钥无效等错误重试无效
+			var apiErr *exchange.APIError
+			if errors.As(err, &apiErr) && apiErr.Class() == exchange.ErrorFatal {
+				return last, err
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-time.After(interval - time.Since(lastPoll)):
+		case <-deadline.C:
+			return last, fmt.Errorf("等待订单 %s 超时", orderID)
+		case <-a.stopChan:
+			return last, fmt.Errorf("账户管理器已停止")
+		}
+	}
+}
+
+// removeWaiter 移除订单的通知通道
+func (a *AccountManager) removeWaiter(key string, notify chan struct{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	waiters := a.waiters[key]
+	for i, ch := range waiters {
+		if ch == notify {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(a.waiters, key)
+	} else {
+		a.waiters[key] = waiters
+	}
+}
+
+// cleanupLoop 定期清除过期的订单状态
+func (a *AccountManager) cleanupLoop() {
+	ticker := time.NewTicker(accountOrderRetention)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stopChan:
+			return
+		case <-ticker.C:
+			a.mu.Lock()
+			for key, cached := range a.orders {
+				if time.Since(cached.receivedAt) > accountOrderRetention && len(a.waiters[key]) == 0 {
+					delete(a.orders, key)
+				}
+			}
+			for key := range a.fills {
+				if _, ok := a.orders[key]; !ok {
+					delete(a.fills, key)
+				}
+			}
+			a.mu.Unlock()
+		}
+	}
+}
@@ -6,76 +6,173 @@ import (
 	"math"
 	"sync"
 	"time"
+
+	"inarbit/arbitrage"
+	"inarbit/exchange"
 )
 
 // ArbitrageOpportunity 套利机会
 type ArbitrageOpportunity struct {
-	ID                 string
-	Type               string    // triangular, quadrangular, pentagonal
-	Pair1              string
-	Pair2              string
-	Pair3              string
-	Pair4              *string
-	Pair5              *string
-	Path               []string  // 交易路径
-	InitialAmount      float64
-	FinalAmount        float64
-	GrossProfit        float64   // 毛利润
-	NetProfit          float64   // 净利润
-	ProfitPercentage   float64   // 利润百分比
-	ExecutionTime      int       // 预计执行时间（毫秒）
-	Confidence         float64   // 信心度 (0-100)
-	Timestamp          time.Time
-	Details            *ArbitrageDetails
+	ID               string
+	Type             string // triangular, quadrangular, pentagonal, cross_exchange
+	StartAsset       string // 起始资产，例如 USDT
+	Pair1            string
+	Pair2            string
+	Pair3            string
+	Pair4            *string
+	Pair5            *string
+	Path             []string // 交易路径
+	InitialAmount    float64
+	FinalAmount      float64
+	GrossProfit      float64 // 毛利润
+	NetProfit        float64 // 净利润
+	ProfitPercentage float64 // 利润百分比
+	ExecutionTime    int     // 预计执行时间（毫秒）
+	Confidence       float64 // 信心度 (0-100)
+	Timestamp        time.Time
+	Details          *ArbitrageDetails
 }
 
 // ArbitrageDetails 套利详情
 type ArbitrageDetails struct {
-	Step1 *TradeStep
-	Step2 *TradeStep
-	Step3 *TradeStep
-	Step4 *TradeStep
-	Step5 *TradeStep
+	Step1     *TradeStep
+	Step2     *TradeStep
+	Step3     *TradeStep
+	Step4     *TradeStep
+	Step5     *TradeStep
 	TotalFees float64
-	Slippage  float64
+	Slippage  float64 // 按订单簿深度成交相对盘口价格损失的金额（起始资产计）
+	MaxAmount float64 // 订单簿深度可承载的最大起始金额
+	MinAmount float64 // 满足 LOT_SIZE/MIN_NOTIONAL 的最小起始金额
+	SizeLimit string  // 决定交易规模的约束: profit, depth, balance, strategy, exchange
+}
+
+// Steps 获取按顺序排列的交易步骤（3-5步）
+func (d *ArbitrageDetails) Steps() []*TradeStep {
+	steps := make([]*TradeStep, 0, 5)
+	for _, step := range []*TradeStep{d.Step1, d.Step2, d.Step3, d.Step4, d.Step5} {
+		if step == nil {
+			break
+		}
+		steps = append(steps, step)
+	}
+	return steps
 }
 
 // TradeStep 交易步骤
 type TradeStep struct {
-	Symbol       string
-	Side         string  // BUY or SELL
-	Price        float64
-	Quantity     float64
-	Amount       float64
-	Fee          float64
+	Exchange      string // 下单的交易所（跨交易所套利），为空时使用默认交易所
+	Symbol        string
+	Side          string  // BUY or SELL
+	Price         float64 // 按订单簿深度计算的成交均价，用于估算利润
+	TopPrice      float64 // 盘口最优价
+	LimitPrice    float64 // 限价单的价格：按订单簿深度吃到的最差一档价格，为0时按 Price 下单
+	Dust          float64 // 舍入后未成交的付出资产数量
+	Quantity      float64
+	QuoteQuantity float64 // 按报价资产金额下单的 MARKET 买单，非0时代替 Quantity
+	Amount        float64
+	Fee           float64 // 按 FeeAsset 计，FeeAsset 为空时按成交所得资产计
 	FeePercentage float64
+	FeeAsset      string // 以抵扣资产（例如 BNB）支付手续费时为该资产，为空时从成交所得中扣除
+	OrderType     string // 下单类型，见 exchange.OrderType 常量，为空时按 LIMIT
+	TimeInForce   string // LIMIT 的有效方式，为空时按 GTC
+}
+
+// 套利步骤默认按 IOC 限价单下单，未能立即成交的部分由交易所撤销，任何一步都不会挂在订单簿上
+const (
+	defaultStepOrderType   = exchange.OrderTypeLimit
+	defaultStepTimeInForce = exchange.TimeInForceIOC
+)
+
+// OrderRequest 构建该步骤的下单请求
+func (s *TradeStep) OrderRequest() *exchange.OrderRequest {
+	req := &exchange.OrderRequest{
+		Symbol:      s.Symbol,
+		Side:        s.Side,
+		Type:        s.OrderType,
+		TimeInForce: s.TimeInForce,
+		Quantity:    s.Quantity,
+		Price:       s.limitPrice(),
+	}
+	if req.Type == "" {
+		req.Type = exchange.OrderTypeLimit
+	}
+	if req.Type == exchange.OrderTypeMarket {
+		req.Price = 0
+		if s.QuoteQuantity > 0 {
+			req.Quantity, req.QuoteQuantity = 0, s.QuoteQuantity
+		}
+	}
+	return req
+}
+
+// limitPrice 获取限价单的价格，没有按深度计算的最差价格时使用成交均价
+func (s *TradeStep) limitPrice() float64 {
+	if s.LimitPrice > 0 {
+		return s.LimitPrice
+	}
+	return s.Price
 }
 
 // ArbitrageEngine 套利引擎
 type ArbitrageEngine struct {
 	marketManager    *MarketManager
 	minProfitPercent float64
-	takerFeePercent  float64
-	makerFeePercent  float64
+	fees             *exchange.FeeModel
+	startAssets      []string // 套利起始资产
+	scanAmount       float64  // 扫描时使用的起始金额
+	maxCycleLength   int      // 最大闭环长度 (3-5)
+	graph            *CurrencyGraph
+	symbolVersion    uint64 // 构建货币图时的交易对集合版本号
+	cycles           []*TriangularCycle
+	multiLegCycles   []*CycleLegs    // 最近发现的4-5步闭环
+	cycleIndex       *CycleIndex     // 交易对 -> 闭环
+	trackedBooks     map[string]bool // 已维护本地订单簿的交易对，只在 scanLoop 中访问
 	mu               sync.RWMutex
 	opportunities    []*ArbitrageOpportunity
+	subscribers      map[int]chan *ArbitrageOpportunity
+	nextSubscriberID int
 	stopChan         chan struct{}
 }
 
-// NewArbitrageEngine 创建套利引擎
-func NewArbitrageEngine(marketManager *MarketManager, minProfitPercent float64) *ArbitrageEngine {
-	return &ArbitrageEngine{
+// multiLegDiscoveryInterval 多边闭环全图检测间隔
+const multiLegDiscoveryInterval = 1 * time.Second
+
+// maxMultiLegCycles 持续跟踪的多边闭环数量上限
+const maxMultiLegCycles = 200
+
+// NewArbitrageEngine 创建套利引擎，maxCycleLength 超出 3-5 时取边界值
+func NewArbitrageEngine(marketManager *MarketManager, minProfitPercent float64, maxCycleLength int) *ArbitrageEngine {
+	engine := &ArbitrageEngine{
 		marketManager:    marketManager,
 		minProfitPercent: minProfitPercent,
-		takerFeePercent:  0.001, // 0.1%
-		makerFeePercent:  0.001, // 0.1%
+		fees:             exchange.NewFeeModel(marketManager.client),
+		startAssets:      []string{"USDT"},
+		scanAmount:       100,
 		opportunities:    make([]*ArbitrageOpportunity, 0),
+		subscribers:      make(map[int]chan *ArbitrageOpportunity),
+		trackedBooks:     make(map[string]bool),
 		stopChan:         make(chan struct{}),
 	}
+	engine.SetMaxCycleLength(maxCycleLength)
+	return engine
+}
+
+// SetFeeModel 设置手续费模型，需在 Start 之前调用
+func (e *ArbitrageEngine) SetFeeModel(fees *exchange.FeeModel) {
+	e.fees = fees
+}
+
+// FeeModel 获取手续费模型
+func (e *ArbitrageEngine) FeeModel() *exchange.FeeModel {
+	return e.fees
 }
 
 // Start 启动套利引擎
 func (e *ArbitrageEngine) Start() {
+	if err := e.fees.Start(); err != nil {
+		log.Printf("加载手续费率失败，使用默认费率: %v", err)
+	}
 	go e.scanLoop()
 	log.Println("✓ 套利引擎已启动")
 }
@@ -83,21 +180,103 @@ func (e *ArbitrageEngine) Start() {
 // Stop 停止套利引擎
 func (e *ArbitrageEngine) Stop() {
 	close(e.stopChan)
+	e.fees.Stop()
 	log.Println("✓ 套利引擎已停止")
 }
 
 // scanLoop 扫描套利机会
+// 启动时为闭环经过的交易对维护本地订单簿并全量扫描一次，之后由行情更新驱动，只重新计算经过变化交易对的闭环；
+// 多边闭环依赖全图检测，按固定间隔执行，行情流断开（REST轮询）时同时全量扫描三角闭环
 func (e *ArbitrageEngine) scanLoop() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+	e.getCycles()
+	e.trackOrderBooks()
+	e.scanTriangularArbitrages()
+	e.scanMultiLegArbitrages()
+
+	discovery := time.NewTicker(multiLegDiscoveryInterval)
+	defer discovery.Stop()
+
+	updates := e.marketManager.Updates()
 
 	for {
 		select {
 		case <-e.stopChan:
 			return
 
-		case <-ticker.C:
-			e.scanTriangularArbitrages()
+		case ticker := <-updates:
+			e.rescanSymbols(drainUpdates(ticker, updates))
+
+		case <-discovery.C:
+			if !e.marketManager.IsStreamActive() {
+				e.scanTriangularArbitrages()
+			}
+			e.scanMultiLegArbitrages()
+			e.trackOrderBooks()
+		}
+	}
+}
+
+// trackOrderBooks 为闭环索引中尚未维护本地订单簿的交易对订阅深度流，订阅失败时在下次全图检测后重试
+// 同时查询这些交易对的费率和抵扣设置
+func (e *ArbitrageEngine) trackOrderBooks() {
+	e.mu.RLock()
+	var symbols []string
+	if e.cycleIndex != nil {
+		symbols = e.cycleIndex.Symbols()
+	}
+	e.mu.RUnlock()
+
+	e.fees.Track(symbols)
+
+	added := make([]string, 0)
+	for _, symbol := range symbols {
+		if !e.trackedBooks[symbol] {
+			added = append(added, symbol)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+
+	if err := e.marketManager.TrackOrderBooks(added); err != nil {
+		log.Printf("维护本地订单簿失败，通过REST获取深度: %v", err)
+		return
+	}
+	for _, symbol := range added {
+		e.trackedBooks[symbol] = true
+	}
+}
+
+// drainUpdates 合并通道中已积压的行情更新，返回去重后的交易对
+func drainUpdates(first *exchange.Ticker, updates <-chan *exchange.Ticker) []string {
+	symbols := []string{first.Symbol}
+	seen := map[string]bool{first.Symbol: true}
+
+	for {
+		select {
+		case ticker := <-updates:
+			if !seen[ticker.Symbol] {
+				seen[ticker.Symbol] = true
+				symbols = append(symbols, ticker.Symbol)
+			}
+		default:
+			return symbols
+		}
+	}
+}
+
+// rescanSymbols 重新计算经过指定交易对的闭环
+func (e *ArbitrageEngine) rescanSymbols(symbols []string) {
+	e.getCycles()
+
+	e.mu.RLock()
+	cycles := e.cycleIndex.Cycles(symbols)
+	e.mu.RUnlock()
+
+	for _, cycle := range cycles {
+		opp := e.evaluateLegs(cycle.StartAsset, cycle.Legs, e.scanAmount)
+		if opp != nil {
+			e.AddOpportunity(opp)
 		}
 	}
 }
@@ -106,43 +285,171 @@ func (e *ArbitrageEngine) scanLoop() {
 
 // scanTriangularArbitrages 扫描三角套利机会
 func (e *ArbitrageEngine) scanTriangularArbitrages() {
-	symbols := e.marketManager.GetAllSymbols()
-	
-	// 按基础货币分组
-	baseCurrencies := make(map[string][]string)
-	for _, symbol := range symbols {
-		if len(symbol) >= 6 {
-			// 假设交易对格式为 XXXYYY（6个字符）
-			// 这是一个简化的实现，实际应该从交易所信息获取
-			baseCurrency := symbol[3:6]
-			baseCurrencies[baseCurrency] = append(baseCurrencies[baseCurrency], symbol)
+	cycles := e.getCycles()
+
+	for _, cycle := range cycles {
+		opp := e.evaluateCycle(cycle, e.scanAmount)
+		if opp != nil {
+			e.AddOpportunity(opp)
 		}
 	}
+}
+
+// getCycles 获取三角闭环，交易对集合变化时重新构建货币图和闭环索引
+func (e *ArbitrageEngine) getCycles() []*TriangularCycle {
+	version := e.marketManager.SymbolVersion()
+
+	e.mu.RLock()
+	if e.graph != nil && e.symbolVersion == version {
+		cycles := e.cycles
+		e.mu.RUnlock()
+		return cycles
+	}
+	e.mu.RUnlock()
 
-	// 对每个基础货币扫描三角套利
-	for baseCurrency, pairs := range baseCurrencies {
-		if len(pairs) >= 3 {
-			e.findTriangularOpportunities(baseCurrency, pairs)
+	// 先取版本号再取交易对信息，两者之间交易对变化时下次调用会再次重建
+	symbols := e.marketManager.GetAllSymbolInfo()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.graph == nil || e.symbolVersion != version {
+		e.graph = NewCurrencyGraph(symbols)
+		e.symbolVersion = version
+		e.cycles = e.graph.FindTriangularCycles(e.startAssets)
+		e.multiLegCycles = nil
+		e.rebuildCycleIndex()
+		log.Printf("✓ 货币图已构建: %d 个交易对, %d 个三角闭环", e.graph.SymbolCount(), len(e.cycles))
+	}
+
+	return e.cycles
+}
+
+// rebuildCycleIndex 根据三角闭环和最近发现的多边闭环重建索引（调用方需持有写锁）
+func (e *ArbitrageEngine) rebuildCycleIndex() {
+	index := NewCycleIndex()
+	for _, cycle := range e.cycles {
+		index.Add(&CycleLegs{StartAsset: cycle.StartAsset, Legs: cycle.Legs[:]})
+	}
+	for _, cycle := range e.multiLegCycles {
+		index.Add(cycle)
+	}
+	e.cycleIndex = index
+}
+
+// trackMultiLegCycles 将新发现的多边闭环加入索引，只保留最近的 maxMultiLegCycles 个
+func (e *ArbitrageEngine) trackMultiLegCycles(found []*CycleLegs) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cycleIndex == nil {
+		return
+	}
+
+	added := false
+	for _, cycle := range found {
+		if e.cycleIndex.Add(cycle) {
+			e.multiLegCycles = append(e.multiLegCycles, cycle)
+			added = true
 		}
 	}
+
+	if added && len(e.multiLegCycles) > maxMultiLegCycles {
+		e.multiLegCycles = e.multiLegCycles[len(e.multiLegCycles)-maxMultiLegCycles:]
+		e.rebuildCycleIndex()
+	}
 }
 
-// findTriangularOpportunities 查找三角套利机会
-func (e *ArbitrageEngine) findTriangularOpportunities(baseCurrency string, pairs []string) {
-	// 这是一个简化的实现
-	// 实际应该根据交易对的基础货币和报价货币来构建交易路径
-	
-	if len(pairs) < 3 {
+// SetStartAssets 设置套利起始资产
+func (e *ArbitrageEngine) SetStartAssets(assets []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.startAssets = assets
+	e.graph = nil // 下次扫描时重新枚举闭环
+}
+
+// ===== 多边套利扫描 =====
+
+// scanMultiLegArbitrages 使用Bellman-Ford负权环检测扫描四角、五角套利机会
+// 三角闭环已由 scanTriangularArbitrages 完整枚举，这里只处理4步及以上的闭环
+func (e *ArbitrageEngine) scanMultiLegArbitrages() {
+	e.getCycles()
+
+	e.mu.RLock()
+	graph := e.graph
+	startAssets := e.startAssets
+	maxLen := e.maxCycleLength
+	e.mu.RUnlock()
+
+	if graph == nil || maxLen < 4 {
 		return
 	}
 
-	// 示例：对于USDT基础货币，查找如下路径：
-	// 1. USDT -> BTC (买入BTC)
-	// 2. BTC -> ETH (卖出BTC买入ETH)
-	// 3. ETH -> USDT (卖出ETH买入USDT)
+	edges := make([]arbitrage.RateEdge, 0)
+	legByKey := make(map[string]*CurrencyEdge)
+	for _, edge := range graph.AllEdges() {
+		rate := e.edgeRate(edge)
+		if rate <= 0 {
+			continue
+		}
+		edges = append(edges, arbitrage.RateEdge{
+			From:   edge.From,
+			To:     edge.To,
+			Symbol: edge.Symbol,
+			Side:   edge.Side,
+			Rate:   rate,
+		})
+		legByKey[edge.Symbol+":"+edge.Side] = edge
+	}
+
+	cycles := arbitrage.FindNegativeCycles(edges, startAssets, 4, maxLen)
+	found := make([]*CycleLegs, 0, len(cycles))
+	for _, cycle := range cycles {
+		legs := make([]*CurrencyEdge, len(cycle.Edges))
+		for i, edge := range cycle.Edges {
+			legs[i] = legByKey[edge.Symbol+":"+edge.Side]
+		}
+		found = append(found, &CycleLegs{StartAsset: cycle.StartAsset, Legs: legs})
+
+		opp := e.evaluateLegs(cycle.StartAsset, legs, e.scanAmount)
+		if opp != nil {
+			e.AddOpportunity(opp)
+		}
+	}
+
+	// 后续行情变化时按索引重新计算这些闭环
+	e.trackMultiLegCycles(found)
+}
+
+// edgeRate 计算一条边扣除手续费后的汇率
+func (e *ArbitrageEngine) edgeRate(edge *CurrencyEdge) float64 {
+	ticker := e.marketManager.GetTicker(edge.Symbol)
+	if ticker == nil {
+		return 0
+	}
+
+	if edge.Side == "BUY" {
+		if ticker.AskPrice <= 0 {
+			return 0
+		}
+		return 1 / ticker.AskPrice * (1 - e.fees.Taker(edge.Symbol))
+	}
+	return ticker.BidPrice * (1 - e.fees.Taker(edge.Symbol))
+}
+
+// SetMaxCycleLength 设置最大闭环长度 (3-5)
+func (e *ArbitrageEngine) SetMaxCycleLength(length int) {
+	if length < arbitrage.MinCycleLength {
+		length = arbitrage.MinCycleLength
+	}
+	if length > arbitrage.MaxCycleLength {
+		length = arbitrage.MaxCycleLength
+	}
 
-	// 这需要更复杂的图论算法来找到所有可能的路径
-	// 这里只是演示基本逻辑
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.maxCycleLength = length
 }
 
 // CalculateTriangularArbitrage 计算三角套利
@@ -150,94 +457,284 @@ func (e *ArbitrageEngine) CalculateTriangularArbitrage(
 	pair1, pair2, pair3 string,
 	initialAmount float64,
 ) *ArbitrageOpportunity {
-	
-	// 获取行情
-	ticker1 := e.marketManager.GetTicker(pair1)
-	ticker2 := e.marketManager.GetTicker(pair2)
-	ticker3 := e.marketManager.GetTicker(pair3)
 
-	if ticker1 == nil || ticker2 == nil || ticker3 == nil {
+	// 验证交易对组合
+	if !e.validatePairCombination(pair1, pair2, pair3) {
 		return nil
 	}
 
-	// 验证交易对组合
-	if !e.validatePairCombination(pair1, pair2, pair3) {
+	e.mu.RLock()
+	cycle := e.graph.ResolveCycle(pair1, pair2, pair3)
+	e.mu.RUnlock()
+
+	if cycle == nil {
 		return nil
 	}
 
-	// 计算交易步骤
-	step1 := &TradeStep{
-		Symbol:        pair1,
-		Side:          "BUY",
-		Price:         ticker1.AskPrice,
-		Quantity:      initialAmount / ticker1.AskPrice,
-		Amount:        initialAmount,
-		FeePercentage: e.takerFeePercent,
-	}
-	step1.Fee = step1.Amount * step1.FeePercentage
-	step1.Amount -= step1.Fee
-
-	step2 := &TradeStep{
-		Symbol:        pair2,
-		Side:          "SELL",
-		Price:         ticker2.BidPrice,
-		Quantity:      step1.Quantity,
-		Amount:        step1.Quantity * ticker2.BidPrice,
-		FeePercentage: e.takerFeePercent,
-	}
-	step2.Fee = step2.Amount * step2.FeePercentage
-	step2.Amount -= step2.Fee
-
-	step3 := &TradeStep{
-		Symbol:        pair3,
-		Side:          "SELL",
-		Price:         ticker3.BidPrice,
-		Quantity:      step2.Amount / ticker3.BidPrice,
-		Amount:        step2.Amount,
-		FeePercentage: e.takerFeePercent,
-	}
-	step3.Fee = step3.Amount * step3.FeePercentage
-	step3.Amount -= step3.Fee
-
-	// 计算利润
-	finalAmount := step3.Amount
-	grossProfit := finalAmount - initialAmount
-	totalFees := step1.Fee + step2.Fee + step3.Fee
-	netProfit := grossProfit - totalFees
-	profitPercentage := (netProfit / initialAmount) * 100
+	return e.evaluateCycle(cycle, initialAmount)
+}
 
-	// 检查是否值得执行
-	if profitPercentage < e.minProfitPercent {
+// evaluateCycle 按当前行情计算闭环的套利收益
+func (e *ArbitrageEngine) evaluateCycle(cycle *TriangularCycle, initialAmount float64) *ArbitrageOpportunity {
+	return e.evaluateLegs(cycle.StartAsset, cycle.Legs[:], initialAmount)
+}
+
+// evaluateLegs 计算3-5步闭环的套利收益，不满足最低利润时返回nil
+func (e *ArbitrageEngine) evaluateLegs(startAsset string, legs []*CurrencyEdge, initialAmount float64) *ArbitrageOpportunity {
+	opportunity := e.simulateLegs(startAsset, legs, initialAmount)
+	if opportunity == nil || opportunity.ProfitPercentage < e.minProfitPercent {
 		return nil
 	}
 
+	// 盘口价格有利可图时，再按订单簿深度验证
+	if !e.applyDepth(opportunity, legs) || opportunity.ProfitPercentage < e.minProfitPercent {
+		return nil
+	}
+
+	return opportunity
+}
+
+// simulateLegs 按盘口价格依次模拟每一步交易，行情缺失时返回nil
+func (e *ArbitrageEngine) simulateLegs(startAsset string, legs []*CurrencyEdge, initialAmount float64) *ArbitrageOpportunity {
+	if initialAmount <= 0 || len(legs) < 3 || len(legs) > 5 {
+		return nil
+	}
+
+	steps := make([]*TradeStep, len(legs))
+	amount := initialAmount
+	grossAmount := initialAmount
+	dust := make(map[string]float64)       // 各资产舍入后的余量，结转到后续步骤
+	commission := make(map[string]float64) // 以抵扣资产支付的手续费
+
+	for i, leg := range legs {
+		ticker := e.marketManager.GetTicker(leg.Symbol)
+		if ticker == nil {
+			return nil
+		}
+
+		price := ticker.BidPrice
+		if leg.Side == "BUY" {
+			price = ticker.AskPrice
+		}
+
+		step := e.simulateLeg(leg, price, amount+dust[leg.From], commission)
+		if step == nil {
+			return nil
+		}
+		step.TopPrice = price
+		dust[leg.From] = step.Dust
+		steps[i] = step
+		amount = step.Amount
+
+		// 不计手续费的理论金额
+		if leg.Side == "BUY" {
+			grossAmount = grossAmount / step.Price
+		} else {
+			grossAmount = grossAmount * step.Price
+		}
+	}
+
+	// 以抵扣资产支付的手续费折算为起始资产计入成本
+	commissionCost, ok := e.commissionCost(startAsset, commission)
+	if !ok {
+		return nil
+	}
+
+	// 计算利润（起始资产的舍入余量未被花费，计入最终金额）
+	finalAmount := amount + dust[startAsset]
+	grossProfit := grossAmount - initialAmount
+	netProfit := finalAmount - commissionCost - initialAmount
+	totalFees := grossProfit - netProfit
+	profitPercentage := (netProfit / initialAmount) * 100
+
+	pairs := make([]string, len(legs))
+	for i, leg := range legs {
+		pairs[i] = leg.Symbol
+	}
+
 	opportunity := &ArbitrageOpportunity{
 		ID:               generateOpportunityID(),
-		Type:             "triangular",
-		Pair1:            pair1,
-		Pair2:            pair2,
-		Pair3:            pair3,
-		Path:             []string{pair1, pair2, pair3},
+		Type:             cycleType(len(legs)),
+		StartAsset:       startAsset,
+		Pair1:            pairs[0],
+		Pair2:            pairs[1],
+		Pair3:            pairs[2],
+		Path:             pairs,
 		InitialAmount:    initialAmount,
 		FinalAmount:      finalAmount,
 		GrossProfit:      grossProfit,
 		NetProfit:        netProfit,
 		ProfitPercentage: profitPercentage,
-		ExecutionTime:    3000, // 预计3秒
+		ExecutionTime:    1000 * len(legs), // 预计每步1秒
 		Confidence:       calculateConfidence(profitPercentage),
 		Timestamp:        time.Now(),
 		Details: &ArbitrageDetails{
-			Step1:     step1,
-			Step2:     step2,
-			Step3:     step3,
+			Step1:     steps[0],
+			Step2:     steps[1],
+			Step3:     steps[2],
 			TotalFees: totalFees,
-			Slippage:  0, // 实际执行时会有滑点
 		},
 	}
 
+	if len(legs) >= 4 {
+		opportunity.Pair4 = &pairs[3]
+		opportunity.Details.Step4 = steps[3]
+	}
+	if len(legs) == 5 {
+		opportunity.Pair5 = &pairs[4]
+		opportunity.Details.Step5 = steps[4]
+	}
+
 	return opportunity
 }
 
+// applyDepth 按订单簿深度重新模拟每一步成交，更新成交均价、可承载金额、滑点和利润
+// 深度不足以承载初始金额时返回false
+func (e *ArbitrageEngine) applyDepth(opp *ArbitrageOpportunity, legs []*CurrencyEdge) bool {
+	topGrossAmount := opp.InitialAmount + opp.GrossProfit
+	steps := opp.Details.Steps()
+	amount := opp.InitialAmount
+	grossAmount := opp.InitialAmount
+	maxAmount := math.Inf(1)
+	dust := make(map[string]float64)
+	commission := make(map[string]float64)
+
+	for i, leg := range legs {
+		book, err := e.marketManager.GetOrderBook(leg.Symbol)
+		if err != nil {
+			return false
+		}
+
+		amountIn := amount + dust[leg.From]
+		fill := book.Fill(leg.Side, amountIn)
+		if !fill.Complete || fill.AvgPrice <= 0 {
+			return false
+		}
+
+		// 将本步骤的深度容量按当前金额比例折算为起始资产
+		maxAmount = math.Min(maxAmount, book.Capacity(leg.Side)*opp.InitialAmount/amountIn)
+
+		step := e.simulateLeg(leg, fill.AvgPrice, amountIn, commission)
+		if step == nil {
+			return false
+		}
+		step.TopPrice = fill.BestPrice
+		// 按成交均价下IOC限价单只能吃到均价以内的档位，限价取吃到的最差一档，同样按不利方向舍入
+		rules := e.marketManager.GetTradingRules(leg.Symbol)
+		if leg.Side == "BUY" {
+			step.LimitPrice = ceilToStep(fill.WorstPrice, rules.TickSize)
+		} else {
+			step.LimitPrice = roundToStep(fill.WorstPrice, rules.TickSize)
+		}
+		*steps[i] = *step
+		dust[leg.From] = step.Dust
+		amount = step.Amount
+
+		if leg.Side == "BUY" {
+			grossAmount = grossAmount / step.Price
+		} else {
+			grossAmount = grossAmount * step.Price
+		}
+	}
+
+	commissionCost, ok := e.commissionCost(opp.StartAsset, commission)
+	if !ok {
+		return false
+	}
+
+	amount += dust[opp.StartAsset]
+	opp.FinalAmount = amount
+	opp.NetProfit = amount - commissionCost - opp.InitialAmount
+	opp.ProfitPercentage = (opp.NetProfit / opp.InitialAmount) * 100
+	opp.Confidence = calculateConfidence(opp.ProfitPercentage)
+	opp.Details.TotalFees = grossAmount - amount + commissionCost
+	opp.Details.Slippage = topGrossAmount - grossAmount
+	opp.Details.MaxAmount = maxAmount
+
+	return true
+}
+
+// simulateLeg 按给定成交价模拟一步交易，amountIn 为付出资产的数量
+// 价格按 PRICE_FILTER 舍入（BUY 向上、SELL 向下，均取不利方向），数量按 LOT_SIZE 向下舍入，
+// 未能成交的舍入余量记入 Dust；数量或成交额不满足交易所过滤器时返回nil
+// BUY: 用报价资产买入基础资产，手续费以基础资产计
+// SELL: 卖出基础资产得到报价资产，手续费以报价资产计
+// 开启BNB抵扣时手续费按成交所得（或付出）资产与抵扣资产的直接交易对中间价折算，不减少成交所得，并累加到 commission；
+// 抵扣资产余额不足以支付各步累计的手续费或无法折算时，按标准费率从成交所得中扣除
+func (e *ArbitrageEngine) simulateLeg(leg *CurrencyEdge, price float64, amountIn float64, commission map[string]float64) *TradeStep {
+	rules := e.marketManager.GetTradingRules(leg.Symbol)
+	if rules == nil || price <= 0 || amountIn <= 0 {
+		return nil
+	}
+
+	step := &TradeStep{
+		Symbol:      leg.Symbol,
+		Side:        leg.Side,
+		OrderType:   defaultStepOrderType,
+		TimeInForce: defaultStepTimeInForce,
+	}
+
+	var notional, paid, received float64
+	if leg.Side == "BUY" {
+		step.Price = ceilToStep(price, rules.TickSize)
+		step.Quantity = roundToStep(amountIn/step.Price, rules.StepSize)
+		notional = step.Quantity * step.Price
+		step.Dust = amountIn - notional
+		paid, received = notional, step.Quantity
+	} else {
+		step.Price = roundToStep(price, rules.TickSize)
+		step.Quantity = roundToStep(amountIn, rules.StepSize)
+		notional = step.Quantity * step.Price
+		step.Dust = amountIn - step.Quantity
+		paid, received = step.Quantity, notional
+	}
+
+	if step.Quantity <= 0 || step.Quantity < rules.MinQty {
+		return nil
+	}
+	if rules.MaxQty > 0 && step.Quantity > rules.MaxQty {
+		return nil
+	}
+	if notional < rules.MinNotional {
+		return nil
+	}
+
+	fee := e.fees.Rate(leg.Symbol, false)
+	if fee.Asset != "" {
+		amount, ok := e.marketManager.ConvertAmount(received*fee.Rate, leg.To, fee.Asset)
+		if !ok {
+			amount, ok = e.marketManager.ConvertAmount(paid*fee.Rate, leg.From, fee.Asset)
+		}
+		if ok && commission[fee.Asset]+amount <= e.fees.DiscountBalance(fee.Asset) {
+			commission[fee.Asset] += amount
+			step.FeePercentage = fee.Rate
+			step.FeeAsset = fee.Asset
+			step.Fee = amount
+			step.Amount = received
+			return step
+		}
+		fee = e.fees.StandardRate(leg.Symbol, false)
+	}
+
+	step.FeePercentage = fee.Rate
+	step.Fee = received * fee.Rate
+	step.Amount = received - step.Fee
+	return step
+}
+
+// commissionCost 将以抵扣资产支付的手续费折算为起始资产，无法折算时返回false
+func (e *ArbitrageEngine) commissionCost(startAsset string, commission map[string]float64) (float64, bool) {
+	var cost float64
+	for asset, amount := range commission {
+		value, ok := e.marketManager.ConvertAmount(amount, asset, startAsset)
+		if !ok {
+			return 0, false
+		}
+		cost += value
+	}
+	return cost, true
+}
+
 // validatePairCombination 验证交易对组合
 func (e *ArbitrageEngine) validatePairCombination(pair1, pair2, pair3 string) bool {
 	// 验证交易对是否存在
@@ -248,12 +745,14 @@ func (e *ArbitrageEngine) validatePairCombination(pair1, pair2, pair3 string) bo
 	}
 
 	// 验证交易对是否能形成闭合回路
-	// 这需要检查基础货币和报价货币的匹配
-	// 简化实现：假设所有交易对都有效
-	return true
+	e.getCycles()
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.graph.ResolveCycle(pair1, pair2, pair3) != nil
 }
 
-// AddOpportunity 添加套利机会
+// AddOpportunity 添加套利机会并推送给订阅者
 func (e *ArbitrageEngine) AddOpportunity(opp *ArbitrageOpportunity) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -264,6 +763,38 @@ func (e *ArbitrageEngine) AddOpportunity(opp *ArbitrageOpportunity) {
 	if len(e.opportunities) > 1000 {
 		e.opportunities = e.opportunities[len(e.opportunities)-1000:]
 	}
+
+	for _, ch := range e.subscribers {
+		select {
+		case ch <- opp:
+		default:
+			// 订阅者处理不及时，丢弃该机会，不阻塞扫描
+		}
+	}
+}
+
+// Subscribe 订阅新发现的套利机会
+// 返回的通道满时新机会会被丢弃；调用取消函数后停止推送并关闭通道
+func (e *ArbitrageEngine) Subscribe(buffer int) (<-chan *ArbitrageOpportunity, func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id := e.nextSubscriberID
+	e.nextSubscriberID++
+	ch := make(chan *ArbitrageOpportunity, buffer)
+	e.subscribers[id] = ch
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			delete(e.subscribers, id)
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
 }
 
 // GetOpportunities 获取套利机会
@@ -295,6 +826,24 @@ func (e *ArbitrageEngine) GetBestOpportunity() *ArbitrageOpportunity {
 	return best
 }
 
+// GetBestOpportunityByType 获取指定类型的最佳套利机会
+func (e *ArbitrageEngine) GetBestOpportunityByType(oppType string) *ArbitrageOpportunity {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var best *ArbitrageOpportunity
+	for _, opp := range e.opportunities {
+		if opp.Type != oppType {
+			continue
+		}
+		if best == nil || opp.ProfitPercentage > best.ProfitPercentage {
+			best = opp
+		}
+	}
+
+	return best
+}
+
 // ClearOpportunities 清除套利机会
 func (e *ArbitrageEngine) ClearOpportunities() {
 	e.mu.Lock()
@@ -304,6 +853,18 @@ func (e *ArbitrageEngine) ClearOpportunities() {
 
 // ===== 辅助函数 =====
 
+// cycleType 根据闭环长度获取套利类型
+func cycleType(length int) string {
+	switch length {
+	case 4:
+		return "quadrangular"
+	case 5:
+		return "pentagonal"
+	default:
+		return "triangular"
+	}
+}
+
 // generateOpportunityID 生成机会ID
 func generateOpportunityID() string {
 	return fmt.Sprintf("opp_%d", time.Now().UnixNano())
@@ -315,7 +876,7 @@ func calculateConfidence(profitPercentage float64) float64 {
 	// 0.1% 利润 -> 50% 信心度
 	// 0.5% 利润 -> 80% 信心度
 	// 1.0% 利润 -> 95% 信心度
-	
+
 	confidence := math.Min(50+profitPercentage*100, 100)
 	return math.Max(confidence, 0)
 }
@@ -351,7 +912,7 @@ func (e *ArbitrageEngine) AssessRisk(opp *ArbitrageOpportunity) *RiskAssessment
 func (e *ArbitrageEngine) assessSlippageRisk(opp *ArbitrageOpportunity) float64 {
 	// 基于买卖价差评估滑点风险
 	// 价差越大，滑点风险越高
-	
+
 	risk := 0.0
 	for _, pair := range opp.Path {
 		spread := e.marketManager.GetSpreadPercentage(pair)
@@ -365,7 +926,7 @@ func (e *ArbitrageEngine) assessSlippageRisk(opp *ArbitrageOpportunity) float64
 func (e *ArbitrageEngine) assessLiquidityRisk(opp *ArbitrageOpportunity) float64 {
 	// 基于交易量评估流动性风险
 	// 交易量越小，流动性风险越高
-	
+
 	risk := 0.0
 	for _, pair := range opp.Path {
 		ticker := e.marketManager.GetTicker(pair)
@@ -388,7 +949,7 @@ func (e *ArbitrageEngine) assessLiquidityRisk(opp *ArbitrageOpportunity) float64
 func (e *ArbitrageEngine) assessExecutionRisk(opp *ArbitrageOpportunity) float64 {
 	// 基于执行时间评估执行风险
 	// 执行时间越长，风险越高
-	
+
 	// 每1秒增加5%风险
 	risk := float64(opp.ExecutionTime/1000) * 5
 	return math.Min(risk, 100)
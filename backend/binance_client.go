@@ -5,360 +5,849 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// BinanceClient Binance API客户端
+// BinanceClient Binance现货API客户端，实现 Exchange 接口
 type BinanceClient struct {
-	apiKey    string
-	apiSecret string
-	baseURL   string
-	httpClient *http.Client
-	isTestnet bool
+	APIKey      string
+	APISecret   string
+	BaseURL     string
+	StreamURL   string
+	IsTestnet   bool
+	HTTPClient  *http.Client
+	RateLimiter *RateLimiter // 同一接口地址的客户端共享，为nil时不限制
+	RecvWindow  int64        // 签名请求的有效时间窗口（毫秒），为0时使用服务器默认值
+	clock       serverClock
 }
 
-// NewBinanceClient 创建新的Binance客户端
-func NewBinanceClient(apiKey, apiSecret string, isTestnet bool) *BinanceClient {
+var (
+	_ Exchange           = (*BinanceClient)(nil)
+	_ RateLimited        = (*BinanceClient)(nil)
+	_ OrderSubscriber    = (*BinanceClient)(nil)
+	_ UserDataSubscriber = (*BinanceClient)(nil)
+	_ FeeProvider        = (*BinanceClient)(nil)
+)
+
+// NewBinanceClient 创建Binance客户端，recvWindow 为签名请求的有效时间窗口（毫秒）
+func NewBinanceClient(apiKey, apiSecret string, isTestnet bool, recvWindow int64) *BinanceClient {
 	baseURL := "https://api.binance.com"
+	streamURL := "wss://stream.binance.com:9443"
 	if isTestnet {
 		baseURL = "https://testnet.binance.vision"
+		streamURL = "wss://testnet.binance.vision"
 	}
 
 	return &BinanceClient{
-		apiKey:    apiKey,
-		apiSecret: apiSecret,
-		baseURL:   baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		isTestnet: isTestnet,
-	}
-}
-
-// ExchangeInfo 交易所信息
-type ExchangeInfo struct {
-	Timezone        string      `json:"timezone"`
-	ServerTime      int64       `json:"serverTime"`
-	Symbols         []SymbolInfo `json:"symbols"`
-}
-
-// SymbolInfo 交易对信息
-type SymbolInfo struct {
-	Symbol             string `json:"symbol"`
-	Status             string `json:"status"`
-	BaseAsset          string `json:"baseAsset"`
-	QuoteAsset         string `json:"quoteAsset"`
-	BaseAssetPrecision int    `json:"baseAssetPrecision"`
-	QuotePrecision     int    `json:"quotePrecision"`
-	OrderTypes         []string `json:"orderTypes"`
-	Filters            []map[string]interface{} `json:"filters"`
-}
-
-// Ticker 行情数据
-type Ticker struct {
-	Symbol   string `json:"symbol"`
-	BidPrice string `json:"bidPrice"`
-	BidQty   string `json:"bidQty"`
-	AskPrice string `json:"askPrice"`
-	AskQty   string `json:"askQty"`
-	LastPrice string `json:"lastPrice"`
-	Volume   string `json:"volume"`
-}
-
-// Account 账户信息
-type Account struct {
-	MakerCommission  int       `json:"makerCommission"`
-	TakerCommission  int       `json:"takerCommission"`
-	BuyerCommission  int       `json:"buyerCommission"`
-	SellerCommission int       `json:"sellerCommission"`
-	CanTrade         bool      `json:"canTrade"`
-	CanDeposit       bool      `json:"canDeposit"`
-	CanWithdraw      bool      `json:"canWithdraw"`
-	UpdateTime       int64     `json:"updateTime"`
-	Balances         []Balance `json:"balances"`
-}
-
-// Balance 余额信息
-type Balance struct {
-	Asset  string `json:"asset"`
-	Free   string `json:"free"`
-	Locked string `json:"locked"`
-}
-
-// Order 订单信息
-type Order struct {
-	Symbol            string `json:"symbol"`
-	OrderID           int64  `json:"orderId"`
-	ClientOrderID     string `json:"clientOrderId"`
-	Price             string `json:"price"`
-	OrigQty           string `json:"origQty"`
-	ExecutedQty       string `json:"executedQty"`
-	CummulativeQuoteQty string `json:"cummulativeQuoteQty"`
-	Status            string `json:"status"`
-	TimeInForce       string `json:"timeInForce"`
-	Type              string `json:"type"`
-	Side              string `json:"side"`
-	StopPrice         string `json:"stopPrice"`
-	IcebergQty        string `json:"icebergQty"`
-	Time              int64  `json:"time"`
-	UpdateTime        int64  `json:"updateTime"`
-	IsWorking         bool   `json:"isWorking"`
-	Fills             []OrderFill `json:"fills"`
-}
-
-// OrderFill 订单成交信息
-type OrderFill struct {
-	Price           string `json:"price"`
-	Qty             string `json:"qty"`
-	Commission      string `json:"commission"`
-	CommissionAsset string `json:"commissionAsset"`
-	TradeID         int64  `json:"tradeId"`
-}
-
-// GetExchangeInfo 获取交易所信息
-func (bc *BinanceClient) GetExchangeInfo() (*ExchangeInfo, error) {
-	path := "/api/v3/exchangeInfo"
-	resp, err := bc.request("GET", path, nil, false)
+		APIKey:      apiKey,
+		APISecret:   apiSecret,
+		BaseURL:     baseURL,
+		StreamURL:   streamURL,
+		IsTestnet:   isTestnet,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		RateLimiter: sharedBinanceLimiter(baseURL),
+		RecvWindow:  recvWindow,
+	}
+}
+
+// Name 交易所名称
+func (c *BinanceClient) Name() string {
+	return "binance"
+}
+
+// ===== Binance接口数据结构 =====
+
+// binanceSymbol 交易对信息 (exchangeInfo)
+type binanceSymbol struct {
+	Symbol     string          `json:"symbol"`
+	Status     string          `json:"status"`
+	BaseAsset  string          `json:"baseAsset"`
+	QuoteAsset string          `json:"quoteAsset"`
+	Filters    []binanceFilter `json:"filters"`
+}
+
+// binanceFilter 交易对过滤器
+type binanceFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize,omitempty"`
+	MinQty      string `json:"minQty,omitempty"`
+	MaxQty      string `json:"maxQty,omitempty"`
+	StepSize    string `json:"stepSize,omitempty"`
+	MinNotional string `json:"minNotional,omitempty"`
+}
+
+// binanceTicker 24小时行情
+type binanceTicker struct {
+	Symbol             string  `json:"symbol"`
+	BidPrice           float64 `json:"bidPrice,string"`
+	BidQty             float64 `json:"bidQty,string"`
+	AskPrice           float64 `json:"askPrice,string"`
+	AskQty             float64 `json:"askQty,string"`
+	LastPrice          float64 `json:"lastPrice,string"`
+	Volume             float64 `json:"volume,string"`
+	QuoteVolume        float64 `json:"quoteVolume,string"`
+	PriceChangePercent float64 `json:"priceChangePercent,string"`
+	CloseTime          int64   `json:"closeTime"`
+}
+
+// binanceBalance 账户余额
+type binanceBalance struct {
+	Asset  string  `json:"asset"`
+	Free   float64 `json:"free,string"`
+	Locked float64 `json:"locked,string"`
+}
+
+// binanceOrder 订单信息
+type binanceOrder struct {
+	Symbol              string        `json:"symbol"`
+	OrderID             int64         `json:"orderId"`
+	ClientOrderID       string        `json:"clientOrderId"`
+	Price               float64       `json:"price,string"`
+	OrigQty             float64       `json:"origQty,string"`
+	ExecutedQty         float64       `json:"executedQty,string"`
+	CummulativeQuoteQty float64       `json:"cummulativeQuoteQty,string"`
+	Status              string        `json:"status"`
+	TimeInForce         string        `json:"timeInForce"`
+	Type                string        `json:"type"`
+	Side                string        `json:"side"`
+	Time                int64         `json:"time"`
+	TransactTime        int64         `json:"transactTime"` // 下单和撤单接口返回
+	UpdateTime          int64         `json:"updateTime"`
+	Fills               []binanceFill `json:"fills"` // newOrderRespType=FULL 时下单接口返回
+}
+
+// binanceFill 下单时立即成交的明细
+type binanceFill struct {
+	Price           float64 `json:"price,string"`
+	Qty             float64 `json:"qty,string"`
+	Commission      float64 `json:"commission,string"`
+	CommissionAsset string  `json:"commissionAsset"`
+	TradeID         int64   `json:"tradeId"`
+}
+
+// toSymbolInfo 解析过滤器，转换为标准交易对信息
+func (s *binanceSymbol) toSymbolInfo() *SymbolInfo {
+	info := &SymbolInfo{
+		Symbol:     s.Symbol,
+		Status:     s.Status,
+		BaseAsset:  s.BaseAsset,
+		QuoteAsset: s.QuoteAsset,
+	}
+
+	for _, filter := range s.Filters {
+		switch filter.FilterType {
+		case "PRICE_FILTER":
+			info.TickSize = parseDecimal(filter.TickSize)
+		case "LOT_SIZE":
+			info.StepSize = parseDecimal(filter.StepSize)
+			info.MinQty = parseDecimal(filter.MinQty)
+			info.MaxQty = parseDecimal(filter.MaxQty)
+		case "MIN_NOTIONAL", "NOTIONAL":
+			info.MinNotional = parseDecimal(filter.MinNotional)
+		}
+	}
+
+	return info
+}
+
+// toTicker 转换为标准行情
+func (t *binanceTicker) toTicker() *Ticker {
+	ticker := &Ticker{
+		Symbol:             t.Symbol,
+		BidPrice:           t.BidPrice,
+		BidQty:             t.BidQty,
+		AskPrice:           t.AskPrice,
+		AskQty:             t.AskQty,
+		LastPrice:          t.LastPrice,
+		Volume:             t.Volume,
+		QuoteVolume:        t.QuoteVolume,
+		PriceChangePercent: t.PriceChangePercent,
+		UpdatedAt:          time.Now(),
+	}
+	if t.CloseTime > 0 {
+		ticker.UpdatedAt = time.UnixMilli(t.CloseTime)
+	}
+	return ticker
+}
+
+// toOrder 转换为标准订单
+func (o *binanceOrder) toOrder() *Order {
+	order := &Order{
+		Symbol:           o.Symbol,
+		OrderID:          strconv.FormatInt(o.OrderID, 10),
+		ClientOrderID:    o.ClientOrderID,
+		Side:             o.Side,
+		Type:             o.Type,
+		TimeInForce:      o.TimeInForce,
+		Price:            o.Price,
+		OrigQty:          o.OrigQty,
+		ExecutedQty:      o.ExecutedQty,
+		ExecutedQuoteQty: o.CummulativeQuoteQty,
+		Status:           o.Status,
+	}
+
+	created := o.Time
+	if created == 0 {
+		created = o.TransactTime
+	}
+	updated := o.UpdateTime
+	if updated == 0 {
+		updated = created
+	}
+	order.Time = time.UnixMilli(created)
+	order.UpdateTime = time.UnixMilli(updated)
+
+	for _, fill := range o.Fills {
+		order.Fills = append(order.Fills, &Execution{
+			Symbol:          o.Symbol,
+			OrderID:         order.OrderID,
+			ClientOrderID:   o.ClientOrderID,
+			TradeID:         strconv.FormatInt(fill.TradeID, 10),
+			Side:            o.Side,
+			Price:           fill.Price,
+			Quantity:        fill.Qty,
+			Commission:      fill.Commission,
+			CommissionAsset: fill.CommissionAsset,
+			Time:            order.Time,
+		})
+	}
+	return order
+}
+
+// ===== 行情 =====
+
+// GetSymbols 获取全部交易对信息
+func (c *BinanceClient) GetSymbols() ([]*SymbolInfo, error) {
+	body, err := c.doRequest("GET", "/api/v3/exchangeInfo", url.Values{}, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var info ExchangeInfo
-	if err := json.Unmarshal(resp, &info); err != nil {
-		return nil, fmt.Errorf("解析交易所信息失败: %v", err)
+	var info struct {
+		RateLimits []binanceRateLimit `json:"rateLimits"`
+		Symbols    []binanceSymbol    `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("解析交易所信息失败: %w", err)
+	}
+	if c.RateLimiter != nil {
+		c.RateLimiter.applyRateLimits(info.RateLimits)
 	}
 
-	return &info, nil
+	symbols := make([]*SymbolInfo, len(info.Symbols))
+	for i := range info.Symbols {
+		symbols[i] = info.Symbols[i].toSymbolInfo()
+	}
+	return symbols, nil
 }
 
-// GetTicker 获取行情数据
-func (bc *BinanceClient) GetTicker(symbol string) (*Ticker, error) {
-	path := "/api/v3/ticker/bookTicker"
+// GetSymbolInfo 获取交易对详细信息
+func (c *BinanceClient) GetSymbolInfo(symbol string) (*SymbolInfo, error) {
+	symbols, err := c.GetSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range symbols {
+		if s.Symbol == symbol {
+			return s, nil
+		}
+	}
+
+	return nil, fmt.Errorf("交易对 %s 不存在", symbol)
+}
+
+// GetTicker 获取交易对行情
+func (c *BinanceClient) GetTicker(symbol string) (*Ticker, error) {
 	params := url.Values{}
 	params.Add("symbol", symbol)
 
-	resp, err := bc.request("GET", path, params, false)
+	body, err := c.doRequest("GET", "/api/v3/ticker/24hr", params, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var ticker Ticker
-	if err := json.Unmarshal(resp, &ticker); err != nil {
-		return nil, fmt.Errorf("解析行情数据失败: %v", err)
+	var ticker binanceTicker
+	if err := json.Unmarshal(body, &ticker); err != nil {
+		return nil, fmt.Errorf("解析行情数据失败: %w", err)
 	}
 
-	return &ticker, nil
+	return ticker.toTicker(), nil
 }
 
-// GetAllTickers 获取所有行情数据
-func (bc *BinanceClient) GetAllTickers() ([]Ticker, error) {
-	path := "/api/v3/ticker/bookTicker"
-	resp, err := bc.request("GET", path, nil, false)
+// GetAllTickers 获取全部交易对行情
+func (c *BinanceClient) GetAllTickers() ([]*Ticker, error) {
+	body, err := c.doRequest("GET", "/api/v3/ticker/24hr", url.Values{}, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var tickers []Ticker
-	if err := json.Unmarshal(resp, &tickers); err != nil {
-		return nil, fmt.Errorf("解析行情数据失败: %v", err)
+	var raw []binanceTicker
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析行情数据失败: %w", err)
 	}
 
+	tickers := make([]*Ticker, len(raw))
+	for i := range raw {
+		tickers[i] = raw[i].toTicker()
+	}
 	return tickers, nil
 }
 
-// GetAccount 获取账户信息
-func (bc *BinanceClient) GetAccount() (*Account, error) {
-	path := "/api/v3/account"
-	resp, err := bc.request("GET", path, nil, true)
+// GetOrderBook 获取订单簿深度
+func (c *BinanceClient) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	params := url.Values{}
+	params.Add("symbol", symbol)
+	params.Add("limit", strconv.Itoa(limit))
+
+	body, err := c.doRequest("GET", "/api/v3/depth", params, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var account Account
-	if err := json.Unmarshal(resp, &account); err != nil {
-		return nil, fmt.Errorf("解析账户信息失败: %v", err)
+	var depth struct {
+		LastUpdateID int64      `json:"lastUpdateId"`
+		Bids         [][]string `json:"bids"`
+		Asks         [][]string `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &depth); err != nil {
+		return nil, fmt.Errorf("解析订单簿失败: %w", err)
 	}
 
-	return &account, nil
+	bids, err := parseDepthLevels(depth.Bids)
+	if err != nil {
+		return nil, fmt.Errorf("解析买单深度失败: %w", err)
+	}
+	asks, err := parseDepthLevels(depth.Asks)
+	if err != nil {
+		return nil, fmt.Errorf("解析卖单深度失败: %w", err)
+	}
+
+	return &OrderBook{
+		Symbol:       symbol,
+		LastUpdateID: depth.LastUpdateID,
+		Bids:         bids,
+		Asks:         asks,
+		UpdatedAt:    time.Now(),
+	}, nil
 }
 
-// PlaceOrder 下单
-func (bc *BinanceClient) PlaceOrder(symbol, side, orderType string, quantity, price float64) (*Order, error) {
-	path := "/api/v3/order"
+// ===== 账户 =====
+
+// GetBalances 获取全部资产余额
+func (c *BinanceClient) GetBalances() ([]*Balance, error) {
 	params := url.Values{}
-	params.Add("symbol", symbol)
-	params.Add("side", side)
-	params.Add("type", orderType)
-	params.Add("timeInForce", "GTC")
-	params.Add("quantity", fmt.Sprintf("%.8f", quantity))
-	
-	if price > 0 {
-		params.Add("price", fmt.Sprintf("%.8f", price))
+
+	body, err := c.doRequest("GET", "/api/v3/account", params, true)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := bc.request("POST", path, params, true)
+	var account struct {
+		Balances []binanceBalance `json:"balances"`
+	}
+	if err := json.Unmarshal(body, &account); err != nil {
+		return nil, fmt.Errorf("解析账户信息失败: %w", err)
+	}
+
+	balances := make([]*Balance, len(account.Balances))
+	for i, b := range account.Balances {
+		balances[i] = &Balance{Asset: b.Asset, Free: b.Free, Locked: b.Locked}
+	}
+	return balances, nil
+}
+
+// GetBalance 获取指定资产余额
+func (c *BinanceClient) GetBalance(asset string) (*Balance, error) {
+	balances, err := c.GetBalances()
 	if err != nil {
 		return nil, err
 	}
 
-	var order Order
-	if err := json.Unmarshal(resp, &order); err != nil {
-		return nil, fmt.Errorf("解析订单信息失败: %v", err)
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			return balance, nil
+		}
 	}
 
-	return &order, nil
+	return nil, fmt.Errorf("资产 %s 不存在", asset)
 }
 
-// CancelOrder 撤销订单
-func (bc *BinanceClient) CancelOrder(symbol string, orderID int64) (*Order, error) {
-	path := "/api/v3/order"
-	params := url.Values{}
-	params.Add("symbol", symbol)
-	params.Add("orderId", fmt.Sprintf("%d", orderID))
+// binanceCommissionRates 手续费率
+type binanceCommissionRates struct {
+	Maker float64 `json:"maker,string"`
+	Taker float64 `json:"taker,string"`
+}
 
-	resp, err := bc.request("DELETE", path, params, true)
+// GetTradeFees 获取全部交易对按VIP等级的手续费率
+// tradeFee 接口不包含BNB抵扣设置，抵扣后的费率由 GetTradeFee 逐个交易对查询
+func (c *BinanceClient) GetTradeFees() ([]*TradeFee, error) {
+	body, err := c.doRequest("GET", "/sapi/v1/asset/tradeFee", url.Values{}, true)
 	if err != nil {
 		return nil, err
 	}
 
-	var order Order
-	if err := json.Unmarshal(resp, &order); err != nil {
-		return nil, fmt.Errorf("解析订单信息失败: %v", err)
+	var items []struct {
+		Symbol string  `json:"symbol"`
+		Maker  float64 `json:"makerCommission,string"`
+		Taker  float64 `json:"takerCommission,string"`
+	}
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, fmt.Errorf("解析手续费率失败: %w", err)
 	}
 
-	return &order, nil
+	fees := make([]*TradeFee, len(items))
+	for i, item := range items {
+		fees[i] = &TradeFee{Symbol: item.Symbol, Maker: item.Maker, Taker: item.Taker}
+	}
+	return fees, nil
 }
 
-// GetOrder 查询订单
-func (bc *BinanceClient) GetOrder(symbol string, orderID int64) (*Order, error) {
-	path := "/api/v3/order"
+// GetTradeFee 获取单个交易对的手续费率和BNB抵扣设置
+// 费率为标准费率、税费和特殊费率之和，抵扣只作用于标准费率
+func (c *BinanceClient) GetTradeFee(symbol string) (*TradeFee, error) {
 	params := url.Values{}
 	params.Add("symbol", symbol)
-	params.Add("orderId", fmt.Sprintf("%d", orderID))
 
-	resp, err := bc.request("GET", path, params, true)
+	body, err := c.doRequest("GET", "/api/v3/account/commission", params, true)
 	if err != nil {
 		return nil, err
 	}
 
-	var order Order
-	if err := json.Unmarshal(resp, &order); err != nil {
-		return nil, fmt.Errorf("解析订单信息失败: %v", err)
+	var result struct {
+		Symbol             string                 `json:"symbol"`
+		StandardCommission binanceCommissionRates `json:"standardCommission"`
+		TaxCommission      binanceCommissionRates `json:"taxCommission"`
+		SpecialCommission  binanceCommissionRates `json:"specialCommission"`
+		Discount           struct {
+			EnabledForAccount bool    `json:"enabledForAccount"`
+			EnabledForSymbol  bool    `json:"enabledForSymbol"`
+			DiscountAsset     string  `json:"discountAsset"`
+			Discount          float64 `json:"discount,string"` // 使用抵扣资产支付时标准费率乘以该系数
+		} `json:"discount"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析手续费率失败: %w", err)
+	}
+
+	standard := result.StandardCommission
+	extraMaker := result.TaxCommission.Maker + result.SpecialCommission.Maker
+	extraTaker := result.TaxCommission.Taker + result.SpecialCommission.Taker
+
+	fee := &TradeFee{
+		Symbol: symbol,
+		Maker:  standard.Maker + extraMaker,
+		Taker:  standard.Taker + extraTaker,
+	}
+	discount := result.Discount
+	if discount.EnabledForAccount && discount.EnabledForSymbol && discount.DiscountAsset != "" {
+		fee.DiscountAsset = discount.DiscountAsset
+		fee.DiscountMaker = standard.Maker*discount.Discount + extraMaker
+		fee.DiscountTaker = standard.Taker*discount.Discount + extraTaker
 	}
+	return fee, nil
+}
 
-	return &order, nil
+// ===== 订单 =====
+
+// PlaceOrder 下单，限价单按 GTC 挂单
+func (c *BinanceClient) PlaceOrder(symbol, side, orderType string, quantity, price float64) (*Order, error) {
+	req := &OrderRequest{Symbol: symbol, Side: side, Type: orderType, Quantity: quantity}
+	if orderType == OrderTypeLimit {
+		req.Price = price
+	}
+	return c.SubmitOrder(req)
+}
+
+// SubmitOrder 按下单请求下单，响应类型为 FULL，立即成交的部分在 Order.Fills 中返回
+func (c *BinanceClient) SubmitOrder(req *OrderRequest) (*Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("symbol", req.Symbol)
+	params.Add("side", req.Side)
+	params.Add("type", req.Type)
+	switch req.Type {
+	case OrderTypeLimit:
+		params.Add("timeInForce", req.TimeInForceOrDefault())
+		params.Add("quantity", fmt.Sprintf("%.8f", req.Quantity))
+		params.Add("price", fmt.Sprintf("%.8f", req.Price))
+	case OrderTypeLimitMaker:
+		params.Add("quantity", fmt.Sprintf("%.8f", req.Quantity))
+		params.Add("price", fmt.Sprintf("%.8f", req.Price))
+	case OrderTypeMarket:
+		if req.QuoteQuantity > 0 {
+			params.Add("quoteOrderQty", fmt.Sprintf("%.8f", req.QuoteQuantity))
+		} else {
+			params.Add("quantity", fmt.Sprintf("%.8f", req.Quantity))
+		}
+	}
+
+	clientOrderID := req.ClientOrderID
+	if clientOrderID == "" {
+		clientOrderID = newClientOrderID()
+	}
+	params.Add("newClientOrderId", clientOrderID)
+	params.Add("newOrderRespType", "FULL")
+
+	return c.placeOrder(params)
+}
+
+// placeOrder 发送下单请求，发送结果未知（网络错误、超时、服务端错误）时按客户端订单ID重放：
+// 先查询上一次请求是否已被受理，已受理则返回该订单，确认不存在才用同一客户端订单ID重新下单，
+// 交易所拒绝重复的客户端订单ID，因此重放不会产生重复订单
+func (c *BinanceClient) placeOrder(params url.Values) (*Order, error) {
+	symbol, clientOrderID := params.Get("symbol"), params.Get("newClientOrderId")
+
+	order, err := c.orderRequest("POST", "/api/v3/order", params)
+	for attempt := 1; err != nil && attempt < binanceMaxAttempts && shouldRetry(err); attempt++ {
+		log.Printf("Binance下单 %s 结果未知: %v, %v 后确认订单状态", clientOrderID, err, retryDelay(attempt))
+		time.Sleep(retryDelay(attempt))
+
+		existing, queryErr := c.GetOrderByClientID(symbol, clientOrderID)
+		if queryErr == nil {
+			return existing, nil
+		}
+		if !IsOrderNotFound(queryErr) {
+			return nil, fmt.Errorf("%w (确认订单状态失败: %v)", err, queryErr)
+		}
+
+		order, err = c.orderRequest("POST", "/api/v3/order", params)
+	}
+	return order, err
+}
+
+// GetOrderByClientID 按客户端订单ID查询订单
+func (c *BinanceClient) GetOrderByClientID(symbol, clientOrderID string) (*Order, error) {
+	params := url.Values{}
+	params.Add("symbol", symbol)
+	params.Add("origClientOrderId", clientOrderID)
+
+	return c.orderRequest("GET", "/api/v3/order", params)
+}
+
+// CancelOrder 撤销订单
+func (c *BinanceClient) CancelOrder(symbol, orderID string) (*Order, error) {
+	params := url.Values{}
+	params.Add("symbol", symbol)
+	params.Add("orderId", orderID)
+
+	return c.orderRequest("DELETE", "/api/v3/order", params)
+}
+
+// GetOrder 查询订单
+func (c *BinanceClient) GetOrder(symbol, orderID string) (*Order, error) {
+	params := url.Values{}
+	params.Add("symbol", symbol)
+	params.Add("orderId", orderID)
+
+	return c.orderRequest("GET", "/api/v3/order", params)
 }
 
 // GetOpenOrders 获取未成交订单
-func (bc *BinanceClient) GetOpenOrders(symbol string) ([]Order, error) {
-	path := "/api/v3/openOrders"
+func (c *BinanceClient) GetOpenOrders(symbol string) ([]*Order, error) {
 	params := url.Values{}
 	if symbol != "" {
 		params.Add("symbol", symbol)
 	}
 
-	resp, err := bc.request("GET", path, params, true)
+	body, err := c.doRequest("GET", "/api/v3/openOrders", params, true)
 	if err != nil {
 		return nil, err
 	}
 
-	var orders []Order
-	if err := json.Unmarshal(resp, &orders); err != nil {
-		return nil, fmt.Errorf("解析订单列表失败: %v", err)
+	var raw []binanceOrder
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析订单列表失败: %w", err)
 	}
 
+	orders := make([]*Order, len(raw))
+	for i := range raw {
+		orders[i] = raw[i].toOrder()
+	}
 	return orders, nil
 }
 
-// request 发送HTTP请求
-func (bc *BinanceClient) request(method, path string, params url.Values, signed bool) ([]byte, error) {
-	if params == nil {
-		params = url.Values{}
+// orderRequest 发送订单请求并解析返回的订单
+func (c *BinanceClient) orderRequest(method, endpoint string, params url.Values) (*Order, error) {
+	body, err := c.doRequest(method, endpoint, params, true)
+	if err != nil {
+		return nil, err
 	}
 
-	// 添加时间戳
-	params.Add("timestamp", fmt.Sprintf("%d", time.Now().UnixMilli()))
+	var order binanceOrder
+	if err := json.Unmarshal(body, &order); err != nil {
+		return nil, fmt.Errorf("解析订单信息失败: %w", err)
+	}
 
-	// 签名
-	if signed {
-		signature := bc.sign(params.Encode())
-		params.Add("signature", signature)
+	return order.toOrder(), nil
+}
+
+// ===== 推送 =====
+
+// SubscribeTickers 订阅盘口行情流 (<symbol>@bookTicker)，symbols 为空时订阅全市场 !bookTicker
+func (c *BinanceClient) SubscribeTickers(symbols []string, callback func(*Ticker)) (Stream, error) {
+	streams := []string{"!bookTicker"}
+	if len(symbols) > 0 {
+		streams = make([]string, len(symbols))
+		for i, symbol := range symbols {
+			streams[i] = strings.ToLower(symbol) + "@bookTicker"
+		}
 	}
 
-	// 构建完整URL
-	fullURL := bc.baseURL + path
-	if method == "GET" || method == "DELETE" {
-		fullURL += "?" + params.Encode()
+	return NewBinanceStream(c.StreamURL, streams, func(stream string, data json.RawMessage) {
+		var event struct {
+			Symbol   string  `json:"s"`
+			BidPrice float64 `json:"b,string"`
+			BidQty   float64 `json:"B,string"`
+			AskPrice float64 `json:"a,string"`
+			AskQty   float64 `json:"A,string"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("解析行情推送失败 (%s): %v", stream, err)
+			return
+		}
+
+		callback(&Ticker{
+			Symbol:    event.Symbol,
+			BidPrice:  event.BidPrice,
+			BidQty:    event.BidQty,
+			AskPrice:  event.AskPrice,
+			AskQty:    event.AskQty,
+			UpdatedAt: time.Now(),
+		})
+	})
+}
+
+// SubscribeDepth 订阅深度增量流 (<symbol>@depth@100ms)
+func (c *BinanceClient) SubscribeDepth(symbols []string, callback func(*DepthUpdate)) (Stream, error) {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = strings.ToLower(symbol) + "@depth@100ms"
 	}
 
-	// 创建请求
-	req, err := http.NewRequest(method, fullURL, nil)
+	return NewBinanceStream(c.StreamURL, streams, func(stream string, data json.RawMessage) {
+		// 需要同时声明 "e"，否则大小写不敏感的解码会把事件类型写入 "E"
+		var event struct {
+			EventType     string     `json:"e"`
+			EventTime     int64      `json:"E"`
+			Symbol        string     `json:"s"`
+			FirstUpdateID int64      `json:"U"`
+			FinalUpdateID int64      `json:"u"`
+			Bids          [][]string `json:"b"`
+			Asks          [][]string `json:"a"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("解析深度推送失败 (%s): %v", stream, err)
+			return
+		}
+
+		bids, err := parseDepthLevels(event.Bids)
+		if err != nil {
+			log.Printf("解析深度推送失败 (%s): %v", stream, err)
+			return
+		}
+		asks, err := parseDepthLevels(event.Asks)
+		if err != nil {
+			log.Printf("解析深度推送失败 (%s): %v", stream, err)
+			return
+		}
+
+		callback(&DepthUpdate{
+			Symbol:        event.Symbol,
+			FirstUpdateID: event.FirstUpdateID,
+			FinalUpdateID: event.FinalUpdateID,
+			Bids:          bids,
+			Asks:          asks,
+			EventTime:     time.UnixMilli(event.EventTime),
+		})
+	})
+}
+
+// ===== 其他接口 =====
+
+// TestConnection 测试连接
+func (c *BinanceClient) TestConnection() error {
+	_, err := c.doRequest("GET", "/api/v3/ping", url.Values{}, false)
+	return err
+}
+
+// GetServerTime 获取服务器时间（毫秒）
+func (c *BinanceClient) GetServerTime() (int64, error) {
+	body, err := c.doRequest("GET", "/api/v3/time", url.Values{}, false)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return 0, err
+	}
+
+	var result struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, fmt.Errorf("解析时间失败: %w", err)
+	}
+
+	return result.ServerTime, nil
+}
+
+// RateLimitUsage 获取请求频率限额使用情况
+func (c *BinanceClient) RateLimitUsage() RateLimitUsage {
+	if c.RateLimiter == nil {
+		return RateLimitUsage{}
+	}
+	return c.RateLimiter.Usage()
+}
+
+// ===== 私有请求辅助方法 =====
+
+// doRequest 执行HTTP请求，失败时返回 *APIError 或网络错误
+// 签名请求因时间戳偏差 (-1021) 被拒绝时重新校准服务器时间并重试一次；
+// GET 请求是幂等的，遇到可重试的错误时按指数退避最多尝试 binanceMaxAttempts 次，其他请求不自动重试
+func (c *BinanceClient) doRequest(method string, endpoint string, params url.Values, signed bool) ([]byte, error) {
+	resynced := false
+	for attempt := 1; ; attempt++ {
+		body, err := c.send(method, endpoint, params, signed)
+		if err == nil {
+			return body, nil
+		}
+
+		var apiErr *APIError
+		if signed && !resynced && errors.As(err, &apiErr) && apiErr.Code == binanceErrInvalidTimestamp {
+			log.Printf("✗ Binance请求时间戳超出 recvWindow，重新校准服务器时间后重试")
+			if syncErr := c.SyncTime(); syncErr != nil {
+				log.Printf("%v", syncErr)
+				return nil, err
+			}
+			resynced = true
+			continue
+		}
+
+		if method != "GET" || attempt >= binanceMaxAttempts || !shouldRetry(err) {
+			return nil, err
+		}
+		log.Printf("Binance请求 %s 失败: %v, %v 后重试", endpoint, err, retryDelay(attempt))
+		time.Sleep(retryDelay(attempt))
+	}
+}
+
+// send 发送一次请求，返回响应内容和错误码
+// 发出前按接口权重占用限额，额度不足时排队或返回 ErrRateLimited；
+// 签名请求在占用限额后才生成时间戳，避免排队时间消耗 recvWindow
+func (c *BinanceClient) send(method string, endpoint string, params url.Values, signed bool) ([]byte, error) {
+	if signed {
+		c.prepareClock()
 	}
 
-	// 添加请求头
-	req.Header.Add("X-MBX-APIKEY", bc.apiKey)
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	if c.RateLimiter != nil {
+		isOrder := method == "POST" && endpoint == "/api/v3/order"
+		if err := c.RateLimiter.Acquire(binanceRequestWeight(method, endpoint, params), isOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	query := params.Encode()
+	if signed {
+		// 添加时间戳和签名，签名放在最后
+		signedParams := url.Values{}
+		for key, values := range params {
+			signedParams[key] = values
+		}
+		signedParams.Set("timestamp", strconv.FormatInt(c.timestamp(), 10))
+		if c.RecvWindow > 0 {
+			signedParams.Set("recvWindow", strconv.FormatInt(c.RecvWindow, 10))
+		}
+		query = signedParams.Encode()
+		query += "&signature=" + c.sign(query)
+	}
 
-	// 如果是POST/PUT，将参数放在body中
-	if method == "POST" || method == "PUT" {
-		req.Body = io.NopCloser(strings.NewReader(params.Encode()))
+	fullURL := c.BaseURL + endpoint
+	if query != "" {
+		fullURL += "?" + query
 	}
 
-	// 发送请求
-	resp, err := bc.httpClient.Do(req)
+	req, err := http.NewRequest(method, fullURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %v", err)
+		return nil, err
+	}
+
+	// 添加API密钥
+	req.Header.Add("X-MBX-APIKEY", c.APIKey)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应
+	if c.RateLimiter != nil {
+		c.RateLimiter.Update(resp.StatusCode, resp.Header)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
 	}
 
 	// 检查HTTP状态码
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API错误 (HTTP %d): %s", resp.StatusCode, string(body))
+		return nil, parseBinanceError(resp.StatusCode, body)
 	}
 
 	return body, nil
 }
 
-// sign 签名请求
-func (bc *BinanceClient) sign(message string) string {
-	h := hmac.New(sha256.New, []byte(bc.apiSecret))
-	h.Write([]byte(message))
-	return hex.EncodeToString(h.Sum(nil))
+// sign 对请求进行签名
+func (c *BinanceClient) sign(message string) string {
+	mac := hmac.New(sha256.New, []byte(c.APISecret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-// TestConnection 测试连接
-func (bc *BinanceClient) TestConnection() error {
-	path := "/api/v3/ping"
-	_, err := bc.request("GET", path, nil, false)
-	return err
+// ===== 辅助函数 =====
+
+// clientOrderSeq 客户端订单ID序号，避免同一纳秒内生成相同的ID
+var clientOrderSeq uint64
+
+// newClientOrderID 生成客户端订单ID，Binance要求不超过36个字符
+func newClientOrderID() string {
+	return fmt.Sprintf("inarbit_%x_%d", time.Now().UnixNano(), atomic.AddUint64(&clientOrderSeq, 1)%1000)
 }
 
-// GetServerTime 获取服务器时间
-func (bc *BinanceClient) GetServerTime() (int64, error) {
-	path := "/api/v3/time"
-	resp, err := bc.request("GET", path, nil, false)
-	if err != nil {
-		return 0, err
+// parseDepthLevels 解析 [价格, 数量] 格式的深度档位
+func parseDepthLevels(raw [][]string) ([]OrderBookLevel, error) {
+	levels := make([]OrderBookLevel, 0, len(raw))
+	for _, entry := range raw {
+		if len(entry) < 2 {
+			continue
+		}
+		price, err := strconv.ParseFloat(entry[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		qty, err := strconv.ParseFloat(entry[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, OrderBookLevel{Price: price, Quantity: qty})
 	}
+	return levels, nil
+}
 
-	var result map[string]int64
-	if err := json.Unmarshal(resp, &result); err != nil {
-		return 0, fmt.Errorf("解析时间失败: %v", err)
+// parseDecimal 解析数字字符串，空字符串或格式错误时返回0
+func parseDecimal(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
 	}
-
-	return result["serverTime"], nil
+	return v
 }
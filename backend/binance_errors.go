@@ -0,0 +1,141 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrorClass 错误的处理方式
+type ErrorClass int
+
+const (
+	ErrorFatal     ErrorClass = iota // 请求本身有误或账户状态不允许，重试无效
+	ErrorRetryable                   // 临时错误，退避后可重试
+	ErrorResync                      // 本地状态（服务器时间、交易规则）与交易所不一致，重新同步后可重试
+)
+
+// String 错误类别名称
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorRetryable:
+		return "retryable"
+	case ErrorResync:
+		return "resync"
+	default:
+		return "fatal"
+	}
+}
+
+// Binance错误码，参考 https://developers.binance.com/docs/binance-spot-api-docs/errors
+const (
+	binanceErrUnknown          = -1000
+	binanceErrDisconnected     = -1001
+	binanceErrTooManyRequests  = -1003
+	binanceErrServerBusy       = -1004
+	binanceErrUnexpectedResp   = -1006
+	binanceErrTimeout          = -1007 // 发送结果未知，订单可能已被受理
+	binanceErrSystemThrottled  = -1008
+	binanceErrFilterFailure    = -1013 // 不满足交易规则（价格、数量步长等）
+	binanceErrTooManyOrders    = -1015
+	binanceErrInvalidTimestamp = -1021
+	binanceErrNoSuchOrder      = -2013
+)
+
+// binanceErrorClasses 非致命错误码的类别，其余错误码均为致命错误
+var binanceErrorClasses = map[int]ErrorClass{
+	binanceErrUnknown:          ErrorRetryable,
+	binanceErrDisconnected:     ErrorRetryable,
+	binanceErrTooManyRequests:  ErrorRetryable,
+	binanceErrServerBusy:       ErrorRetryable,
+	binanceErrUnexpectedResp:   ErrorRetryable,
+	binanceErrTimeout:          ErrorRetryable,
+	binanceErrSystemThrottled:  ErrorRetryable,
+	binanceErrTooManyOrders:    ErrorRetryable,
+	binanceErrFilterFailure:    ErrorResync,
+	binanceErrInvalidTimestamp: ErrorResync,
+}
+
+// binanceMaxAttempts 可重试请求的最大尝试次数
+const binanceMaxAttempts = 3
+
+// binanceRetryDelay 首次重试前的等待时间，之后每次翻倍
+const binanceRetryDelay = 200 * time.Millisecond
+
+// APIError 交易所返回的错误
+type APIError struct {
+	Exchange   string
+	HTTPStatus int
+	Code       int    // 交易所错误码，响应不是 {code,msg} 格式时为0
+	Message    string // 交易所返回的错误信息，无法解析时为原始响应
+}
+
+// Error 错误描述
+func (e *APIError) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("API错误 (HTTP %d): %s", e.HTTPStatus, e.Message)
+	}
+	return fmt.Sprintf("API错误 (HTTP %d, 错误码 %d): %s", e.HTTPStatus, e.Code, e.Message)
+}
+
+// Class 错误类别，错误码未知时按HTTP状态码判断：限流和服务端错误可重试，其余为致命错误
+func (e *APIError) Class() ErrorClass {
+	if class, ok := binanceErrorClasses[e.Code]; ok {
+		return class
+	}
+	if e.Code == 0 && (e.HTTPStatus >= http.StatusInternalServerError || e.HTTPStatus == http.StatusTooManyRequests || e.HTTPStatus == http.StatusTeapot) {
+		return ErrorRetryable
+	}
+	return ErrorFatal
+}
+
+// ClassifyError 获取错误类别
+// 网络错误和本地限流可重试，交易所返回的错误按错误码分类，其余错误均视为致命错误
+func ClassifyError(err error) ErrorClass {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Class()
+	}
+	var netErr net.Error
+	if errors.Is(err, ErrRateLimited) || errors.As(err, &netErr) {
+		return ErrorRetryable
+	}
+	return ErrorFatal
+}
+
+// IsOrderNotFound 检查错误是否为订单不存在
+func IsOrderNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.Is(err, ErrOrderNotFound) || errors.As(err, &apiErr) && apiErr.Code == binanceErrNoSuchOrder
+}
+
+// shouldRetry 检查请求是否应由客户端自动重试
+// 本地限流说明额度短时间内不会恢复，不在客户端内重试
+func shouldRetry(err error) bool {
+	return ClassifyError(err) == ErrorRetryable && !errors.Is(err, ErrRateLimited)
+}
+
+// retryDelay 第 attempt 次重试前的等待时间
+func retryDelay(attempt int) time.Duration {
+	return binanceRetryDelay << (attempt - 1)
+}
+
+// parseBinanceError 将非200响应解析为 APIError
+func parseBinanceError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{Exchange: "binance", HTTPStatus: statusCode}
+
+	var resp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Code != 0 {
+		apiErr.Code, apiErr.Message = resp.Code, resp.Msg
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
@@ -0,0 +1,268 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Binance现货默认限额，exchangeInfo 返回的 rateLimits 会覆盖
+const (
+	binanceWeightLimit    = 6000   // 每分钟请求权重
+	binanceOrderLimit10s  = 100    // 每10秒下单数
+	binanceOrderLimitDay  = 200000 // 每天下单数
+	binanceRateLimitUsage = 0.9    // 使用量达到限额的该比例后不再放行，为其他进程和推送重连留出余量
+)
+
+// binanceRateLimitMaxWait 请求需要排队等待的最长时间，超过则直接拒绝
+const binanceRateLimitMaxWait = 5 * time.Second
+
+// ErrRateLimited 请求频率即将或已经超过交易所限额
+var ErrRateLimited = errors.New("请求频率超限")
+
+// RateLimiter Binance请求频率限制器
+// 按权重（每分钟）和下单数（每10秒、每天）计数，窗口与交易所一样按自然时间对齐；
+// 计数在发出请求前预先累加，收到响应后以 X-MBX-USED-WEIGHT-* 和 X-MBX-ORDER-COUNT-* 头校正。
+// 收到 429/418 后在 Retry-After 之前暂停全部请求。同一 IP 的客户端应共享一个限制器
+type RateLimiter struct {
+	mu            sync.Mutex
+	weightLimit   int
+	orderLimit10s int
+	orderLimitDay int
+	maxWait       time.Duration
+
+	weight          int
+	weightWindow    time.Time
+	orders10s       int
+	orders10sWindow time.Time
+	ordersDay       int
+	ordersDayWindow time.Time
+	blockedUntil    time.Time
+}
+
+// NewRateLimiter 创建频率限制器，maxWait 为请求排队等待的最长时间
+func NewRateLimiter(maxWait time.Duration) *RateLimiter {
+	return &RateLimiter{
+		weightLimit:   binanceWeightLimit,
+		orderLimit10s: binanceOrderLimit10s,
+		orderLimitDay: binanceOrderLimitDay,
+		maxWait:       maxWait,
+	}
+}
+
+var (
+	binanceLimitersMu sync.Mutex
+	binanceLimiters   = make(map[string]*RateLimiter)
+)
+
+// sharedBinanceLimiter 获取同一接口地址共享的频率限制器
+func sharedBinanceLimiter(baseURL string) *RateLimiter {
+	binanceLimitersMu.Lock()
+	defer binanceLimitersMu.Unlock()
+
+	limiter, ok := binanceLimiters[baseURL]
+	if !ok {
+		limiter = NewRateLimiter(binanceRateLimitMaxWait)
+		binanceLimiters[baseURL] = limiter
+	}
+	return limiter
+}
+
+// SetLimits 设置限额，参数为0时保持原值
+func (l *RateLimiter) SetLimits(weightPerMinute, orders10s, ordersDay int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if weightPerMinute > 0 {
+		l.weightLimit = weightPerMinute
+	}
+	if orders10s > 0 {
+		l.orderLimit10s = orders10s
+	}
+	if ordersDay > 0 {
+		l.orderLimitDay = ordersDay
+	}
+}
+
+// Acquire 在发出请求前登记权重，isOrder 为 true 时同时登记下单数
+// 额度不足时排队等待窗口重置，需等待的时间超过 maxWait 时返回 ErrRateLimited
+func (l *RateLimiter) Acquire(weight int, isOrder bool) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.rollWindows(now)
+		wait := l.waitTime(now, weight, isOrder)
+		if wait <= 0 {
+			l.weight += weight
+			if isOrder {
+				l.orders10s++
+				l.ordersDay++
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if wait > l.maxWait {
+			return fmt.Errorf("%w，需等待 %v", ErrRateLimited, wait.Round(time.Second))
+		}
+		time.Sleep(wait)
+	}
+}
+
+// Update 根据响应头校正已用额度，并在收到 429/418 时按 Retry-After 暂停请求
+func (l *RateLimiter) Update(statusCode int, header http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.rollWindows(now)
+
+	// 服务端计数包含同一IP的其他进程，本地计数包含尚未返回的请求，取较大值
+	if used, err := strconv.Atoi(header.Get("X-MBX-USED-WEIGHT-1M")); err == nil && used > l.weight {
+		l.weight = used
+	}
+	if count, err := strconv.Atoi(header.Get("X-MBX-ORDER-COUNT-10S")); err == nil && count > l.orders10s {
+		l.orders10s = count
+	}
+	if count, err := strconv.Atoi(header.Get("X-MBX-ORDER-COUNT-1D")); err == nil && count > l.ordersDay {
+		l.ordersDay = count
+	}
+
+	if statusCode != http.StatusTooManyRequests && statusCode != http.StatusTeapot {
+		return
+	}
+
+	// 未返回 Retry-After 时暂停到下一分钟
+	until := l.weightWindow.Add(time.Minute)
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		until = now.Add(time.Duration(seconds) * time.Second)
+	}
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+	log.Printf("✗ Binance请求频率超限 (HTTP %d)，暂停请求至 %s", statusCode, l.blockedUntil.Format("15:04:05"))
+}
+
+// Usage 获取当前额度使用情况
+func (l *RateLimiter) Usage() RateLimitUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.rollWindows(now)
+
+	usage := RateLimitUsage{
+		Weight:        l.weight,
+		WeightLimit:   l.weightLimit,
+		Orders10s:     l.orders10s,
+		OrderLimit10s: l.orderLimit10s,
+		OrdersDay:     l.ordersDay,
+		OrderLimitDay: l.orderLimitDay,
+	}
+	if now.Before(l.blockedUntil) {
+		usage.BlockedUntil = l.blockedUntil
+	}
+	return usage
+}
+
+// rollWindows 进入新的计数窗口时清零对应计数
+func (l *RateLimiter) rollWindows(now time.Time) {
+	if window := now.Truncate(time.Minute); !window.Equal(l.weightWindow) {
+		l.weightWindow, l.weight = window, 0
+	}
+	if window := now.Truncate(10 * time.Second); !window.Equal(l.orders10sWindow) {
+		l.orders10sWindow, l.orders10s = window, 0
+	}
+	if window := now.Truncate(24 * time.Hour); !window.Equal(l.ordersDayWindow) {
+		l.ordersDayWindow, l.ordersDay = window, 0
+	}
+}
+
+// waitTime 计算请求需要等待的时间，0 表示可以立即发出
+func (l *RateLimiter) waitTime(now time.Time, weight int, isOrder bool) time.Duration {
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+	if float64(l.weight+weight) > float64(l.weightLimit)*binanceRateLimitUsage {
+		return l.weightWindow.Add(time.Minute).Sub(now)
+	}
+	if isOrder {
+		if float64(l.orders10s+1) > float64(l.orderLimit10s)*binanceRateLimitUsage {
+			return l.orders10sWindow.Add(10 * time.Second).Sub(now)
+		}
+		if l.ordersDay+1 > l.orderLimitDay {
+			return l.ordersDayWindow.Add(24 * time.Hour).Sub(now)
+		}
+	}
+	return 0
+}
+
+// binanceRequestWeight 获取接口的请求权重
+// 参考 https://developers.binance.com/docs/binance-spot-api-docs/rest-api
+func binanceRequestWeight(method, endpoint string, params url.Values) int {
+	switch endpoint {
+	case "/api/v3/exchangeInfo", "/api/v3/account", "/api/v3/account/commission":
+		return 20
+	case "/api/v3/ticker/24hr":
+		if params.Get("symbol") != "" {
+			return 2
+		}
+		return 80
+	case "/api/v3/depth":
+		limit, _ := strconv.Atoi(params.Get("limit"))
+		switch {
+		case limit > 1000:
+			return 250
+		case limit > 500:
+			return 50
+		case limit > 100:
+			return 25
+		default:
+			return 5
+		}
+	case "/api/v3/order":
+		if method == "GET" {
+			return 4
+		}
+		return 1
+	case "/api/v3/userDataStream":
+		return 2
+	case "/api/v3/openOrders":
+		if params.Get("symbol") != "" {
+			return 6
+		}
+		return 80
+	default:
+		return 1
+	}
+}
+
+// binanceRateLimit exchangeInfo 中的限额定义
+type binanceRateLimit struct {
+	RateLimitType string `json:"rateLimitType"` // REQUEST_WEIGHT, ORDERS, RAW_REQUESTS
+	Interval      string `json:"interval"`      // SECOND, MINUTE, DAY
+	IntervalNum   int    `json:"intervalNum"`
+	Limit         int    `json:"limit"`
+}
+
+// applyRateLimits 使用 exchangeInfo 返回的限额
+func (l *RateLimiter) applyRateLimits(limits []binanceRateLimit) {
+	var weightPerMinute, orders10s, ordersDay int
+	for _, limit := range limits {
+		switch {
+		case limit.RateLimitType == "REQUEST_WEIGHT" && limit.Interval == "MINUTE" && limit.IntervalNum == 1:
+			weightPerMinute = limit.Limit
+		case limit.RateLimitType == "ORDERS" && limit.Interval == "SECOND" && limit.IntervalNum == 10:
+			orders10s = limit.Limit
+		case limit.RateLimitType == "ORDERS" && limit.Interval == "DAY" && limit.IntervalNum == 1:
+			ordersDay = limit.Limit
+		}
+	}
+	l.SetLimits(weightPerMinute, orders10s, ordersDay)
+}
@@ -0,0 +1,199 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamMaxStreams       = 1024             // 单个连接最多订阅的流数量
+	streamMaxConnectionAge = 23 * time.Hour   // Binance 在24小时后断开连接，提前主动切换
+	streamReadTimeout      = 1 * time.Minute  // 服务器每20秒发送ping，超时未收到任何数据视为断线
+	streamWriteTimeout     = 10 * time.Second // 控制帧写入超时
+	streamMinBackoff       = 1 * time.Second  // 重连最小等待时间
+	streamMaxBackoff       = 30 * time.Second // 重连最大等待时间
+)
+
+// BinanceStream Binance WebSocket组合流连接
+// 断线后按指数退避自动重连，连接满23小时时先建立新连接再关闭旧连接
+type BinanceStream struct {
+	url           string
+	handler       func(stream string, data json.RawMessage)
+	onStateChange func(connected bool) // 连接状态变化回调
+
+	mu        sync.RWMutex
+	connected bool
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ Stream = (*BinanceStream)(nil)
+
+// NewBinanceStream 创建组合流连接，调用 Start 后开始接收
+func NewBinanceStream(baseURL string, streams []string, handler func(stream string, data json.RawMessage)) (*BinanceStream, error) {
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("未指定订阅的行情流")
+	}
+	if len(streams) > streamMaxStreams {
+		return nil, fmt.Errorf("单个连接最多订阅 %d 个行情流，当前 %d 个", streamMaxStreams, len(streams))
+	}
+
+	return &BinanceStream{
+		url:      baseURL + "/stream?streams=" + strings.Join(streams, "/"),
+		handler:  handler,
+		stopChan: make(chan struct{}),
+	}, nil
+}
+
+// SetStateHandler 设置连接状态变化回调，需在 Start 之前调用
+func (s *BinanceStream) SetStateHandler(handler func(connected bool)) {
+	s.onStateChange = handler
+}
+
+// Start 启动连接
+func (s *BinanceStream) Start() {
+	go s.run()
+}
+
+// Stop 关闭连接并停止重连
+func (s *BinanceStream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// IsConnected 检查连接是否可用
+func (s *BinanceStream) IsConnected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// setConnected 更新连接状态并通知回调
+func (s *BinanceStream) setConnected(connected bool) {
+	s.mu.Lock()
+	changed := s.connected != connected
+	s.connected = connected
+	s.mu.Unlock()
+
+	if changed && s.onStateChange != nil {
+		s.onStateChange(connected)
+	}
+}
+
+// run 维护连接的主循环
+func (s *BinanceStream) run() {
+	defer s.setConnected(false)
+
+	var previous *websocket.Conn
+	backoff := streamMinBackoff
+
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+
+		// 到期切换时，新连接建立后再关闭旧连接，避免出现推送空窗
+		if previous != nil {
+			previous.Close()
+			previous = nil
+		}
+
+		if err != nil {
+			s.setConnected(false)
+			log.Printf("行情流连接失败: %v, %v 后重试", err, backoff)
+
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+			continue
+		}
+
+		backoff = streamMinBackoff
+		s.setConnected(true)
+
+		rollover, err := s.serve(conn)
+		if rollover {
+			log.Println("行情流连接即将到期，切换到新连接")
+			previous = conn
+			continue
+		}
+		conn.Close()
+
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		s.setConnected(false)
+		log.Printf("行情流断开: %v, 正在重连", err)
+	}
+}
+
+// serve 处理单个连接，连接出错、到期或停止时返回
+func (s *BinanceStream) serve(conn *websocket.Conn) (rollover bool, err error) {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.readLoop(conn)
+	}()
+
+	timer := time.NewTimer(streamMaxConnectionAge)
+	defer timer.Stop()
+
+	select {
+	case <-s.stopChan:
+		conn.WriteControl(
+			websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(streamWriteTimeout),
+		)
+		return false, nil
+	case err := <-errChan:
+		return false, err
+	case <-timer.C:
+		return true, nil
+	}
+}
+
+// readLoop 读取消息并分发，收到服务器ping时回复pong
+func (s *BinanceStream) readLoop(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(streamWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+
+		// 组合流消息格式: {"stream":"btcusdt@bookTicker","data":{...}}
+		var envelope struct {
+			Stream string          `json:"stream"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &envelope); err != nil || len(envelope.Data) == 0 {
+			continue
+		}
+		s.handler(envelope.Stream, envelope.Data)
+	}
+}
@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// binanceTimeSyncInterval 定期校准服务器时间的间隔
+const binanceTimeSyncInterval = 10 * time.Minute
+
+const (
+	BinanceDefaultRecvWindow = 5000  // 签名请求默认的有效时间窗口（毫秒）
+	BinanceMaxRecvWindow     = 60000 // Binance允许的最大有效时间窗口（毫秒）
+)
+
+// serverClock 本地时钟相对服务器时钟的偏差
+type serverClock struct {
+	mu          sync.Mutex
+	offset      time.Duration // 服务器时间减去本地时间
+	latency     time.Duration // 最近一次校准的往返延迟
+	syncedAt    time.Time     // 最近一次校准成功的时间
+	attemptedAt time.Time     // 最近一次尝试校准的时间，校准失败时避免每个请求都重试
+	syncing     bool
+}
+
+// SyncTime 通过 GetServerTime 测量本地时钟偏差和往返延迟
+// 假定请求往返对称，服务器时间对应往返的中点
+func (c *BinanceClient) SyncTime() error {
+	c.clock.mu.Lock()
+	c.clock.attemptedAt = time.Now()
+	c.clock.mu.Unlock()
+
+	sent := time.Now()
+	serverTime, err := c.GetServerTime()
+	if err != nil {
+		return fmt.Errorf("校准服务器时间失败: %w", err)
+	}
+	received := time.Now()
+	if serverTime <= 0 {
+		return fmt.Errorf("校准服务器时间失败: 无效的服务器时间 %d", serverTime)
+	}
+
+	latency := received.Sub(sent)
+	offset := time.UnixMilli(serverTime).Sub(sent.Add(latency / 2))
+
+	c.clock.mu.Lock()
+	c.clock.offset = offset
+	c.clock.latency = latency
+	c.clock.syncedAt = received
+	c.clock.mu.Unlock()
+
+	log.Printf("✓ Binance服务器时间已校准: 偏差 %v, 往返延迟 %v", offset.Round(time.Millisecond), latency.Round(time.Millisecond))
+	return nil
+}
+
+// TimeOffset 获取最近一次校准的时钟偏差（服务器时间减去本地时间）和往返延迟
+func (c *BinanceClient) TimeOffset() (offset, latency time.Duration) {
+	c.clock.mu.Lock()
+	defer c.clock.mu.Unlock()
+	return c.clock.offset, c.clock.latency
+}
+
+// prepareClock 在签名请求前确保时钟偏差可用
+// 首次请求时同步校准，之后每隔 binanceTimeSyncInterval 在后台重新校准
+func (c *BinanceClient) prepareClock() {
+	c.clock.mu.Lock()
+	if c.clock.syncing || time.Since(c.clock.attemptedAt) < binanceTimeSyncInterval {
+		c.clock.mu.Unlock()
+		return
+	}
+	first := c.clock.attemptedAt.IsZero()
+	c.clock.syncing = true
+	c.clock.mu.Unlock()
+
+	resync := func() {
+		if err := c.SyncTime(); err != nil {
+			log.Printf("%v", err)
+		}
+		c.clock.mu.Lock()
+		c.clock.syncing = false
+		c.clock.mu.Unlock()
+	}
+
+	if first {
+		resync()
+	} else {
+		go resync()
+	}
+}
+
+// timestamp 按服务器时间生成签名请求的时间戳（毫秒）
+func (c *BinanceClient) timestamp() int64 {
+	c.clock.mu.Lock()
+	offset := c.clock.offset
+	c.clock.mu.Unlock()
+	return time.Now().Add(offset).UnixMilli()
+}
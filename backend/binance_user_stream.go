@@ -0,0 +1,340 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// userStreamKeepAlive listenKey 60分钟后失效，每30分钟延期一次
+const userStreamKeepAlive = 30 * time.Minute
+
+// BinanceUserStream Binance用户数据流连接
+// 每次连接前获取 listenKey，连接期间定期延期；延期失败或收到 listenKeyExpired 时重新获取并重连
+type BinanceUserStream struct {
+	client        *BinanceClient
+	handler       UserDataHandler
+	onStateChange func(connected bool)
+
+	mu        sync.RWMutex
+	connected bool
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ Stream = (*BinanceUserStream)(nil)
+
+// SubscribeUserData 订阅用户数据流，推送订单状态 (executionReport) 和余额变化 (outboundAccountPosition)
+func (c *BinanceClient) SubscribeUserData(handler UserDataHandler) (Stream, error) {
+	if c.APIKey == "" {
+		return nil, fmt.Errorf("用户数据流需要API密钥")
+	}
+
+	return &BinanceUserStream{
+		client:   c,
+		handler:  handler,
+		stopChan: make(chan struct{}),
+	}, nil
+}
+
+// SubscribeOrders 订阅订单状态变化推送
+func (c *BinanceClient) SubscribeOrders(callback func(*Order)) (Stream, error) {
+	return c.SubscribeUserData(UserDataHandler{OnOrder: callback})
+}
+
+// createListenKey 创建用户数据流的 listenKey
+func (c *BinanceClient) createListenKey() (string, error) {
+	body, err := c.doRequest("POST", "/api/v3/userDataStream", url.Values{}, false)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.ListenKey == "" {
+		return "", fmt.Errorf("解析listenKey失败: %s", string(body))
+	}
+	return result.ListenKey, nil
+}
+
+// listenKeyRequest 延期 (PUT) 或关闭 (DELETE) listenKey
+func (c *BinanceClient) listenKeyRequest(method, listenKey string) error {
+	params := url.Values{}
+	params.Add("listenKey", listenKey)
+
+	_, err := c.doRequest(method, "/api/v3/userDataStream", params, false)
+	return err
+}
+
+// SetStateHandler 设置连接状态变化回调，需在 Start 之前调用
+func (s *BinanceUserStream) SetStateHandler(handler func(connected bool)) {
+	s.onStateChange = handler
+}
+
+// Start 启动连接
+func (s *BinanceUserStream) Start() {
+	go s.run()
+}
+
+// Stop 关闭连接并停止重连
+func (s *BinanceUserStream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// IsConnected 检查连接是否可用
+func (s *BinanceUserStream) IsConnected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// setConnected 更新连接状态并通知回调
+func (s *BinanceUserStream) setConnected(connected bool) {
+	s.mu.Lock()
+	changed := s.connected != connected
+	s.connected = connected
+	s.mu.Unlock()
+
+	if changed && s.onStateChange != nil {
+		s.onStateChange(connected)
+	}
+}
+
+// run 维护连接的主循环
+func (s *BinanceUserStream) run() {
+	defer s.setConnected(false)
+
+	backoff := streamMinBackoff
+	for {
+		listenKey, err := s.client.createListenKey()
+		var conn *websocket.Conn
+		if err == nil {
+			conn, _, err = websocket.DefaultDialer.Dial(s.client.StreamURL+"/ws/"+listenKey, nil)
+		}
+
+		if err != nil {
+			s.setConnected(false)
+			log.Printf("用户数据流连接失败: %v, %v 后重试", err, backoff)
+
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+			continue
+		}
+
+		backoff = streamMinBackoff
+		s.setConnected(true)
+
+		err = s.serve(conn, listenKey)
+		conn.Close()
+
+		select {
+		case <-s.stopChan:
+			if err := s.client.listenKeyRequest("DELETE", listenKey); err != nil {
+				log.Printf("关闭listenKey失败: %v", err)
+			}
+			return
+		default:
+		}
+
+		s.setConnected(false)
+		log.Printf("用户数据流断开: %v, 正在重连", err)
+	}
+}
+
+// serve 处理单个连接并定期延期 listenKey，连接出错、延期失败或停止时返回
+func (s *BinanceUserStream) serve(conn *websocket.Conn, listenKey string) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.readLoop(conn)
+	}()
+
+	keepAlive := time.NewTicker(userStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(streamWriteTimeout),
+			)
+			return nil
+		case err := <-errChan:
+			return err
+		case <-keepAlive.C:
+			if err := s.client.listenKeyRequest("PUT", listenKey); err != nil {
+				return fmt.Errorf("延期listenKey失败: %w", err)
+			}
+		}
+	}
+}
+
+// readLoop 读取事件并分发，收到服务器ping时回复pong
+func (s *BinanceUserStream) readLoop(conn *websocket.Conn) error {
+	conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(streamWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+
+		var event struct {
+			EventType string `json:"e"`
+			EventTime int64  `json:"E"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			continue
+		}
+
+		switch event.EventType {
+		case "executionReport":
+			s.handleExecutionReport(message)
+		case "outboundAccountPosition":
+			s.handleAccountPosition(message)
+		case "listenKeyExpired":
+			return fmt.Errorf("listenKey已失效")
+		}
+	}
+}
+
+// binanceExecutionReport 订单更新事件
+// 大小写不敏感的解码会把未声明的大写字段写入同名的小写字段，因此成对声明 p/P、q/Q、f/F 等字段
+type binanceExecutionReport struct {
+	EventType             string  `json:"e"`
+	EventTime             int64   `json:"E"`
+	Symbol                string  `json:"s"`
+	ClientOrderID         string  `json:"c"` // 撤单事件中为撤单请求的ID
+	OrigClientOrderID     string  `json:"C"` // 撤单事件中为原订单的客户端ID
+	Side                  string  `json:"S"`
+	OrderType             string  `json:"o"`
+	OrderCreationTime     int64   `json:"O"`
+	TimeInForce           string  `json:"f"`
+	IcebergQty            string  `json:"F"`
+	Quantity              float64 `json:"q,string"`
+	QuoteOrderQty         string  `json:"Q"`
+	Price                 float64 `json:"p,string"`
+	StopPrice             string  `json:"P"`
+	ExecutionType         string  `json:"x"` // NEW, CANCELED, REJECTED, TRADE, EXPIRED
+	Status                string  `json:"X"`
+	OrderID               int64   `json:"i"`
+	Ignore                int64   `json:"I"`
+	LastExecutedQty       float64 `json:"l,string"`
+	LastExecutedPrice     float64 `json:"L,string"`
+	Commission            float64 `json:"n,string"`
+	CommissionAsset       string  `json:"N"`
+	TradeID               int64   `json:"t"`
+	TransactionTime       int64   `json:"T"`
+	IsMaker               bool    `json:"m"`
+	IgnoreM               bool    `json:"M"`
+	CumulativeQty         float64 `json:"z,string"`
+	CumulativeQuoteQty    float64 `json:"Z,string"`
+	WorkingTime           int64   `json:"W"`
+	IsWorking             bool    `json:"w"`
+	LastQuoteQty          string  `json:"Y"`
+	PreventedMatchID      int64   `json:"v"`
+	SelfTradePreventionMd string  `json:"V"`
+}
+
+// handleExecutionReport 转换订单更新事件，成交事件同时推送成交明细
+func (s *BinanceUserStream) handleExecutionReport(message []byte) {
+	var report binanceExecutionReport
+	if err := json.Unmarshal(message, &report); err != nil {
+		log.Printf("解析订单推送失败: %v", err)
+		return
+	}
+
+	clientOrderID := report.ClientOrderID
+	if report.OrigClientOrderID != "" {
+		clientOrderID = report.OrigClientOrderID
+	}
+	orderID := fmt.Sprint(report.OrderID)
+
+	// 先推送成交明细，等待订单结束的调用方收到最终状态时已能取到全部明细
+	if report.ExecutionType == "TRADE" && s.handler.OnExecution != nil {
+		s.handler.OnExecution(&Execution{
+			Symbol:          report.Symbol,
+			OrderID:         orderID,
+			ClientOrderID:   clientOrderID,
+			TradeID:         fmt.Sprint(report.TradeID),
+			Side:            report.Side,
+			Price:           report.LastExecutedPrice,
+			Quantity:        report.LastExecutedQty,
+			Commission:      report.Commission,
+			CommissionAsset: report.CommissionAsset,
+			IsMaker:         report.IsMaker,
+			Time:            time.UnixMilli(report.TransactionTime),
+		})
+	}
+
+	if s.handler.OnOrder != nil {
+		s.handler.OnOrder(&Order{
+			Symbol:           report.Symbol,
+			OrderID:          orderID,
+			ClientOrderID:    clientOrderID,
+			Side:             report.Side,
+			Type:             report.OrderType,
+			TimeInForce:      report.TimeInForce,
+			Price:            report.Price,
+			OrigQty:          report.Quantity,
+			ExecutedQty:      report.CumulativeQty,
+			ExecutedQuoteQty: report.CumulativeQuoteQty,
+			Status:           report.Status,
+			Time:             time.UnixMilli(report.OrderCreationTime),
+			UpdateTime:       time.UnixMilli(report.TransactionTime),
+		})
+	}
+}
+
+// handleAccountPosition 转换余额变化事件，只包含本次变化的资产
+func (s *BinanceUserStream) handleAccountPosition(message []byte) {
+	if s.handler.OnBalances == nil {
+		return
+	}
+
+	var position struct {
+		EventType string `json:"e"`
+		EventTime int64  `json:"E"`
+		Balances  []struct {
+			Asset  string  `json:"a"`
+			Free   float64 `json:"f,string"`
+			Locked float64 `json:"l,string"`
+		} `json:"B"`
+	}
+	if err := json.Unmarshal(message, &position); err != nil {
+		log.Printf("解析余额推送失败: %v", err)
+		return
+	}
+
+	balances := make([]*Balance, len(position.Balances))
+	for i, b := range position.Balances {
+		balances[i] = &Balance{Asset: b.Asset, Free: b.Free, Locked: b.Locked}
+	}
+	s.handler.OnBalances(balances)
+}
@@ -3,81 +3,147 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
+
+	"inarbit/exchange"
 )
 
 // BotManager 机器人管理器
 type BotManager struct {
-	db                *Database
-	binanceClient     *BinanceClient
-	marketManager     *MarketManager
-	arbitrageEngine   *ArbitrageEngine
-	tradeExecutor     *TradeExecutor
-	activeBots        map[int64]*BotInstance
-	mu                sync.RWMutex
-	stopChan          chan struct{}
-	wsManager         *WebSocketManager
+	db              *Database
+	client          exchange.Exchange
+	marketManager   *MarketManager
+	arbitrageEngine *ArbitrageEngine
+	tradeExecutor   *TradeExecutor
+	activeBots      map[int64]*BotInstance
+	mu              sync.RWMutex
+	stopChan        chan struct{}
+	wsManager       *WebSocketManager
+	unsubscribe     func() // 取消订阅套利机会
+	accountManager  *AccountManager
+	recvWindow      int64 // 跨交易所套利新建的 Binance 客户端签名请求的有效时间窗口（毫秒）
 }
 
+// opportunityBufferSize 套利机会订阅通道的缓冲大小
+const opportunityBufferSize = 64
+
+// opportunityMaxAge 套利机会的最长有效时间，超过后不再执行
+const opportunityMaxAge = 2 * time.Second
+
+// opportunityPublishInterval 同一路径的机会推送到前端和写入数据库的最小间隔
+const opportunityPublishInterval = 5 * time.Second
+
 // BotInstance 机器人实例
 type BotInstance struct {
-	Bot                *Bot
-	IsRunning          bool
-	MarketManager      *MarketManager
-	ArbitrageEngine    *ArbitrageEngine
-	TradeExecutor      *TradeExecutor
-	LastOpportunity    *ArbitrageOpportunity
-	LastExecution      *TradeExecution
-	Statistics         *BotStatistics
-	UpdateFrequency    time.Duration
-	stopChan           chan struct{}
-	mu                 sync.RWMutex
+	Bot             *Bot
+	Strategy        *Strategy
+	IsRunning       bool
+	Client          exchange.Exchange
+	MarketManager   *MarketManager
+	ArbitrageEngine *ArbitrageEngine
+	TradeExecutor   *TradeExecutor
+	CrossExchange   *CrossExchangeArbitrage // 仅 cross_exchange 策略使用
+	LastOpportunity *ArbitrageOpportunity
+	LastExecution   *TradeExecution
+	Statistics      *BotStatistics
+	UpdateFrequency time.Duration // 两次交易之间的最小间隔
+	stopChan        chan struct{}
+	onStop          func() // 停止时释放机器人独占的资源
+	mu              sync.RWMutex
 }
 
 // BotStatistics 机器人统计信息
 type BotStatistics struct {
-	TotalTrades        int64
-	SuccessfulTrades   int64
-	FailedTrades       int64
-	TotalProfit        float64
-	TotalLoss          float64
-	WinRate            float64
+	TotalTrades           int64
+	SuccessfulTrades      int64
+	FailedTrades          int64
+	TotalProfit           float64
+	TotalLoss             float64
+	WinRate               float64
 	AverageProfitPerTrade float64
-	BestTrade          float64
-	WorstTrade         float64
-	TotalFees          float64
-	StartTime          time.Time
-	UpdatedAt          time.Time
+	BestTrade             float64
+	WorstTrade            float64
+	TotalFees             float64
+	StartTime             time.Time
+	UpdatedAt             time.Time
 }
 
-// NewBotManager 创建机器人管理器
+// NewBotManager 创建机器人管理器，recvWindow 用于跨交易所套利新建的 Binance 客户端
 func NewBotManager(
 	db *Database,
-	binanceClient *BinanceClient,
+	client exchange.Exchange,
 	marketManager *MarketManager,
 	arbitrageEngine *ArbitrageEngine,
 	tradeExecutor *TradeExecutor,
 	wsManager *WebSocketManager,
+	recvWindow int64,
 ) *BotManager {
 	return &BotManager{
 		db:              db,
-		binanceClient:   binanceClient,
+		client:          client,
 		marketManager:   marketManager,
 		arbitrageEngine: arbitrageEngine,
 		tradeExecutor:   tradeExecutor,
 		activeBots:      make(map[int64]*BotInstance),
 		stopChan:        make(chan struct{}),
 		wsManager:       wsManager,
+		recvWindow:      recvWindow,
 	}
 }
 
 // Start 启动机器人管理器
 func (bm *BotManager) Start() error {
+	// 将套利机会推送到前端并写入数据库
+	opportunities, unsubscribe := bm.arbitrageEngine.Subscribe(opportunityBufferSize)
+	bm.unsubscribe = unsubscribe
+	go bm.publishOpportunities(opportunities)
+
+	// 通过账户推送获取订单成交和余额变化，启动失败时交易执行器轮询订单状态
+	if bm.client != nil {
+		accountManager := NewAccountManager(bm.client)
+		if err := accountManager.Start(); err != nil {
+			log.Printf("启动账户管理器失败，使用REST轮询: %v", err)
+		} else {
+			bm.accountManager = accountManager
+			bm.tradeExecutor.SetAccountManager(accountManager)
+			// 抵扣资产（BNB）有余额时按抵扣后的费率计算手续费
+			bm.arbitrageEngine.FeeModel().SetBalanceSource(accountManager.CachedAvailable)
+		}
+	}
+
+	// 恢复重启前未结束的交易，持有的中间资产继续执行剩余步骤或换回起始资产
+	if err := bm.tradeExecutor.RecoverExecutions(); err != nil {
+		log.Printf("✗ 恢复未结束的交易失败: %v", err)
+	}
+
 	log.Println("✓ 机器人管理器已启动")
 	return nil
 }
 
+// publishOpportunities 推送并持久化套利机会，同一路径在 opportunityPublishInterval 内只处理一次
+func (bm *BotManager) publishOpportunities(opportunities <-chan *ArbitrageOpportunity) {
+	lastPublished := make(map[string]time.Time)
+
+	for opp := range opportunities {
+		key := strings.Join(opp.Path, ",")
+		if time.Since(lastPublished[key]) < opportunityPublishInterval {
+			continue
+		}
+		lastPublished[key] = time.Now()
+
+		if bm.wsManager != nil {
+			bm.wsManager.BroadcastOpportunity(opp)
+		}
+		if bm.db != nil {
+			if err := bm.db.RecordOpportunity(nil, opp); err != nil {
+				log.Printf("记录套利机会失败: %v", err)
+			}
+		}
+	}
+}
+
 // Stop 停止机器人管理器
 func (bm *BotManager) Stop() {
 	bm.mu.Lock()
@@ -88,6 +154,16 @@ func (bm *BotManager) Stop() {
 		botInstance.Stop()
 	}
 
+	if bm.unsubscribe != nil {
+		bm.unsubscribe()
+	}
+
+	if bm.accountManager != nil {
+		bm.tradeExecutor.SetAccountManager(nil)
+		bm.arbitrageEngine.FeeModel().SetBalanceSource(nil)
+		bm.accountManager.Stop()
+	}
+
 	close(bm.stopChan)
 	log.Println("✓ 机器人管理器已停止")
 }
@@ -108,20 +184,45 @@ func (bm *BotManager) StartBot(botID int64) error {
 		return fmt.Errorf("获取机器人信息失败: %w", err)
 	}
 
+	// 策略配置决定交易规模、最低利润、下单类型和回滚方式，没有配置时使用默认值
+	strategy, err := bm.db.GetStrategyByBotID(botID)
+	if err != nil {
+		return fmt.Errorf("获取策略配置失败: %w", err)
+	}
+
 	// 创建机器人实例
 	botInstance := &BotInstance{
-		Bot:               bot,
-		IsRunning:         true,
-		MarketManager:     bm.marketManager,
-		ArbitrageEngine:   bm.arbitrageEngine,
-		TradeExecutor:     bm.tradeExecutor,
-		UpdateFrequency:   time.Duration(bot.UpdateFrequency) * time.Second,
-		stopChan:          make(chan struct{}),
+		Bot:             bot,
+		Strategy:        strategy,
+		IsRunning:       true,
+		Client:          bm.client,
+		MarketManager:   bm.marketManager,
+		ArbitrageEngine: bm.arbitrageEngine,
+		TradeExecutor:   bm.tradeExecutor,
+		UpdateFrequency: time.Duration(bot.UpdateFrequency) * time.Second,
+		stopChan:        make(chan struct{}),
 		Statistics: &BotStatistics{
 			StartTime: time.Now(),
 		},
 	}
 
+	if bot.StrategyType == StrategyCrossExchange {
+		cross, err := bm.newCrossExchange(bot)
+		if err != nil {
+			return fmt.Errorf("初始化跨交易所套利失败: %w", err)
+		}
+		if strategy != nil {
+			cross.SetMaxTradeAmount(strategy.MaxTradeAmount)
+		}
+		botInstance.CrossExchange = cross
+		botInstance.onStop = func() {
+			cross.Close()
+			for _, venue := range cross.Venues() {
+				bm.tradeExecutor.UnregisterVenue(venue.Name)
+			}
+		}
+	}
+
 	// 启动机器人
 	go botInstance.Run()
 
@@ -132,6 +233,58 @@ func (bm *BotManager) StartBot(botID int64) error {
 	return nil
 }
 
+// newCrossExchange 为机器人的两个交易所配置创建客户端和独立的行情管理器，并注册到交易执行器
+func (bm *BotManager) newCrossExchange(bot *Bot) (*CrossExchangeArbitrage, error) {
+	if bot.HedgeExchangeID == nil {
+		return nil, fmt.Errorf("未指定对冲交易所")
+	}
+
+	venues := make([]*CrossExchangeVenue, 0, 2)
+	cleanup := func() {
+		for _, venue := range venues {
+			venue.MarketManager.Stop()
+			venue.Fees.Stop()
+		}
+	}
+
+	for _, exchangeID := range []int64{bot.ExchangeID, *bot.HedgeExchangeID} {
+		record, err := bm.db.GetExchangeByID(exchangeID, bot.UserID)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("获取交易所配置 %d 失败: %w", exchangeID, err)
+		}
+
+		client, err := exchange.New(record.Name, record.APIKey, record.APISecret, record.Passphrase, record.IsTestnet, bm.recvWindow)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		marketManager := NewMarketManager(client, time.Second)
+		if err := marketManager.Start(); err != nil {
+			cleanup()
+			return nil, fmt.Errorf("启动 %s 行情管理器失败: %w", record.Name, err)
+		}
+
+		fees := exchange.NewFeeModel(client)
+		if err := fees.Start(); err != nil {
+			log.Printf("加载 %s 手续费率失败，使用默认费率: %v", record.Name, err)
+		}
+
+		venues = append(venues, &CrossExchangeVenue{
+			Name:          fmt.Sprintf("%s#%d", record.Name, record.ID),
+			Client:        client,
+			MarketManager: marketManager,
+			Fees:          fees,
+		})
+	}
+
+	for _, venue := range venues {
+		bm.tradeExecutor.RegisterVenue(venue.Name, venue.Client)
+	}
+	return NewCrossExchangeArbitrage(venues[0], venues[1], nil, crossExchangeMinProfitPercent), nil
+}
+
 // StopBot 停止机器人
 func (bm *BotManager) StopBot(botID int64) error {
 	bm.mu.Lock()
@@ -156,6 +309,37 @@ func (bm *BotManager) GetBotInstance(botID int64) *BotInstance {
 	return bm.activeBots[botID]
 }
 
+// RateLimitUsage 获取默认交易所的请求频率限额使用情况，交易所不限流时返回nil
+func (bm *BotManager) RateLimitUsage() *exchange.RateLimitUsage {
+	return rateLimitUsage(bm.client)
+}
+
+// AccountBalances 获取默认交易所各资产的可用、冻结和占用余额
+func (bm *BotManager) AccountBalances() ([]*AssetBalance, error) {
+	if bm.accountManager == nil {
+		return nil, fmt.Errorf("账户管理器未启动")
+	}
+	return bm.accountManager.AssetBalances()
+}
+
+// BalanceReservations 获取进行中交易占用的余额，账户管理器未启动时返回空列表
+func (bm *BotManager) BalanceReservations() []*BalanceReservation {
+	if bm.accountManager == nil {
+		return []*BalanceReservation{}
+	}
+	return bm.accountManager.Reservations()
+}
+
+// rateLimitUsage 获取交易所的请求频率限额使用情况，交易所不限流时返回nil
+func rateLimitUsage(client exchange.Exchange) *exchange.RateLimitUsage {
+	limited, ok := client.(exchange.RateLimited)
+	if !ok {
+		return nil
+	}
+	usage := limited.RateLimitUsage()
+	return &usage
+}
+
 // GetActiveBots 获取所有活跃机器人
 func (bm *BotManager) GetActiveBots() []*BotInstance {
 	bm.mu.RLock()
@@ -170,22 +354,99 @@ func (bm *BotManager) GetActiveBots() []*BotInstance {
 
 // ===== BotInstance 方法 =====
 
-// Run 运行机器人
+// Run 运行机器人，处理套利引擎推送的机会
 func (bi *BotInstance) Run() {
 	log.Printf("机器人 %d 开始运行", bi.Bot.ID)
 
-	ticker := time.NewTicker(bi.UpdateFrequency)
-	defer ticker.Stop()
+	if bi.CrossExchange != nil {
+		bi.runCrossExchange()
+		return
+	}
+
+	opportunities, unsubscribe := bi.ArbitrageEngine.Subscribe(opportunityBufferSize)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-bi.stopChan:
+			return
+
+		case opp := <-opportunities:
+			bi.handleOpportunity(opp)
+		}
+	}
+}
+
+// runCrossExchange 定期比较两个交易所的价差，并定期从交易所校正持仓
+func (bi *BotInstance) runCrossExchange() {
+	if err := bi.CrossExchange.RefreshInventory(); err != nil {
+		log.Printf("机器人 %d: 获取持仓失败: %v", bi.Bot.ID, err)
+	}
+
+	scan := time.NewTicker(crossExchangeScanInterval)
+	defer scan.Stop()
+	refresh := time.NewTicker(crossExchangeInventoryRefresh)
+	defer refresh.Stop()
 
 	for {
 		select {
 		case <-bi.stopChan:
 			return
 
-		case <-ticker.C:
-			bi.scan()
+		case <-refresh.C:
+			if err := bi.CrossExchange.RefreshInventory(); err != nil {
+				log.Printf("机器人 %d: 获取持仓失败: %v", bi.Bot.ID, err)
+			}
+
+		case <-scan.C:
+			bi.handleCrossExchange()
+		}
+	}
+}
+
+// handleCrossExchange 扫描并执行跨交易所套利机会
+func (bi *BotInstance) handleCrossExchange() {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	if bi.LastExecution != nil && time.Since(bi.LastExecution.StartTime) < bi.UpdateFrequency {
+		return
+	}
+	if !bi.CrossExchange.IsDataFresh(10 * time.Second) {
+		return
+	}
+
+	opp := bi.CrossExchange.Scan(!bi.Bot.IsSimulation)
+	if opp == nil {
+		return
+	}
+	applyStrategyOrderType(bi.Strategy, opp)
+
+	// 任一侧下单额度不足时跳过，避免只成交一侧
+	if !bi.Bot.IsSimulation {
+		for _, venue := range bi.CrossExchange.Venues() {
+			if usage := rateLimitUsage(venue.Client); usage != nil && !usage.CanPlaceOrders(1) {
+				return
+			}
 		}
 	}
+
+	// 机会刚按两侧最新盘口计算，不经套利引擎重新定价
+	execution, err := bi.TradeExecutor.ExecuteArbitrage(bi.Bot.ID, opp, bi.Bot.IsSimulation, NewUnwindPolicy(bi.Strategy), NewPreTradeCheck(nil, bi.Strategy))
+	if err != nil {
+		log.Printf("机器人 %d: 执行交易失败: %v", bi.Bot.ID, err)
+		return
+	}
+
+	// 按预期成交调整持仓，实际成交由下次校正修正
+	bi.CrossExchange.ApplyOpportunity(opp)
+	logImbalances(bi.Bot.ID, bi.CrossExchange.Imbalances())
+
+	bi.LastOpportunity = opp
+	bi.LastExecution = execution
+	bi.updateStatistics(execution)
+
+	log.Printf("机器人 %d: 执行跨交易所交易 %s, 利润: %.2f", bi.Bot.ID, execution.ID, execution.ActualProfit)
 }
 
 // Stop 停止机器人
@@ -195,29 +456,44 @@ func (bi *BotInstance) Stop() {
 
 	bi.IsRunning = false
 	close(bi.stopChan)
+	if bi.onStop != nil {
+		bi.onStop()
+	}
 	log.Printf("机器人 %d 已停止", bi.Bot.ID)
 }
 
-// scan 扫描套利机会
-func (bi *BotInstance) scan() {
+// handleOpportunity 处理推送的套利机会
+func (bi *BotInstance) handleOpportunity(bestOpp *ArbitrageOpportunity) {
 	bi.mu.Lock()
 	defer bi.mu.Unlock()
 
+	// 只处理与机器人策略类型匹配且尚未过期的机会
+	if bestOpp.Type != bi.Bot.StrategyType || time.Since(bestOpp.Timestamp) > opportunityMaxAge {
+		return
+	}
+
+	// 两次交易之间至少间隔 UpdateFrequency
+	if bi.LastExecution != nil && time.Since(bi.LastExecution.StartTime) < bi.UpdateFrequency {
+		return
+	}
+
 	// 检查行情数据是否新鲜
 	if !bi.MarketManager.IsDataFresh(10 * time.Second) {
-		log.Printf("机器人 %d: 行情数据过旧，跳过本轮扫描", bi.Bot.ID)
+		log.Printf("机器人 %d: 行情数据过旧，跳过该机会", bi.Bot.ID)
 		return
 	}
 
-	// 扫描套利机会
-	// TODO: 实现具体的套利机会扫描逻辑
-	// 这里是一个示例：扫描三角套利
-
-	// 获取最佳套利机会
-	bestOpp := bi.ArbitrageEngine.GetBestOpportunity()
+	// 根据策略、可用余额和订单簿深度确定交易规模
+	limits, err := bi.sizingLimits(bestOpp.StartAsset)
+	if err != nil {
+		log.Printf("机器人 %d: 获取可用余额失败: %v", bi.Bot.ID, err)
+		return
+	}
+	bestOpp = bi.ArbitrageEngine.OptimizeSize(bestOpp, limits)
 	if bestOpp == nil {
 		return
 	}
+	applyStrategyOrderType(bi.Strategy, bestOpp)
 
 	// 评估风险
 	riskAssessment := bi.ArbitrageEngine.AssessRisk(bestOpp)
@@ -226,8 +502,16 @@ func (bi *BotInstance) scan() {
 		return
 	}
 
+	// 剩余下单额度不足以完成全部步骤时跳过，避免中途被限流
+	if !bi.Bot.IsSimulation {
+		if usage := rateLimitUsage(bi.Client); usage != nil && !usage.CanPlaceOrders(len(bestOpp.Details.Steps())) {
+			log.Printf("机器人 %d: 下单额度不足，跳过该机会", bi.Bot.ID)
+			return
+		}
+	}
+
 	// 执行交易
-	execution, err := bi.TradeExecutor.ExecuteArbitrage(bi.Bot.ID, bestOpp, bi.Bot.IsSimulation)
+	execution, err := bi.TradeExecutor.ExecuteArbitrage(bi.Bot.ID, bestOpp, bi.Bot.IsSimulation, NewUnwindPolicy(bi.Strategy), NewPreTradeCheck(bi.ArbitrageEngine, bi.Strategy))
 	if err != nil {
 		log.Printf("机器人 %d: 执行交易失败: %v", bi.Bot.ID, err)
 		return
@@ -242,6 +526,53 @@ func (bi *BotInstance) scan() {
 	log.Printf("机器人 %d: 执行交易 %s, 利润: %.2f", bi.Bot.ID, execution.ID, execution.ActualProfit)
 }
 
+// sizingLimits 获取交易规模约束，模拟模式下不受账户余额限制
+// 设置了账户管理器时扣除其他进行中交易占用的余额
+func (bi *BotInstance) sizingLimits(asset string) (SizingLimits, error) {
+	if bi.Bot.IsSimulation || bi.Client == nil {
+		return NewSizingLimits(bi.Strategy, 0), nil
+	}
+
+	var available float64
+	if accountManager := bi.TradeExecutor.AccountManager(); accountManager != nil {
+		free, err := accountManager.Available(asset)
+		if err != nil {
+			return SizingLimits{}, err
+		}
+		available = free
+	} else {
+		balance, err := bi.Client.GetBalance(asset)
+		if err != nil {
+			return SizingLimits{}, err
+		}
+		available = balance.Free
+	}
+	if available <= 0 {
+		return SizingLimits{}, fmt.Errorf("%s 可用余额不足", asset)
+	}
+	return NewSizingLimits(bi.Strategy, available), nil
+}
+
+// applyStrategyOrderType 按策略配置的下单类型和有效方式覆盖各步骤的默认值
+// 有效方式只适用于 LIMIT，其他下单类型清除有效方式
+func applyStrategyOrderType(strategy *Strategy, opp *ArbitrageOpportunity) {
+	if strategy == nil || (strategy.OrderType == "" && strategy.TimeInForce == "") {
+		return
+	}
+
+	for _, step := range opp.Details.Steps() {
+		if strategy.OrderType != "" {
+			step.OrderType = strategy.OrderType
+		}
+		switch {
+		case step.OrderType != exchange.OrderTypeLimit:
+			step.TimeInForce = ""
+		case strategy.TimeInForce != "":
+			step.TimeInForce = strategy.TimeInForce
+		}
+	}
+}
+
 // updateStatistics 更新统计信息
 func (bi *BotInstance) updateStatistics(execution *TradeExecution) {
 	stats := bi.Statistics
@@ -297,6 +628,18 @@ func (bi *BotInstance) SetUpdateFrequency(frequency time.Duration) {
 	log.Printf("机器人 %d: 更新频率已设置为 %v", bi.Bot.ID, frequency)
 }
 
+// SetStrategy 设置机器人使用的策略配置
+func (bi *BotInstance) SetStrategy(strategy *Strategy) {
+	bi.mu.Lock()
+	defer bi.mu.Unlock()
+
+	bi.Strategy = strategy
+	if bi.CrossExchange != nil {
+		bi.CrossExchange.SetMaxTradeAmount(strategy.MaxTradeAmount)
+	}
+	log.Printf("机器人 %d: 策略已设置为 %s", bi.Bot.ID, strategy.Name)
+}
+
 // SwitchMode 切换模式（虚拟/实盘）
 func (bi *BotInstance) SwitchMode(isSimulation bool) {
 	bi.mu.Lock()
@@ -315,15 +658,21 @@ func (bi *BotInstance) GetStatus() map[string]interface{} {
 	bi.mu.RLock()
 	defer bi.mu.RUnlock()
 
-	return map[string]interface{}{
-		"bot_id":              bi.Bot.ID,
-		"is_running":          bi.IsRunning,
-		"is_simulation":       bi.Bot.IsSimulation,
-		"update_frequency":    bi.UpdateFrequency.String(),
-		"last_opportunity":    bi.LastOpportunity,
-		"last_execution":      bi.LastExecution,
-		"statistics":          bi.Statistics,
+	status := map[string]interface{}{
+		"bot_id":           bi.Bot.ID,
+		"is_running":       bi.IsRunning,
+		"is_simulation":    bi.Bot.IsSimulation,
+		"update_frequency": bi.UpdateFrequency.String(),
+		"last_opportunity": bi.LastOpportunity,
+		"last_execution":   bi.LastExecution,
+		"statistics":       bi.Statistics,
+		"rate_limit":       rateLimitUsage(bi.Client),
+	}
+	if bi.CrossExchange != nil {
+		status["inventory"] = bi.CrossExchange.Inventory().Snapshot()
+		status["rebalance"] = bi.CrossExchange.Imbalances()
 	}
+	return status
 }
 
 // ===== 机器人协调器 =====
@@ -0,0 +1,790 @@
+package exchange
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	bybitMaxBookDepth      = 200  // 现货REST订单簿最多返回的档位数量
+	bybitStreamBookDepth   = 50   // 深度推送使用的档位数量（现货支持 1/50/200）
+	bybitDefaultRecvWindow = 5000 // 签名请求的默认有效时间（毫秒）
+)
+
+// BybitClient Bybit v5 现货API客户端，实现 Exchange 接口
+type BybitClient struct {
+	APIKey           string
+	APISecret        string
+	BaseURL          string
+	PublicStreamURL  string
+	PrivateStreamURL string
+	RecvWindow       int64 // 签名请求的有效时间（毫秒）
+	IsTestnet        bool
+	HTTPClient       *http.Client
+}
+
+var (
+	_ Exchange        = (*BybitClient)(nil)
+	_ OrderSubscriber = (*BybitClient)(nil)
+	_ FeeProvider     = (*BybitClient)(nil)
+)
+
+// NewBybitClient 创建Bybit客户端
+func NewBybitClient(apiKey, apiSecret string, isTestnet bool) *BybitClient {
+	baseURL := "https://api.bybit.com"
+	publicURL := "wss://stream.bybit.com/v5/public/spot"
+	privateURL := "wss://stream.bybit.com/v5/private"
+	if isTestnet {
+		baseURL = "https://api-testnet.bybit.com"
+		publicURL = "wss://stream-testnet.bybit.com/v5/public/spot"
+		privateURL = "wss://stream-testnet.bybit.com/v5/private"
+	}
+
+	return &BybitClient{
+		APIKey:           apiKey,
+		APISecret:        apiSecret,
+		BaseURL:          baseURL,
+		PublicStreamURL:  publicURL,
+		PrivateStreamURL: privateURL,
+		RecvWindow:       bybitDefaultRecvWindow,
+		IsTestnet:        isTestnet,
+		HTTPClient:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name 交易所名称
+func (c *BybitClient) Name() string {
+	return "bybit"
+}
+
+// ===== Bybit接口数据结构 =====
+
+// bybitInstrument 现货交易对信息
+type bybitInstrument struct {
+	Symbol        string `json:"symbol"`
+	BaseCoin      string `json:"baseCoin"`
+	QuoteCoin     string `json:"quoteCoin"`
+	Status        string `json:"status"` // Trading 表示可交易
+	LotSizeFilter struct {
+		BasePrecision string `json:"basePrecision"`
+		MinOrderQty   string `json:"minOrderQty"`
+		MaxOrderQty   string `json:"maxOrderQty"`
+		MinOrderAmt   string `json:"minOrderAmt"`
+	} `json:"lotSizeFilter"`
+	PriceFilter struct {
+		TickSize string `json:"tickSize"`
+	} `json:"priceFilter"`
+}
+
+// bybitTicker 行情
+type bybitTicker struct {
+	Symbol       string `json:"symbol"`
+	Bid1Price    string `json:"bid1Price"`
+	Bid1Size     string `json:"bid1Size"`
+	Ask1Price    string `json:"ask1Price"`
+	Ask1Size     string `json:"ask1Size"`
+	LastPrice    string `json:"lastPrice"`
+	Price24hPcnt string `json:"price24hPcnt"` // 小数形式，0.01 表示 1%
+	Volume24h    string `json:"volume24h"`
+	Turnover24h  string `json:"turnover24h"`
+}
+
+// bybitBook 订单簿（REST和推送格式相同）
+type bybitBook struct {
+	Symbol string     `json:"s"`
+	Bids   [][]string `json:"b"`
+	Asks   [][]string `json:"a"`
+	Ts     int64      `json:"ts"`
+	U      int64      `json:"u"` // 更新序号，推送中为1时表示服务重启后的新快照
+}
+
+// bybitOrder 订单信息
+type bybitOrder struct {
+	Category     string `json:"category"`
+	Symbol       string `json:"symbol"`
+	OrderID      string `json:"orderId"`
+	OrderLinkID  string `json:"orderLinkId"`
+	Side         string `json:"side"`
+	OrderType    string `json:"orderType"`
+	TimeInForce  string `json:"timeInForce"`
+	Price        string `json:"price"`
+	Qty          string `json:"qty"`
+	OrderStatus  string `json:"orderStatus"`
+	CumExecQty   string `json:"cumExecQty"`
+	CumExecValue string `json:"cumExecValue"`
+	CreatedTime  string `json:"createdTime"`
+	UpdatedTime  string `json:"updatedTime"`
+}
+
+// bybitExecution 成交明细
+type bybitExecution struct {
+	Category    string `json:"category"`
+	Symbol      string `json:"symbol"`
+	OrderID     string `json:"orderId"`
+	OrderLinkID string `json:"orderLinkId"`
+	ExecID      string `json:"execId"`
+	Side        string `json:"side"`
+	ExecPrice   string `json:"execPrice"`
+	ExecQty     string `json:"execQty"`
+	ExecFee     string `json:"execFee"`
+	FeeCurrency string `json:"feeCurrency"`
+	IsMaker     bool   `json:"isMaker"`
+	ExecTime    string `json:"execTime"`
+}
+
+// toSymbolInfo 转换为标准交易对信息
+func (i *bybitInstrument) toSymbolInfo() *SymbolInfo {
+	status := strings.ToUpper(i.Status)
+	if i.Status == "Trading" {
+		status = SymbolStatusTrading
+	}
+
+	return &SymbolInfo{
+		Symbol:      i.Symbol,
+		Status:      status,
+		BaseAsset:   i.BaseCoin,
+		QuoteAsset:  i.QuoteCoin,
+		TickSize:    parseDecimal(i.PriceFilter.TickSize),
+		StepSize:    parseDecimal(i.LotSizeFilter.BasePrecision),
+		MinQty:      parseDecimal(i.LotSizeFilter.MinOrderQty),
+		MaxQty:      parseDecimal(i.LotSizeFilter.MaxOrderQty),
+		MinNotional: parseDecimal(i.LotSizeFilter.MinOrderAmt),
+	}
+}
+
+// toTicker 转换为标准行情
+func (t *bybitTicker) toTicker(updatedAt time.Time) *Ticker {
+	return &Ticker{
+		Symbol:             t.Symbol,
+		BidPrice:           parseDecimal(t.Bid1Price),
+		BidQty:             parseDecimal(t.Bid1Size),
+		AskPrice:           parseDecimal(t.Ask1Price),
+		AskQty:             parseDecimal(t.Ask1Size),
+		LastPrice:          parseDecimal(t.LastPrice),
+		Volume:             parseDecimal(t.Volume24h),
+		QuoteVolume:        parseDecimal(t.Turnover24h),
+		PriceChangePercent: parseDecimal(t.Price24hPcnt) * 100,
+		UpdatedAt:          updatedAt,
+	}
+}
+
+// toOrder 转换为标准订单
+func (o *bybitOrder) toOrder() *Order {
+	order := &Order{
+		Symbol:           o.Symbol,
+		OrderID:          o.OrderID,
+		ClientOrderID:    o.OrderLinkID,
+		Side:             strings.ToUpper(o.Side),
+		Type:             strings.ToUpper(o.OrderType),
+		TimeInForce:      o.TimeInForce,
+		Price:            parseDecimal(o.Price),
+		OrigQty:          parseDecimal(o.Qty),
+		ExecutedQty:      parseDecimal(o.CumExecQty),
+		ExecutedQuoteQty: parseDecimal(o.CumExecValue),
+		Time:             bybitTime(o.CreatedTime),
+		UpdateTime:       bybitTime(o.UpdatedTime),
+	}
+	if o.TimeInForce == "PostOnly" {
+		order.Type = OrderTypeLimitMaker
+		order.TimeInForce = TimeInForceGTC
+	}
+
+	switch o.OrderStatus {
+	case "New", "Untriggered":
+		order.Status = OrderStatusNew
+	case "PartiallyFilled":
+		order.Status = OrderStatusPartiallyFilled
+	case "Filled":
+		order.Status = OrderStatusFilled
+	case "Cancelled", "PartiallyFilledCanceled":
+		order.Status = OrderStatusCanceled
+	case "Rejected":
+		order.Status = OrderStatusRejected
+	case "Deactivated":
+		order.Status = OrderStatusExpired
+	default:
+		order.Status = strings.ToUpper(o.OrderStatus)
+	}
+
+	return order
+}
+
+// toExecution 转换为标准成交明细
+func (e *bybitExecution) toExecution() *Execution {
+	return &Execution{
+		Symbol:          e.Symbol,
+		OrderID:         e.OrderID,
+		ClientOrderID:   e.OrderLinkID,
+		TradeID:         e.ExecID,
+		Side:            strings.ToUpper(e.Side),
+		Price:           parseDecimal(e.ExecPrice),
+		Quantity:        parseDecimal(e.ExecQty),
+		Commission:      parseDecimal(e.ExecFee),
+		CommissionAsset: e.FeeCurrency,
+		IsMaker:         e.IsMaker,
+		Time:            bybitTime(e.ExecTime),
+	}
+}
+
+// ===== 行情 =====
+
+// GetSymbols 获取全部现货交易对信息
+func (c *BybitClient) GetSymbols() ([]*SymbolInfo, error) {
+	params := url.Values{}
+	params.Add("category", "spot")
+
+	var result struct {
+		List []bybitInstrument `json:"list"`
+	}
+	if _, err := c.doRequest("GET", "/v5/market/instruments-info", params, nil, false, &result); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]*SymbolInfo, len(result.List))
+	for i := range result.List {
+		symbols[i] = result.List[i].toSymbolInfo()
+	}
+	return symbols, nil
+}
+
+// GetTicker 获取交易对行情
+func (c *BybitClient) GetTicker(symbol string) (*Ticker, error) {
+	tickers, err := c.getTickers(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("交易对 %s 无行情数据", symbol)
+	}
+	return tickers[0], nil
+}
+
+// GetAllTickers 获取全部现货交易对行情
+func (c *BybitClient) GetAllTickers() ([]*Ticker, error) {
+	return c.getTickers("")
+}
+
+// getTickers 获取行情，symbol 为空时返回全部交易对
+func (c *BybitClient) getTickers(symbol string) ([]*Ticker, error) {
+	params := url.Values{}
+	params.Add("category", "spot")
+	if symbol != "" {
+		params.Add("symbol", symbol)
+	}
+
+	var result struct {
+		List []bybitTicker `json:"list"`
+	}
+	updatedAt, err := c.doRequest("GET", "/v5/market/tickers", params, nil, false, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	tickers := make([]*Ticker, len(result.List))
+	for i := range result.List {
+		tickers[i] = result.List[i].toTicker(updatedAt)
+	}
+	return tickers, nil
+}
+
+// GetOrderBook 获取订单簿深度，最多 bybitMaxBookDepth 档
+// REST与推送的更新序号不属于同一序列，LastUpdateID 为0，本地订单簿通过推送的快照同步
+func (c *BybitClient) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	if limit > bybitMaxBookDepth {
+		limit = bybitMaxBookDepth
+	}
+
+	params := url.Values{}
+	params.Add("category", "spot")
+	params.Add("symbol", symbol)
+	params.Add("limit", strconv.Itoa(limit))
+
+	var book bybitBook
+	if _, err := c.doRequest("GET", "/v5/market/orderbook", params, nil, false, &book); err != nil {
+		return nil, err
+	}
+
+	bids, err := parseDepthLevels(book.Bids)
+	if err != nil {
+		return nil, fmt.Errorf("解析买单深度失败: %w", err)
+	}
+	asks, err := parseDepthLevels(book.Asks)
+	if err != nil {
+		return nil, fmt.Errorf("解析卖单深度失败: %w", err)
+	}
+
+	return &OrderBook{
+		Symbol:    symbol,
+		Bids:      bids,
+		Asks:      asks,
+		UpdatedAt: time.UnixMilli(book.Ts),
+	}, nil
+}
+
+// ===== 账户 =====
+
+// GetBalances 获取统一账户全部资产余额
+func (c *BybitClient) GetBalances() ([]*Balance, error) {
+	params := url.Values{}
+	params.Add("accountType", "UNIFIED")
+
+	var result struct {
+		List []struct {
+			Coin []struct {
+				Coin          string `json:"coin"`
+				WalletBalance string `json:"walletBalance"`
+				Locked        string `json:"locked"`
+			} `json:"coin"`
+		} `json:"list"`
+	}
+	if _, err := c.doRequest("GET", "/v5/account/wallet-balance", params, nil, true, &result); err != nil {
+		return nil, err
+	}
+
+	balances := make([]*Balance, 0)
+	for _, account := range result.List {
+		for _, coin := range account.Coin {
+			locked := parseDecimal(coin.Locked)
+			balances = append(balances, &Balance{
+				Asset:  coin.Coin,
+				Free:   parseDecimal(coin.WalletBalance) - locked,
+				Locked: locked,
+			})
+		}
+	}
+	return balances, nil
+}
+
+// GetBalance 获取指定资产余额
+func (c *BybitClient) GetBalance(asset string) (*Balance, error) {
+	balances, err := c.GetBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			return balance, nil
+		}
+	}
+
+	return nil, fmt.Errorf("资产 %s 不存在", asset)
+}
+
+// GetTradeFees 获取账户各现货交易对的手续费率
+func (c *BybitClient) GetTradeFees() ([]*TradeFee, error) {
+	params := url.Values{}
+	params.Add("category", "spot")
+
+	var result struct {
+		List []struct {
+			Symbol       string `json:"symbol"`
+			MakerFeeRate string `json:"makerFeeRate"`
+			TakerFeeRate string `json:"takerFeeRate"`
+		} `json:"list"`
+	}
+	if _, err := c.doRequest("GET", "/v5/account/fee-rate", params, nil, true, &result); err != nil {
+		return nil, err
+	}
+
+	fees := make([]*TradeFee, len(result.List))
+	for i, item := range result.List {
+		fees[i] = &TradeFee{
+			Symbol: item.Symbol,
+			Maker:  parseDecimal(item.MakerFeeRate),
+			Taker:  parseDecimal(item.TakerFeeRate),
+		}
+	}
+	return fees, nil
+}
+
+// ===== 订单 =====
+
+// PlaceOrder 下单，限价单按 GTC 挂单，市价单数量按基础资产计
+func (c *BybitClient) PlaceOrder(symbol, side, orderType string, quantity, price float64) (*Order, error) {
+	req := &OrderRequest{Symbol: symbol, Side: side, Type: orderType, Quantity: quantity}
+	if orderType == OrderTypeLimit {
+		req.Price = price
+	}
+	return c.SubmitOrder(req)
+}
+
+// SubmitOrder 按下单请求下单，LIMIT_MAKER 对应有效方式为 PostOnly 的限价单
+func (c *BybitClient) SubmitOrder(req *OrderRequest) (*Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	body := map[string]string{
+		"category": "spot",
+		"symbol":   req.Symbol,
+		"side":     bybitCase(req.Side),
+		"qty":      strconv.FormatFloat(req.Quantity, 'f', -1, 64),
+	}
+	switch req.Type {
+	case OrderTypeLimit:
+		body["orderType"] = "Limit"
+		body["price"] = strconv.FormatFloat(req.Price, 'f', -1, 64)
+		body["timeInForce"] = req.TimeInForceOrDefault()
+	case OrderTypeLimitMaker:
+		body["orderType"] = "Limit"
+		body["price"] = strconv.FormatFloat(req.Price, 'f', -1, 64)
+		body["timeInForce"] = "PostOnly"
+	case OrderTypeMarket:
+		body["orderType"] = "Market"
+		body["marketUnit"] = "baseCoin"
+		if req.QuoteQuantity > 0 {
+			body["marketUnit"] = "quoteCoin"
+			body["qty"] = strconv.FormatFloat(req.QuoteQuantity, 'f', -1, 64)
+		}
+	}
+	if req.ClientOrderID != "" {
+		body["orderLinkId"] = req.ClientOrderID
+	}
+
+	var result struct {
+		OrderID     string `json:"orderId"`
+		OrderLinkID string `json:"orderLinkId"`
+	}
+	if _, err := c.doRequest("POST", "/v5/order/create", nil, body, true, &result); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &Order{
+		Symbol:        req.Symbol,
+		OrderID:       result.OrderID,
+		ClientOrderID: result.OrderLinkID,
+		Side:          req.Side,
+		Type:          req.Type,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		Status:        OrderStatusNew,
+		Time:          now,
+		UpdateTime:    now,
+	}
+	switch req.Type {
+	case OrderTypeLimit:
+		order.TimeInForce = req.TimeInForceOrDefault()
+	case OrderTypeLimitMaker:
+		order.TimeInForce = TimeInForceGTC
+	}
+	return order, nil
+}
+
+// CancelOrder 撤销订单，返回撤单后的订单状态
+func (c *BybitClient) CancelOrder(symbol, orderID string) (*Order, error) {
+	body := map[string]string{"category": "spot", "symbol": symbol, "orderId": orderID}
+
+	var result struct {
+		OrderID string `json:"orderId"`
+	}
+	if _, err := c.doRequest("POST", "/v5/order/cancel", nil, body, true, &result); err != nil {
+		return nil, err
+	}
+
+	return c.GetOrder(symbol, orderID)
+}
+
+// GetOrder 查询订单，未完成订单不存在时从历史订单中查找
+func (c *BybitClient) GetOrder(symbol, orderID string) (*Order, error) {
+	return c.findOrder(symbol, "orderId", orderID)
+}
+
+// GetOrderByClientID 按客户端订单ID (orderLinkId) 查询订单
+func (c *BybitClient) GetOrderByClientID(symbol, clientOrderID string) (*Order, error) {
+	return c.findOrder(symbol, "orderLinkId", clientOrderID)
+}
+
+// findOrder 按订单ID或客户端订单ID依次查询未结束和历史订单
+func (c *BybitClient) findOrder(symbol, key, value string) (*Order, error) {
+	params := url.Values{}
+	params.Add("category", "spot")
+	params.Add("symbol", symbol)
+	params.Add(key, value)
+
+	for _, endpoint := range []string{"/v5/order/realtime", "/v5/order/history"} {
+		var result struct {
+			List []bybitOrder `json:"list"`
+		}
+		if _, err := c.doRequest("GET", endpoint, params, nil, true, &result); err != nil {
+			return nil, err
+		}
+		if len(result.List) > 0 {
+			return result.List[0].toOrder(), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, value)
+}
+
+// GetOpenOrders 获取未成交订单
+func (c *BybitClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	params := url.Values{}
+	params.Add("category", "spot")
+	if symbol != "" {
+		params.Add("symbol", symbol)
+	}
+
+	var result struct {
+		List []bybitOrder `json:"list"`
+	}
+	if _, err := c.doRequest("GET", "/v5/order/realtime", params, nil, true, &result); err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, len(result.List))
+	for i := range result.List {
+		orders[i] = result.List[i].toOrder()
+	}
+	return orders, nil
+}
+
+// ===== 推送 =====
+
+// SubscribeTickers 订阅一档订单簿主题 (orderbook.1)，symbols 为空时订阅全部可交易的交易对
+// 现货 tickers 主题不含买一卖一，因此使用一档深度作为盘口行情
+func (c *BybitClient) SubscribeTickers(symbols []string, callback func(*Ticker)) (Stream, error) {
+	if len(symbols) == 0 {
+		infos, err := c.GetSymbols()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.Status == SymbolStatusTrading {
+				symbols = append(symbols, info.Symbol)
+			}
+		}
+	}
+
+	return newBybitStream(c.PublicStreamURL, bybitTopics("orderbook.1", symbols), nil, func(push *bybitPush) {
+		var book bybitBook
+		if err := json.Unmarshal(push.Data, &book); err != nil {
+			log.Printf("解析Bybit盘口推送失败 (%s): %v", push.Topic, err)
+			return
+		}
+		if len(book.Bids) == 0 || len(book.Asks) == 0 || len(book.Bids[0]) < 2 || len(book.Asks[0]) < 2 {
+			return
+		}
+
+		callback(&Ticker{
+			Symbol:    book.Symbol,
+			BidPrice:  parseDecimal(book.Bids[0][0]),
+			BidQty:    parseDecimal(book.Bids[0][1]),
+			AskPrice:  parseDecimal(book.Asks[0][0]),
+			AskQty:    parseDecimal(book.Asks[0][1]),
+			UpdatedAt: time.UnixMilli(push.Ts),
+		})
+	})
+}
+
+// SubscribeDepth 订阅深度主题 (orderbook.50)
+// 订阅后先推送完整快照，之后每个增量的 u 应比上一条大1；
+// 序号不连续时丢弃该增量并重新订阅，以获取新的快照
+func (c *BybitClient) SubscribeDepth(symbols []string, callback func(*DepthUpdate)) (Stream, error) {
+	var stream *BybitStream
+	lastSeq := make(map[string]int64) // 只在读取协程中访问
+	stream, err := newBybitStream(c.PublicStreamURL, bybitTopics("orderbook."+strconv.Itoa(bybitStreamBookDepth), symbols), nil, func(push *bybitPush) {
+		var book bybitBook
+		if err := json.Unmarshal(push.Data, &book); err != nil {
+			log.Printf("解析Bybit深度推送失败 (%s): %v", push.Topic, err)
+			return
+		}
+
+		bids, err := parseDepthLevels(book.Bids)
+		if err != nil {
+			log.Printf("解析Bybit深度推送失败 (%s): %v", push.Topic, err)
+			return
+		}
+		asks, err := parseDepthLevels(book.Asks)
+		if err != nil {
+			log.Printf("解析Bybit深度推送失败 (%s): %v", push.Topic, err)
+			return
+		}
+
+		update := &DepthUpdate{
+			Symbol:        book.Symbol,
+			FirstUpdateID: book.U,
+			FinalUpdateID: book.U,
+			Bids:          bids,
+			Asks:          asks,
+			EventTime:     time.UnixMilli(push.Ts),
+		}
+
+		// u=1 的增量同样表示服务重启后的新快照
+		if push.Type == "snapshot" || book.U == 1 {
+			update.FirstUpdateID = 0
+			update.Snapshot = true
+		} else if last, ok := lastSeq[book.Symbol]; !ok || book.U != last+1 {
+			log.Printf("Bybit %s 深度序号不连续 (u=%d)，重新订阅", book.Symbol, book.U)
+			delete(lastSeq, book.Symbol)
+			go stream.resubscribe(push.Topic)
+			return
+		}
+
+		lastSeq[book.Symbol] = book.U
+		callback(update)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// SubscribeOrders 订阅私有订单主题 (order.spot)，推送现货订单的状态变化
+func (c *BybitClient) SubscribeOrders(callback func(*Order)) (Stream, error) {
+	return newBybitStream(c.PrivateStreamURL, []string{"order.spot"}, c.credentials(), func(push *bybitPush) {
+		var orders []bybitOrder
+		if err := json.Unmarshal(push.Data, &orders); err != nil {
+			log.Printf("解析Bybit订单推送失败: %v", err)
+			return
+		}
+		for i := range orders {
+			callback(orders[i].toOrder())
+		}
+	})
+}
+
+// SubscribeExecutions 订阅私有成交主题 (execution.spot)，推送现货订单的每笔成交
+func (c *BybitClient) SubscribeExecutions(callback func(*Execution)) (Stream, error) {
+	return newBybitStream(c.PrivateStreamURL, []string{"execution.spot"}, c.credentials(), func(push *bybitPush) {
+		var executions []bybitExecution
+		if err := json.Unmarshal(push.Data, &executions); err != nil {
+			log.Printf("解析Bybit成交推送失败: %v", err)
+			return
+		}
+		for i := range executions {
+			callback(executions[i].toExecution())
+		}
+	})
+}
+
+// credentials 私有频道鉴权凭证
+func (c *BybitClient) credentials() *bybitCredentials {
+	return &bybitCredentials{apiKey: c.APIKey, apiSecret: c.APISecret}
+}
+
+// ===== 私有请求辅助方法 =====
+
+// doRequest 执行HTTP请求并将返回的 result 解析到 result 参数，返回服务器时间
+// 签名为 Hex(HMAC-SHA256(timestamp + apiKey + recvWindow + queryString|body))
+func (c *BybitClient) doRequest(method string, endpoint string, params url.Values, body interface{}, signed bool, result interface{}) (time.Time, error) {
+	query := params.Encode()
+	fullURL := c.BaseURL + endpoint
+	if query != "" {
+		fullURL += "?" + query
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	req, err := http.NewRequest(method, fullURL, bytes.NewReader(payload))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if signed {
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		recvWindow := strconv.FormatInt(c.RecvWindow, 10)
+		signPayload := query
+		if method == "POST" {
+			signPayload = string(payload)
+		}
+		req.Header.Set("X-BAPI-API-KEY", c.APIKey)
+		req.Header.Set("X-BAPI-TIMESTAMP", timestamp)
+		req.Header.Set("X-BAPI-RECV-WINDOW", recvWindow)
+		req.Header.Set("X-BAPI-SIGN", bybitSign(c.APISecret, timestamp+c.APIKey+recvWindow+signPayload))
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 返回格式: {"retCode":0,"retMsg":"OK","result":{...},"time":1700000000000}
+	var envelope struct {
+		RetCode int             `json:"retCode"`
+		RetMsg  string          `json:"retMsg"`
+		Result  json.RawMessage `json:"result"`
+		Time    int64           `json:"time"`
+	}
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return time.Time{}, fmt.Errorf("API错误 (HTTP %d): %s", resp.StatusCode, string(respBody))
+		}
+		return time.Time{}, fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || envelope.RetCode != 0 {
+		return time.Time{}, fmt.Errorf("API错误 (HTTP %d, retCode %d): %s", resp.StatusCode, envelope.RetCode, envelope.RetMsg)
+	}
+
+	if err := json.Unmarshal(envelope.Result, result); err != nil {
+		return time.Time{}, fmt.Errorf("解析响应数据失败: %w", err)
+	}
+
+	serverTime := time.Now()
+	if envelope.Time > 0 {
+		serverTime = time.UnixMilli(envelope.Time)
+	}
+	return serverTime, nil
+}
+
+// ===== 辅助函数 =====
+
+// bybitSign 计算 Hex(HMAC-SHA256) 签名
+func bybitSign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// bybitCase 将 BUY、LIMIT 等取值转换为 Bybit 的首字母大写格式
+func bybitCase(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
+}
+
+// bybitTopics 构建按交易对订阅的主题，例如 orderbook.50.BTCUSDT
+func bybitTopics(prefix string, symbols []string) []string {
+	topics := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		topics[i] = prefix + "." + symbol
+	}
+	return topics
+}
+
+// bybitTime 解析毫秒时间戳字符串
+func bybitTime(ms string) time.Time {
+	v, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || v <= 0 {
+		return time.Now()
+	}
+	return time.UnixMilli(v)
+}
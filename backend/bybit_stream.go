@@ -0,0 +1,292 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	bybitPingInterval   = 20 * time.Second // 官方建议每20秒发送一次心跳
+	bybitAuthTimeout    = 10 * time.Second // 等待鉴权结果的超时时间
+	bybitAuthExpiry     = 10 * time.Second // 鉴权签名的有效期
+	bybitSubscribeBatch = 10               // 现货单条订阅消息最多包含的主题数量
+)
+
+// bybitPush Bybit WebSocket推送消息
+type bybitPush struct {
+	Topic string          `json:"topic"`
+	Type  string          `json:"type"` // 订单簿主题: snapshot 或 delta
+	Ts    int64           `json:"ts"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// bybitCredentials 私有频道鉴权凭证
+type bybitCredentials struct {
+	apiKey    string
+	apiSecret string
+}
+
+// BybitStream Bybit WebSocket连接（公共或私有频道）
+// 断线后按指数退避自动重连并重新订阅，私有频道在订阅前先鉴权
+type BybitStream struct {
+	url           string
+	topics        []string
+	credentials   *bybitCredentials // 为nil时为公共频道
+	handler       func(push *bybitPush)
+	onStateChange func(connected bool)
+
+	mu        sync.RWMutex
+	connected bool
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ Stream = (*BybitStream)(nil)
+
+// newBybitStream 创建Bybit连接，调用 Start 后开始接收
+func newBybitStream(url string, topics []string, credentials *bybitCredentials, handler func(push *bybitPush)) (*BybitStream, error) {
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("未指定订阅的主题")
+	}
+
+	return &BybitStream{
+		url:         url,
+		topics:      topics,
+		credentials: credentials,
+		handler:     handler,
+		stopChan:    make(chan struct{}),
+	}, nil
+}
+
+// SetStateHandler 设置连接状态变化回调，需在 Start 之前调用
+func (s *BybitStream) SetStateHandler(handler func(connected bool)) {
+	s.onStateChange = handler
+}
+
+// Start 启动连接
+func (s *BybitStream) Start() {
+	go s.run()
+}
+
+// Stop 关闭连接并停止重连
+func (s *BybitStream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// IsConnected 检查连接是否可用
+func (s *BybitStream) IsConnected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// setConnected 更新连接状态并通知回调
+func (s *BybitStream) setConnected(connected bool, conn *websocket.Conn) {
+	s.mu.Lock()
+	changed := s.connected != connected
+	s.connected = connected
+	s.conn = conn
+	s.mu.Unlock()
+
+	if changed && s.onStateChange != nil {
+		s.onStateChange(connected)
+	}
+}
+
+// resubscribe 取消并重新订阅主题，用于深度序号不连续时重新获取快照
+func (s *BybitStream) resubscribe(topic string) {
+	s.mu.RLock()
+	conn := s.conn
+	s.mu.RUnlock()
+
+	if conn == nil {
+		return
+	}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "unsubscribe", "args": []string{topic}}); err != nil {
+		log.Printf("Bybit取消订阅失败: %v", err)
+		return
+	}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "subscribe", "args": []string{topic}}); err != nil {
+		log.Printf("Bybit重新订阅失败: %v", err)
+	}
+}
+
+// run 维护连接的主循环
+func (s *BybitStream) run() {
+	defer s.setConnected(false, nil)
+
+	backoff := streamMinBackoff
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+		if err == nil {
+			err = s.setup(conn)
+			if err != nil {
+				conn.Close()
+			}
+		}
+
+		if err != nil {
+			s.setConnected(false, nil)
+			log.Printf("Bybit连接失败: %v, %v 后重试", err, backoff)
+
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+			continue
+		}
+
+		backoff = streamMinBackoff
+		s.setConnected(true, conn)
+
+		err = s.serve(conn)
+		conn.Close()
+
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		s.setConnected(false, nil)
+		log.Printf("Bybit连接断开: %v, 正在重连", err)
+	}
+}
+
+// setup 鉴权（私有频道）并订阅全部主题
+func (s *BybitStream) setup(conn *websocket.Conn) error {
+	if s.credentials != nil {
+		if err := s.auth(conn); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(s.topics); start += bybitSubscribeBatch {
+		end := start + bybitSubscribeBatch
+		if end > len(s.topics) {
+			end = len(s.topics)
+		}
+		if err := s.writeJSON(conn, map[string]interface{}{"op": "subscribe", "args": s.topics[start:end]}); err != nil {
+			return fmt.Errorf("订阅失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// auth 私有频道鉴权，签名内容为 "GET/realtime" + expires
+func (s *BybitStream) auth(conn *websocket.Conn) error {
+	expires := strconv.FormatInt(time.Now().Add(bybitAuthExpiry).UnixMilli(), 10)
+	args := []string{s.credentials.apiKey, expires, bybitSign(s.credentials.apiSecret, "GET/realtime"+expires)}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "auth", "args": args}); err != nil {
+		return fmt.Errorf("发送鉴权请求失败: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(bybitAuthTimeout))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("等待鉴权结果失败: %w", err)
+		}
+
+		var resp struct {
+			Op      string `json:"op"`
+			Success bool   `json:"success"`
+			RetMsg  string `json:"ret_msg"`
+		}
+		if err := json.Unmarshal(message, &resp); err != nil || resp.Op != "auth" {
+			continue
+		}
+		if !resp.Success {
+			return fmt.Errorf("鉴权失败: %s", resp.RetMsg)
+		}
+		return nil
+	}
+}
+
+// serve 处理单个连接，连接出错或停止时返回
+func (s *BybitStream) serve(conn *websocket.Conn) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.readLoop(conn)
+	}()
+
+	ping := time.NewTicker(bybitPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			s.writeMu.Lock()
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(streamWriteTimeout),
+			)
+			s.writeMu.Unlock()
+			return nil
+		case err := <-errChan:
+			return err
+		case <-ping.C:
+			if err := s.writeJSON(conn, map[string]string{"op": "ping"}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLoop 读取消息并分发，操作响应（订阅、心跳）中失败的会记录日志
+func (s *BybitStream) readLoop(conn *websocket.Conn) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var push struct {
+			bybitPush
+			Op      string `json:"op"`
+			Success *bool  `json:"success"`
+			RetMsg  string `json:"ret_msg"`
+		}
+		if err := json.Unmarshal(message, &push); err != nil {
+			continue
+		}
+		if push.Op != "" {
+			if push.Success != nil && !*push.Success && !strings.Contains(push.RetMsg, "already") {
+				log.Printf("Bybit %s 请求失败: %s", push.Op, push.RetMsg)
+			}
+			continue
+		}
+		if push.Topic == "" || len(push.Data) == 0 {
+			continue
+		}
+		s.handler(&push.bybitPush)
+	}
+}
+
+// writeJSON 发送JSON消息
+func (s *BybitStream) writeJSON(conn *websocket.Conn, v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteJSON(v)
+}
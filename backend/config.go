@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+
+	"inarbit/arbitrage"
+	"inarbit/exchange"
 )
 
 // Config 应用配置
@@ -28,6 +31,12 @@ type Config struct {
 
 	// 日志配置
 	LogLevel string
+
+	// 交易所配置
+	BinanceRecvWindow int64 // Binance签名请求的有效时间窗口（毫秒）
+
+	// 套利配置
+	MaxCycleLength int // 多边套利的最大闭环长度 (3-5)
 }
 
 // LoadConfig 加载配置
@@ -53,6 +62,12 @@ func LoadConfig() *Config {
 
 		// 日志配置
 		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		// 交易所配置
+		BinanceRecvWindow: int64(getEnvInt("BINANCE_RECV_WINDOW", exchange.BinanceDefaultRecvWindow)),
+
+		// 套利配置
+		MaxCycleLength: getEnvInt("MAX_CYCLE_LENGTH", arbitrage.MaxCycleLength),
 	}
 
 	return config
@@ -98,6 +113,12 @@ func (c *Config) Validate() error {
 	if c.JWTSecret == "" {
 		return fmt.Errorf("JWT密钥未配置")
 	}
+	if c.BinanceRecvWindow <= 0 || c.BinanceRecvWindow > exchange.BinanceMaxRecvWindow {
+		return fmt.Errorf("Binance recvWindow 必须在 1-%d 毫秒之间", exchange.BinanceMaxRecvWindow)
+	}
+	if c.MaxCycleLength < arbitrage.MinCycleLength || c.MaxCycleLength > arbitrage.MaxCycleLength {
+		return fmt.Errorf("最大闭环长度必须在 %d-%d 之间", arbitrage.MinCycleLength, arbitrage.MaxCycleLength)
+	}
 	return nil
 }
 
@@ -109,5 +130,7 @@ func (c *Config) String() string {
   服务器: %s:%s
   数据库: %s:%s/%s
   日志级别: %s
-	`, c.Env, c.ServerHost, c.ServerPort, c.DBHost, c.DBPort, c.DBName, c.LogLevel)
+  Binance recvWindow: %dms
+  最大闭环长度: %d
+	`, c.Env, c.ServerHost, c.ServerPort, c.DBHost, c.DBPort, c.DBName, c.LogLevel, c.BinanceRecvWindow, c.MaxCycleLength)
 }
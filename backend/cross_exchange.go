@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// StrategyCrossExchange 跨交易所套利策略类型
+const StrategyCrossExchange = "cross_exchange"
+
+// crossExchangeScanInterval 跨交易所价差扫描间隔
+const crossExchangeScanInterval = 500 * time.Millisecond
+
+// crossExchangeInventoryRefresh 从交易所校正持仓的间隔
+const crossExchangeInventoryRefresh = 30 * time.Second
+
+// crossExchangeRebalanceSkew 单个交易所持仓占比偏离均分超过该值时建议再平衡
+const crossExchangeRebalanceSkew = 0.3
+
+// crossExchangeMinProfitPercent 扣除两侧手续费后的默认最低利润率（%）
+const crossExchangeMinProfitPercent = 0.1
+
+// crossExchangeDefaultSymbols 策略未指定交易对时比较的交易对
+var crossExchangeDefaultSymbols = []string{"BTCUSDT", "ETHUSDT"}
+
+// CrossExchangeVenue 跨交易所套利的一侧
+type CrossExchangeVenue struct {
+	Name          string // 唯一名称，与 TradeStep.Exchange 对应
+	Client        exchange.Exchange
+	MarketManager *MarketManager
+	Fees          *exchange.FeeModel // 为nil时使用 TakerFee
+	TakerFee      float64            // 固定吃单手续费率，例如 0.001 表示 0.1%
+}
+
+// takerFee 获取交易对的吃单手续费率
+func (v *CrossExchangeVenue) takerFee(symbol string) float64 {
+	if v.Fees != nil {
+		return v.Fees.Taker(symbol)
+	}
+	return v.TakerFee
+}
+
+// CrossExchangeArbitrage 跨交易所（空间）套利
+// 比较两个交易所同一交易对的盘口，在低价一侧买入、高价一侧同时卖出，
+// 两侧都需预先持有资产（买入侧持有报价资产，卖出侧持有基础资产），不做链上划转
+type CrossExchangeArbitrage struct {
+	venues           [2]*CrossExchangeVenue
+	symbols          []string
+	minProfitPercent float64
+	maxTradeAmount   float64 // 单次最大成交额（报价资产），0 表示不限制
+	inventory        *InventoryTracker
+	mu               sync.RWMutex
+}
+
+// NewCrossExchangeArbitrage 创建跨交易所套利，symbols 为空时使用默认交易对
+func NewCrossExchangeArbitrage(a, b *CrossExchangeVenue, symbols []string, minProfitPercent float64) *CrossExchangeArbitrage {
+	if len(symbols) == 0 {
+		symbols = crossExchangeDefaultSymbols
+	}
+	for _, venue := range []*CrossExchangeVenue{a, b} {
+		if venue.Fees != nil {
+			venue.Fees.Track(symbols)
+		}
+	}
+
+	return &CrossExchangeArbitrage{
+		venues:           [2]*CrossExchangeVenue{a, b},
+		symbols:          symbols,
+		minProfitPercent: minProfitPercent,
+		inventory:        NewInventoryTracker(),
+	}
+}
+
+// Venues 获取两侧交易所
+func (c *CrossExchangeArbitrage) Venues() []*CrossExchangeVenue {
+	return c.venues[:]
+}
+
+// Inventory 获取持仓跟踪
+func (c *CrossExchangeArbitrage) Inventory() *InventoryTracker {
+	return c.inventory
+}
+
+// SetMaxTradeAmount 设置单次最大成交额（报价资产）
+func (c *CrossExchangeArbitrage) SetMaxTradeAmount(amount float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxTradeAmount = amount
+}
+
+// Close 停止两侧的行情管理器和手续费率刷新
+func (c *CrossExchangeArbitrage) Close() {
+	for _, venue := range c.venues {
+		venue.MarketManager.Stop()
+		if venue.Fees != nil {
+			venue.Fees.Stop()
+		}
+	}
+}
+
+// IsDataFresh 检查两侧行情是否都足够新
+func (c *CrossExchangeArbitrage) IsDataFresh(maxAge time.Duration) bool {
+	return c.venues[0].MarketManager.IsDataFresh(maxAge) && c.venues[1].MarketManager.IsDataFresh(maxAge)
+}
+
+// Scan 比较全部交易对的两个方向，返回净利润率最高且达到最低利润的机会
+// limitByInventory 为 true 时数量受两侧可用持仓限制（实盘），否则只受盘口和最大成交额限制
+func (c *CrossExchangeArbitrage) Scan(limitByInventory bool) *ArbitrageOpportunity {
+	var best *ArbitrageOpportunity
+	for _, symbol := range c.symbols {
+		for _, dir := range [][2]*CrossExchangeVenue{{c.venues[0], c.venues[1]}, {c.venues[1], c.venues[0]}} {
+			opp := c.evaluate(symbol, dir[0], dir[1], limitByInventory)
+			if opp != nil && (best == nil || opp.ProfitPercentage > best.ProfitPercentage) {
+				best = opp
+			}
+		}
+	}
+	return best
+}
+
+// evaluate 计算在 buy 一侧按卖一价买入、在 sell 一侧按买一价卖出的收益
+// 数量取两侧盘口数量、最大成交额和可用持仓的最小值，按两侧交易规则中较粗的步长向下舍入；
+// 手续费均折算为报价资产：收益 = 数量 × (卖价 × (1 - 卖出侧费率) - 买价 × (1 + 买入侧费率))
+func (c *CrossExchangeArbitrage) evaluate(symbol string, buy, sell *CrossExchangeVenue, limitByInventory bool) *ArbitrageOpportunity {
+	ask := buy.MarketManager.GetTicker(symbol)
+	bid := sell.MarketManager.GetTicker(symbol)
+	if ask == nil || bid == nil || ask.AskPrice <= 0 || bid.BidPrice <= ask.AskPrice {
+		return nil
+	}
+
+	buyRules := buy.MarketManager.GetTradingRules(symbol)
+	sellRules := sell.MarketManager.GetTradingRules(symbol)
+	info := buy.MarketManager.GetSymbolInfo(symbol)
+	if buyRules == nil || sellRules == nil || info == nil {
+		return nil
+	}
+
+	buyPrice := ceilToStep(ask.AskPrice, buyRules.TickSize)
+	sellPrice := roundToStep(bid.BidPrice, sellRules.TickSize)
+	buyFeeRate, sellFeeRate := buy.takerFee(symbol), sell.takerFee(symbol)
+	netPerUnit := sellPrice*(1-sellFeeRate) - buyPrice*(1+buyFeeRate)
+	if netPerUnit <= 0 {
+		return nil
+	}
+
+	quantity := math.Min(ask.AskQty, bid.BidQty)
+	c.mu.RLock()
+	if c.maxTradeAmount > 0 {
+		quantity = math.Min(quantity, c.maxTradeAmount/buyPrice)
+	}
+	c.mu.RUnlock()
+	if limitByInventory {
+		quantity = math.Min(quantity, c.inventory.Get(buy.Name, info.QuoteAsset)/(buyPrice*(1+buyFeeRate)))
+		quantity = math.Min(quantity, c.inventory.Get(sell.Name, info.BaseAsset))
+	}
+	quantity = roundToStep(quantity, math.Max(buyRules.StepSize, sellRules.StepSize))
+
+	for _, rules := range []*TradingRules{buyRules, sellRules} {
+		if quantity <= 0 || quantity < rules.MinQty || (rules.MaxQty > 0 && quantity > rules.MaxQty) {
+			return nil
+		}
+	}
+	if quantity*buyPrice < buyRules.MinNotional || quantity*sellPrice < sellRules.MinNotional {
+		return nil
+	}
+
+	cost := quantity * buyPrice
+	buyFee := cost * buyFeeRate
+	sellFee := quantity * sellPrice * sellFeeRate
+	netProfit := quantity * netPerUnit
+	profitPercentage := netProfit / cost * 100
+	if profitPercentage < c.minProfitPercent {
+		return nil
+	}
+
+	return &ArbitrageOpportunity{
+		ID:               generateOpportunityID(),
+		Type:             StrategyCrossExchange,
+		StartAsset:       info.QuoteAsset,
+		Pair1:            symbol,
+		Path:             []string{symbol},
+		InitialAmount:    cost,
+		FinalAmount:      cost + netProfit,
+		GrossProfit:      quantity * (sellPrice - buyPrice),
+		NetProfit:        netProfit,
+		ProfitPercentage: profitPercentage,
+		ExecutionTime:    1000, // 两侧同时下单
+		Confidence:       calculateConfidence(profitPercentage),
+		Timestamp:        time.Now(),
+		Details: &ArbitrageDetails{
+			Step1: &TradeStep{
+				Exchange:      buy.Name,
+				Symbol:        symbol,
+				Side:          "BUY",
+				Price:         buyPrice,
+				TopPrice:      ask.AskPrice,
+				Quantity:      quantity,
+				Amount:        quantity * (1 - buyFeeRate),
+				Fee:           buyFee,
+				FeePercentage: buyFeeRate,
+				OrderType:     defaultStepOrderType,
+				TimeInForce:   defaultStepTimeInForce,
+			},
+			Step2: &TradeStep{
+				Exchange:      sell.Name,
+				Symbol:        symbol,
+				Side:          "SELL",
+				Price:         sellPrice,
+				TopPrice:      bid.BidPrice,
+				Quantity:      quantity,
+				Amount:        quantity*sellPrice - sellFee,
+				Fee:           sellFee,
+				FeePercentage: sellFeeRate,
+				OrderType:     defaultStepOrderType,
+				TimeInForce:   defaultStepTimeInForce,
+			},
+			TotalFees: buyFee + sellFee,
+		},
+	}
+}
+
+// RefreshInventory 从两侧交易所查询相关资产余额，校正持仓
+func (c *CrossExchangeArbitrage) RefreshInventory() error {
+	for _, venue := range c.venues {
+		balances, err := venue.Client.GetBalances()
+		if err != nil {
+			return fmt.Errorf("获取 %s 余额失败: %w", venue.Name, err)
+		}
+
+		assets := c.assets(venue)
+		for _, balance := range balances {
+			if assets[balance.Asset] {
+				c.inventory.Set(venue.Name, balance.Asset, balance.Free)
+				delete(assets, balance.Asset)
+			}
+		}
+		// 余额列表中不存在的资产视为0
+		for asset := range assets {
+			c.inventory.Set(venue.Name, asset, 0)
+		}
+	}
+	return nil
+}
+
+// ApplyOpportunity 按机会的预期成交更新持仓，下次校正前避免重复使用同一笔资产
+func (c *CrossExchangeArbitrage) ApplyOpportunity(opp *ArbitrageOpportunity) {
+	info := c.venues[0].MarketManager.GetSymbolInfo(opp.Pair1)
+	if info == nil || opp.Details == nil {
+		return
+	}
+
+	buy, sell := opp.Details.Step1, opp.Details.Step2
+	c.inventory.Adjust(buy.Exchange, info.QuoteAsset, -(buy.Quantity*buy.Price + buy.Fee))
+	c.inventory.Adjust(buy.Exchange, info.BaseAsset, buy.Amount)
+	c.inventory.Adjust(sell.Exchange, info.BaseAsset, -sell.Quantity)
+	c.inventory.Adjust(sell.Exchange, info.QuoteAsset, sell.Amount)
+}
+
+// Imbalances 获取需要再平衡的资产
+func (c *CrossExchangeArbitrage) Imbalances() []*InventoryImbalance {
+	return c.inventory.Imbalances(crossExchangeRebalanceSkew)
+}
+
+// assets 获取交易对涉及的全部资产
+func (c *CrossExchangeArbitrage) assets(venue *CrossExchangeVenue) map[string]bool {
+	assets := make(map[string]bool)
+	for _, symbol := range c.symbols {
+		if info := venue.MarketManager.GetSymbolInfo(symbol); info != nil {
+			assets[info.BaseAsset] = true
+			assets[info.QuoteAsset] = true
+		}
+	}
+	return assets
+}
+
+// ===== 持仓跟踪 =====
+
+// InventoryTracker 跟踪各交易所的可用持仓
+// 跨交易所套利每次成交后，买入侧基础资产增加、卖出侧减少，持仓逐渐向一侧倾斜
+type InventoryTracker struct {
+	mu       sync.RWMutex
+	balances map[string]map[string]float64 // 交易所 -> 资产 -> 可用数量
+}
+
+// InventoryImbalance 资产在各交易所之间的分布偏差
+type InventoryImbalance struct {
+	Asset    string             `json:"asset"`
+	Balances map[string]float64 `json:"balances"`
+	Total    float64            `json:"total"`
+	Skew     float64            `json:"skew"`     // 持仓最多的交易所占比减去均分占比
+	Transfer *RebalanceTransfer `json:"transfer"` // 恢复均分所需的划转
+}
+
+// RebalanceTransfer 建议的资产划转
+type RebalanceTransfer struct {
+	Asset  string  `json:"asset"`
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+}
+
+// NewInventoryTracker 创建持仓跟踪
+func NewInventoryTracker() *InventoryTracker {
+	return &InventoryTracker{
+		balances: make(map[string]map[string]float64),
+	}
+}
+
+// Set 设置交易所的资产数量
+func (t *InventoryTracker) Set(venue, asset string, amount float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.balances[venue] == nil {
+		t.balances[venue] = make(map[string]float64)
+	}
+	t.balances[venue][asset] = amount
+}
+
+// Adjust 调整交易所的资产数量
+func (t *InventoryTracker) Adjust(venue, asset string, delta float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.balances[venue] == nil {
+		t.balances[venue] = make(map[string]float64)
+	}
+	t.balances[venue][asset] += delta
+}
+
+// Get 获取交易所的资产数量
+func (t *InventoryTracker) Get(venue, asset string) float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.balances[venue][asset]
+}
+
+// Snapshot 获取全部持仓的副本
+func (t *InventoryTracker) Snapshot() map[string]map[string]float64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	snapshot := make(map[string]map[string]float64, len(t.balances))
+	for venue, assets := range t.balances {
+		snapshot[venue] = make(map[string]float64, len(assets))
+		for asset, amount := range assets {
+			snapshot[venue][asset] = amount
+		}
+	}
+	return snapshot
+}
+
+// Imbalances 获取持仓占比偏离均分超过 maxSkew 的资产，按偏差从大到小排列
+// 建议的划转从持仓最多的交易所转到最少的交易所，使两者恢复均分
+func (t *InventoryTracker) Imbalances(maxSkew float64) []*InventoryImbalance {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	venues := make([]string, 0, len(t.balances))
+	assets := make(map[string]bool)
+	for venue, balances := range t.balances {
+		venues = append(venues, venue)
+		for asset := range balances {
+			assets[asset] = true
+		}
+	}
+	sort.Strings(venues)
+	if len(venues) < 2 {
+		return nil
+	}
+
+	result := make([]*InventoryImbalance, 0)
+	for asset := range assets {
+		imbalance := &InventoryImbalance{Asset: asset, Balances: make(map[string]float64, len(venues))}
+		most, least := venues[0], venues[0]
+		for _, venue := range venues {
+			amount := math.Max(t.balances[venue][asset], 0)
+			imbalance.Balances[venue] = amount
+			imbalance.Total += amount
+			if amount > imbalance.Balances[most] {
+				most = venue
+			}
+			if amount < imbalance.Balances[least] {
+				least = venue
+			}
+		}
+		if imbalance.Total <= 0 {
+			continue
+		}
+
+		target := imbalance.Total / float64(len(venues))
+		imbalance.Skew = (imbalance.Balances[most] - target) / imbalance.Total
+		if imbalance.Skew <= maxSkew {
+			continue
+		}
+
+		imbalance.Transfer = &RebalanceTransfer{
+			Asset:  asset,
+			From:   most,
+			To:     least,
+			Amount: math.Min(imbalance.Balances[most]-target, target-imbalance.Balances[least]),
+		}
+		result = append(result, imbalance)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Skew > result[j].Skew
+	})
+	return result
+}
+
+// logImbalances 输出需要再平衡的资产
+func logImbalances(botID int64, imbalances []*InventoryImbalance) {
+	for _, imbalance := range imbalances {
+		transfer := imbalance.Transfer
+		log.Printf("机器人 %d: %s 持仓失衡 (偏离 %.0f%%)，建议从 %s 划转 %.8f 到 %s",
+			botID, imbalance.Asset, imbalance.Skew*100, transfer.From, transfer.Amount, transfer.To)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import "inarbit/exchange"
+
+// CurrencyEdge 货币图中的有向边
+// 每个交易对对应两条方向相反的边：报价资产 -> 基础资产 (BUY)，基础资产 -> 报价资产 (SELL)
+type CurrencyEdge struct {
+	From   string // 付出的资产
+	To     string // 得到的资产
+	Symbol string // 交易对
+	Side   string // BUY or SELL
+}
+
+// TriangularCycle 三角套利闭环
+type TriangularCycle struct {
+	StartAsset string
+	Legs       [3]*CurrencyEdge
+}
+
+// Symbols 获取闭环经过的交易对
+func (c *TriangularCycle) Symbols() []string {
+	return []string{c.Legs[0].Symbol, c.Legs[1].Symbol, c.Legs[2].Symbol}
+}
+
+// Assets 获取闭环经过的资产，例如 [USDT BTC ETH]
+func (c *TriangularCycle) Assets() []string {
+	return []string{c.Legs[0].From, c.Legs[1].From, c.Legs[2].From}
+}
+
+// CurrencyGraph 货币图（以资产为节点，交易对为边）
+type CurrencyGraph struct {
+	edges   map[string][]*CurrencyEdge      // 资产 -> 出边
+	symbols map[string]*exchange.SymbolInfo // 交易对 -> 交易对信息
+}
+
+// NewCurrencyGraph 根据交易对信息构建货币图
+func NewCurrencyGraph(symbols []*exchange.SymbolInfo) *CurrencyGraph {
+	g := &CurrencyGraph{
+		edges:   make(map[string][]*CurrencyEdge),
+		symbols: make(map[string]*exchange.SymbolInfo, len(symbols)),
+	}
+
+	for _, info := range symbols {
+		if info.BaseAsset == "" || info.QuoteAsset == "" {
+			continue
+		}
+		g.symbols[info.Symbol] = info
+
+		// 用报价资产买入基础资产
+		g.edges[info.QuoteAsset] = append(g.edges[info.QuoteAsset], &CurrencyEdge{
+			From:   info.QuoteAsset,
+			To:     info.BaseAsset,
+			Symbol: info.Symbol,
+			Side:   "BUY",
+		})
+
+		// 卖出基础资产得到报价资产
+		g.edges[info.BaseAsset] = append(g.edges[info.BaseAsset], &CurrencyEdge{
+			From:   info.BaseAsset,
+			To:     info.QuoteAsset,
+			Symbol: info.Symbol,
+			Side:   "SELL",
+		})
+	}
+
+	return g
+}
+
+// SymbolCount 获取图中的交易对数量
+func (g *CurrencyGraph) SymbolCount() int {
+	return len(g.symbols)
+}
+
+// Edges 获取资产的所有出边
+func (g *CurrencyGraph) Edges(asset string) []*CurrencyEdge {
+	return g.edges[asset]
+}
+
+// AllEdges 获取图中所有有向边
+func (g *CurrencyGraph) AllEdges() []*CurrencyEdge {
+	edges := make([]*CurrencyEdge, 0, len(g.symbols)*2)
+	for _, list := range g.edges {
+		edges = append(edges, list...)
+	}
+	return edges
+}
+
+// FindTriangularCycles 枚举从指定资产出发并回到该资产的所有三角闭环
+func (g *CurrencyGraph) FindTriangularCycles(startAssets []string) []*TriangularCycle {
+	cycles := make([]*TriangularCycle, 0)
+
+	for _, start := range startAssets {
+		for _, leg1 := range g.edges[start] {
+			for _, leg2 := range g.edges[leg1.To] {
+				if leg2.To == start || leg2.Symbol == leg1.Symbol {
+					continue
+				}
+				for _, leg3 := range g.edges[leg2.To] {
+					if leg3.To != start || leg3.Symbol == leg1.Symbol || leg3.Symbol == leg2.Symbol {
+						continue
+					}
+					cycles = append(cycles, &TriangularCycle{
+						StartAsset: start,
+						Legs:       [3]*CurrencyEdge{leg1, leg2, leg3},
+					})
+				}
+			}
+		}
+	}
+
+	return cycles
+}
+
+// BuildCycle 按给定交易对顺序构建闭环，无法闭合时返回nil
+func (g *CurrencyGraph) BuildCycle(startAsset string, pairs []string) *TriangularCycle {
+	if len(pairs) != 3 {
+		return nil
+	}
+
+	cycle := &TriangularCycle{StartAsset: startAsset}
+	current := startAsset
+
+	for i, pair := range pairs {
+		edge := g.edgeFor(current, pair)
+		if edge == nil {
+			return nil
+		}
+		cycle.Legs[i] = edge
+		current = edge.To
+	}
+
+	if current != startAsset {
+		return nil
+	}
+	return cycle
+}
+
+// ResolveCycle 根据交易对推断起始资产并构建闭环
+func (g *CurrencyGraph) ResolveCycle(pair1, pair2, pair3 string) *TriangularCycle {
+	info, ok := g.symbols[pair1]
+	if !ok {
+		return nil
+	}
+
+	pairs := []string{pair1, pair2, pair3}
+	for _, start := range []string{info.QuoteAsset, info.BaseAsset} {
+		if cycle := g.BuildCycle(start, pairs); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// edgeFor 查找从指定资产出发经过指定交易对的边
+func (g *CurrencyGraph) edgeFor(from string, symbol string) *CurrencyEdge {
+	for _, edge := range g.edges[from] {
+		if edge.Symbol == symbol {
+			return edge
+		}
+	}
+	return nil
+}
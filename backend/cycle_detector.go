@@ -0,0 +1,157 @@
+package arbitrage
+
+import "math"
+
+// MinCycleLength 最小闭环长度
+const MinCycleLength = 3
+
+// MaxCycleLength 支持的最大闭环长度
+const MaxCycleLength = 5
+
+// RateEdge 汇率图中的有向边
+type RateEdge struct {
+	From   string
+	To     string
+	Symbol string
+	Side   string  // BUY or SELL
+	Rate   float64 // 付出1单位From可得到的To数量（已扣除手续费）
+}
+
+// RateCycle 有利可图的汇率闭环
+type RateCycle struct {
+	StartAsset string
+	Edges      []RateEdge
+	Product    float64 // 汇率连乘，大于1表示有利可图
+}
+
+// Path 获取闭环经过的交易对
+func (c *RateCycle) Path() []string {
+	path := make([]string, len(c.Edges))
+	for i, edge := range c.Edges {
+		path[i] = edge.Symbol
+	}
+	return path
+}
+
+// ProfitPercent 获取闭环的理论利润百分比
+func (c *RateCycle) ProfitPercent() float64 {
+	return (c.Product - 1) * 100
+}
+
+// cycleCandidates 每个 (步数, 资产) 保留的最优简单路径数量
+// 只保留一条时，最优路径重复经过某个资产就会漏掉经过次优前驱的简单闭环
+const cycleCandidates = 8
+
+// FindNegativeCycles 在 -log(rate) 权重图上查找负权闭环
+// 对每个起始资产运行有界 Bellman-Ford：第k轮得到恰好k步到达各资产的若干条最短简单路径，
+// 若第k轮 (minLen <= k <= maxLen) 回到起始资产的路径权重为负，说明存在k步的盈利闭环。
+// 每个 (起始资产, 长度) 组合最多返回 cycleCandidates 个不重复经过资产的闭环，按权重从小到大排列。
+func FindNegativeCycles(edges []RateEdge, startAssets []string, minLen, maxLen int) []*RateCycle {
+	if minLen < MinCycleLength {
+		minLen = MinCycleLength
+	}
+	if maxLen > MaxCycleLength {
+		maxLen = MaxCycleLength
+	}
+
+	weights := make([]float64, len(edges))
+	for i, edge := range edges {
+		if edge.Rate > 0 {
+			weights[i] = -math.Log(edge.Rate)
+		} else {
+			weights[i] = math.Inf(1)
+		}
+	}
+
+	cycles := make([]*RateCycle, 0)
+	for _, start := range startAssets {
+		cycles = append(cycles, findCyclesFrom(edges, weights, start, minLen, maxLen)...)
+	}
+	return cycles
+}
+
+// walk 从起始资产出发的简单路径
+type walk struct {
+	weight float64
+	edges  []int // 依次经过的边
+}
+
+// visits 判断路径是否已经到达过指定资产（不含起始资产）
+func (w *walk) visits(edges []RateEdge, asset string) bool {
+	for _, i := range w.edges {
+		if edges[i].To == asset {
+			return true
+		}
+	}
+	return false
+}
+
+// addCandidate 将路径按权重插入候选列表，只保留权重最小的 cycleCandidates 条
+func addCandidate(candidates []*walk, w *walk) []*walk {
+	if len(candidates) == cycleCandidates && w.weight >= candidates[len(candidates)-1].weight {
+		return candidates
+	}
+
+	pos := len(candidates)
+	for pos > 0 && candidates[pos-1].weight > w.weight {
+		pos--
+	}
+	if len(candidates) < cycleCandidates {
+		candidates = append(candidates, nil)
+	}
+	copy(candidates[pos+1:], candidates[pos:len(candidates)-1])
+	candidates[pos] = w
+	return candidates
+}
+
+// findCyclesFrom 查找经过指定起始资产的负权闭环
+func findCyclesFrom(edges []RateEdge, weights []float64, start string, minLen, maxLen int) []*RateCycle {
+	// paths[asset] 为恰好k-1步到达asset的候选路径，每轮由上一轮扩展一步
+	paths := map[string][]*walk{start: {{}}}
+
+	cycles := make([]*RateCycle, 0)
+	for k := 1; k <= maxLen; k++ {
+		next := make(map[string][]*walk)
+		for i, edge := range edges {
+			if math.IsInf(weights[i], 1) {
+				continue
+			}
+			// 起始资产只能在第一步离开，回到起始资产的路径不再扩展
+			if edge.From == start && k > 1 {
+				continue
+			}
+			// 最后一步必须回到起始资产
+			if k == maxLen && edge.To != start {
+				continue
+			}
+
+			for _, w := range paths[edge.From] {
+				if edge.To != start && w.visits(edges, edge.To) {
+					continue
+				}
+				extended := &walk{weight: w.weight + weights[i], edges: make([]int, len(w.edges)+1)}
+				copy(extended.edges, w.edges)
+				extended.edges[len(w.edges)] = i
+				next[edge.To] = addCandidate(next[edge.To], extended)
+			}
+		}
+
+		if k >= minLen {
+			for _, w := range next[start] {
+				if w.weight >= 0 {
+					break
+				}
+				cycle := &RateCycle{StartAsset: start, Edges: make([]RateEdge, len(w.edges)), Product: math.Exp(-w.weight)}
+				for j, i := range w.edges {
+					cycle.Edges[j] = edges[i]
+				}
+				cycles = append(cycles, cycle)
+			}
+		}
+
+		delete(next, start)
+		paths = next
+	}
+
+	return cycles
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// CycleLegs 套利闭环（3-5步）
+type CycleLegs struct {
+	StartAsset string
+	Legs       []*CurrencyEdge
+}
+
+// Key 获取闭环的唯一标识，例如 USDT:BTCUSDT,ETHBTC,ETHUSDT
+func (c *CycleLegs) Key() string {
+	symbols := make([]string, len(c.Legs))
+	for i, leg := range c.Legs {
+		symbols[i] = leg.Symbol
+	}
+	return c.StartAsset + ":" + strings.Join(symbols, ",")
+}
+
+// CycleIndex 交易对到闭环的索引，行情变化时只需重新计算经过该交易对的闭环
+// 非并发安全，由调用方加锁
+type CycleIndex struct {
+	bySymbol map[string][]*CycleLegs
+	keys     map[string]bool
+}
+
+// NewCycleIndex 创建闭环索引
+func NewCycleIndex() *CycleIndex {
+	return &CycleIndex{
+		bySymbol: make(map[string][]*CycleLegs),
+		keys:     make(map[string]bool),
+	}
+}
+
+// Add 添加闭环，已存在时返回false
+func (ci *CycleIndex) Add(cycle *CycleLegs) bool {
+	key := cycle.Key()
+	if ci.keys[key] {
+		return false
+	}
+	ci.keys[key] = true
+
+	seen := make(map[string]bool, len(cycle.Legs))
+	for _, leg := range cycle.Legs {
+		if seen[leg.Symbol] {
+			continue
+		}
+		seen[leg.Symbol] = true
+		ci.bySymbol[leg.Symbol] = append(ci.bySymbol[leg.Symbol], cycle)
+	}
+	return true
+}
+
+// Cycles 获取经过任一指定交易对的闭环（去重）
+func (ci *CycleIndex) Cycles(symbols []string) []*CycleLegs {
+	if len(symbols) == 1 {
+		return ci.bySymbol[symbols[0]]
+	}
+
+	seen := make(map[*CycleLegs]bool)
+	cycles := make([]*CycleLegs, 0)
+	for _, symbol := range symbols {
+		for _, cycle := range ci.bySymbol[symbol] {
+			if !seen[cycle] {
+				seen[cycle] = true
+				cycles = append(cycles, cycle)
+			}
+		}
+	}
+	return cycles
+}
+
+// Symbols 获取索引中闭环经过的全部交易对，按名称排序
+func (ci *CycleIndex) Symbols() []string {
+	symbols := make([]string, 0, len(ci.bySymbol))
+	for symbol := range ci.bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Len 获取索引中的闭环数量
+func (ci *CycleIndex) Len() int {
+	return len(ci.keys)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -89,7 +90,7 @@ func (d *Database) GetUserByID(id int64) (*User, error) {
 // GetBots 获取用户的所有机器人
 func (d *Database) GetBots(userID int64) ([]*Bot, error) {
 	rows, err := d.DB.Query(
-		`SELECT id, user_id, name, strategy_type, exchange_id, is_running, is_simulation, 
+		`SELECT id, user_id, name, strategy_type, exchange_id, hedge_exchange_id, is_running, is_simulation, 
 		        update_frequency, created_at, total_profit, total_trades
 		 FROM bots WHERE user_id = $1 ORDER BY created_at DESC`,
 		userID,
@@ -103,7 +104,7 @@ func (d *Database) GetBots(userID int64) ([]*Bot, error) {
 	for rows.Next() {
 		bot := &Bot{}
 		err := rows.Scan(
-			&bot.ID, &bot.UserID, &bot.Name, &bot.StrategyType, &bot.ExchangeID,
+			&bot.ID, &bot.UserID, &bot.Name, &bot.StrategyType, &bot.ExchangeID, &bot.HedgeExchangeID,
 			&bot.IsRunning, &bot.IsSimulation, &bot.UpdateFrequency,
 			&bot.CreatedAt, &bot.TotalProfit, &bot.TotalTrades,
 		)
@@ -120,12 +121,12 @@ func (d *Database) GetBots(userID int64) ([]*Bot, error) {
 func (d *Database) GetBotByID(id int64, userID int64) (*Bot, error) {
 	bot := &Bot{}
 	err := d.DB.QueryRow(
-		`SELECT id, user_id, name, strategy_type, exchange_id, is_running, is_simulation, 
+		`SELECT id, user_id, name, strategy_type, exchange_id, hedge_exchange_id, is_running, is_simulation, 
 		        update_frequency, created_at, total_profit, total_trades
 		 FROM bots WHERE id = $1 AND user_id = $2`,
 		id, userID,
 	).Scan(
-		&bot.ID, &bot.UserID, &bot.Name, &bot.StrategyType, &bot.ExchangeID,
+		&bot.ID, &bot.UserID, &bot.Name, &bot.StrategyType, &bot.ExchangeID, &bot.HedgeExchangeID,
 		&bot.IsRunning, &bot.IsSimulation, &bot.UpdateFrequency,
 		&bot.CreatedAt, &bot.TotalProfit, &bot.TotalTrades,
 	)
@@ -140,13 +141,39 @@ func (d *Database) GetBotByID(id int64, userID int64) (*Bot, error) {
 	return bot, nil
 }
 
+// GetStrategyByBotID 获取机器人当前启用的策略配置，没有配置策略时返回nil
+func (d *Database) GetStrategyByBotID(botID int64) (*Strategy, error) {
+	strategy := &Strategy{}
+	err := d.DB.QueryRow(
+		`SELECT id, bot_id, name, strategy_type, COALESCE(min_profit_percent, 0), COALESCE(min_trade_amount, 0),
+		        COALESCE(max_trade_amount, 0), COALESCE(max_loss_percent, 0), COALESCE(order_type, ''), COALESCE(time_in_force, ''),
+		        COALESCE(unwind_policy, ''), COALESCE(unwind_cap_percent, 0), is_active, created_at, updated_at
+		 FROM strategies WHERE bot_id = $1 AND is_active AND deleted_at IS NULL
+		 ORDER BY updated_at DESC LIMIT 1`,
+		botID,
+	).Scan(
+		&strategy.ID, &strategy.BotID, &strategy.Name, &strategy.StrategyType, &strategy.MinProfitPercentage, &strategy.MinTradeAmount,
+		&strategy.MaxTradeAmount, &strategy.MaxLossPercentage, &strategy.OrderType, &strategy.TimeInForce,
+		&strategy.UnwindPolicy, &strategy.UnwindCapPercent, &strategy.IsActive, &strategy.CreatedAt, &strategy.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return strategy, nil
+}
+
 // CreateBot 创建机器人
 func (d *Database) CreateBot(bot *Bot) error {
 	err := d.DB.QueryRow(
-		`INSERT INTO bots (user_id, name, strategy_type, exchange_id, is_running, is_simulation, update_frequency)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`INSERT INTO bots (user_id, name, strategy_type, exchange_id, hedge_exchange_id, is_running, is_simulation, update_frequency)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		 RETURNING id, created_at`,
-		bot.UserID, bot.Name, bot.StrategyType, bot.ExchangeID,
+		bot.UserID, bot.Name, bot.StrategyType, bot.ExchangeID, bot.HedgeExchangeID,
 		bot.IsRunning, bot.IsSimulation, bot.UpdateFrequency,
 	).Scan(&bot.ID, &bot.CreatedAt)
 
@@ -156,10 +183,10 @@ func (d *Database) CreateBot(bot *Bot) error {
 // UpdateBot 更新机器人
 func (d *Database) UpdateBot(bot *Bot) error {
 	result, err := d.DB.Exec(
-		`UPDATE bots SET name = $1, strategy_type = $2, exchange_id = $3, 
-		        is_running = $4, is_simulation = $5, update_frequency = $6, updated_at = NOW()
-		 WHERE id = $7 AND user_id = $8`,
-		bot.Name, bot.StrategyType, bot.ExchangeID,
+		`UPDATE bots SET name = $1, strategy_type = $2, exchange_id = $3, hedge_exchange_id = $4,
+		        is_running = $5, is_simulation = $6, update_frequency = $7, updated_at = NOW()
+		 WHERE id = $8 AND user_id = $9`,
+		bot.Name, bot.StrategyType, bot.ExchangeID, bot.HedgeExchangeID,
 		bot.IsRunning, bot.IsSimulation, bot.UpdateFrequency,
 		bot.ID, bot.UserID,
 	)
@@ -324,6 +351,208 @@ func (d *Database) RecordTrade(trade *Trade) error {
 	return err
 }
 
+// SaveTradeExecution 保存交易执行记录及其订单，按 execution_id 更新已有记录
+func (d *Database) SaveTradeExecution(execution *TradeExecution) error {
+	path, err := json.Marshal(execution.Path)
+	if err != nil {
+		return err
+	}
+	steps, err := json.Marshal(execution.Steps)
+	if err != nil {
+		return err
+	}
+
+	// 交易结束前没有结果，保存为NULL
+	var finalAmount, grossProfit, netProfit, profitPercent, executionTime interface{}
+	if !execution.EndTime.IsZero() {
+		finalAmount = execution.FinalAmount
+		grossProfit = execution.FinalAmount - execution.InitialAmount
+		netProfit = execution.ActualProfit
+		profitPercent = execution.ActualProfitPercent
+		executionTime = execution.ExecutionTime
+	}
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var tradeID int64
+	err = tx.QueryRow(
+		`INSERT INTO trades (bot_id, execution_id, status, strategy_type, trading_path, start_asset, trade_steps,
+		                     unwind_policy, unwind_cap_percent, initial_amount, final_amount, gross_profit, net_profit,
+		                     profit_percent, total_fees, realized_loss, execution_time_ms, orders_count, is_simulation,
+		                     error_message, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+		         NULLIF($20, ''), $21, NOW())
+		 ON CONFLICT (execution_id) DO UPDATE SET
+		     status = EXCLUDED.status, initial_amount = EXCLUDED.initial_amount, final_amount = EXCLUDED.final_amount,
+		     gross_profit = EXCLUDED.gross_profit, net_profit = EXCLUDED.net_profit, profit_percent = EXCLUDED.profit_percent,
+		     total_fees = EXCLUDED.total_fees, realized_loss = EXCLUDED.realized_loss,
+		     execution_time_ms = EXCLUDED.execution_time_ms, orders_count = EXCLUDED.orders_count,
+		     error_message = EXCLUDED.error_message, updated_at = NOW()
+		 RETURNING id`,
+		execution.BotID, execution.ID, execution.Status, execution.Type, string(path), execution.StartAsset, string(steps),
+		execution.Unwind.Mode, execution.Unwind.PriceCapPercent, execution.InitialAmount, finalAmount, grossProfit, netProfit,
+		profitPercent, execution.TotalFees, execution.RealizedLoss, executionTime, len(execution.Orders), execution.IsSimulation,
+		execution.ErrorMessage, execution.CreatedAt,
+	).Scan(&tradeID)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range execution.Orders {
+		if err := saveExecutedOrder(tx, tradeID, order); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveExecutedOrder 保存单个订单的最新状态，交易执行记录需已保存
+func (d *Database) SaveExecutedOrder(executionID string, order *ExecutedOrder) error {
+	var tradeID int64
+	err := d.DB.QueryRow("SELECT id FROM trades WHERE execution_id = $1", executionID).Scan(&tradeID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("交易记录 %s 不存在", executionID)
+		}
+		return err
+	}
+	return saveExecutedOrder(d.DB, tradeID, order)
+}
+
+// sqlExecer 可执行SQL语句的数据库连接或事务
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveExecutedOrder 按 trade_id、步骤和下单次数写入或更新订单，同一步骤的每次下单各保存一行
+// exchange_order_id 保存客户端订单ID，下单结果未知时也能据此向交易所查询订单
+func saveExecutedOrder(db sqlExecer, tradeID int64, order *ExecutedOrder) error {
+	var executedPrice interface{}
+	if order.AvgPrice > 0 {
+		executedPrice = order.AvgPrice
+	}
+
+	_, err := db.Exec(
+		`INSERT INTO orders (trade_id, step, attempt, exchange, exchange_order_id, symbol, side, order_type, quantity, price,
+		                     executed_quantity, executed_price, executed_quote_quantity, commission, commission_asset,
+		                     status, created_at, updated_at)
+		 VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17, NOW())
+		 ON CONFLICT (trade_id, step, attempt) DO UPDATE SET
+		     exchange = EXCLUDED.exchange, exchange_order_id = EXCLUDED.exchange_order_id,
+		     symbol = EXCLUDED.symbol, side = EXCLUDED.side, order_type = EXCLUDED.order_type, quantity = EXCLUDED.quantity, price = EXCLUDED.price,
+		     executed_quantity = EXCLUDED.executed_quantity, executed_price = EXCLUDED.executed_price,
+		     executed_quote_quantity = EXCLUDED.executed_quote_quantity, commission = EXCLUDED.commission,
+		     commission_asset = EXCLUDED.commission_asset, status = EXCLUDED.status, updated_at = NOW()`,
+		tradeID, order.Step, order.Attempt, order.Exchange, order.ClientOrderID, order.Symbol, order.Side, order.Type,
+		order.Quantity, order.Price, order.ExecutedQty, executedPrice, order.CummulativeQty, order.Fee, order.FeeAsset,
+		order.Status, order.ExecutedAt,
+	)
+	return err
+}
+
+// GetUnfinishedExecutions 获取尚未结束（pending、executing）的交易执行记录及其订单，按创建时间排序
+func (d *Database) GetUnfinishedExecutions() ([]*TradeExecution, error) {
+	rows, err := d.DB.Query(
+		`SELECT id, bot_id, execution_id, status, strategy_type, trading_path, COALESCE(start_asset, ''),
+		        COALESCE(trade_steps, '[]'), COALESCE(unwind_policy, ''), COALESCE(unwind_cap_percent, 0),
+		        initial_amount, is_simulation, created_at
+		 FROM trades
+		 WHERE status IN ('pending', 'executing') AND execution_id IS NOT NULL AND deleted_at IS NULL
+		 ORDER BY created_at`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tradeIDs := make([]int64, 0)
+	var executions []*TradeExecution
+	for rows.Next() {
+		var tradeID int64
+		var path, steps string
+		execution := &TradeExecution{}
+		err := rows.Scan(&tradeID, &execution.BotID, &execution.ID, &execution.Status, &execution.Type, &path,
+			&execution.StartAsset, &steps, &execution.Unwind.Mode, &execution.Unwind.PriceCapPercent,
+			&execution.InitialAmount, &execution.IsSimulation, &execution.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(path), &execution.Path); err != nil {
+			return nil, fmt.Errorf("解析交易 %s 的路径失败: %w", execution.ID, err)
+		}
+		if err := json.Unmarshal([]byte(steps), &execution.Steps); err != nil {
+			return nil, fmt.Errorf("解析交易 %s 的步骤失败: %w", execution.ID, err)
+		}
+		execution.StartTime = execution.CreatedAt
+		tradeIDs = append(tradeIDs, tradeID)
+		executions = append(executions, execution)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, execution := range executions {
+		orders, err := d.getExecutedOrders(tradeIDs[i])
+		if err != nil {
+			return nil, fmt.Errorf("获取交易 %s 的订单失败: %w", execution.ID, err)
+		}
+		execution.Orders = orders
+	}
+
+	return executions, nil
+}
+
+// getExecutedOrders 获取交易的订单（包括同一步骤之前的下单），按步骤和下单次数排序
+func (d *Database) getExecutedOrders(tradeID int64) ([]*ExecutedOrder, error) {
+	rows, err := d.DB.Query(
+		`SELECT step, COALESCE(attempt, 1), COALESCE(exchange, ''), COALESCE(exchange_order_id, ''), symbol, side,
+		        order_type, quantity, COALESCE(price, 0), executed_quantity, COALESCE(executed_price, 0), COALESCE(executed_quote_quantity, 0),
+		        commission, COALESCE(commission_asset, ''), status, created_at
+		 FROM orders WHERE trade_id = $1 AND deleted_at IS NULL ORDER BY step, attempt`,
+		tradeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := make([]*ExecutedOrder, 0)
+	for rows.Next() {
+		order := &ExecutedOrder{}
+		err := rows.Scan(&order.Step, &order.Attempt, &order.Exchange, &order.ClientOrderID, &order.Symbol, &order.Side,
+			&order.Type, &order.Quantity, &order.Price, &order.ExecutedQty, &order.AvgPrice, &order.CummulativeQty, &order.Fee,
+			&order.FeeAsset, &order.Status, &order.ExecutedAt)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// RecordOpportunity 记录套利机会
+func (d *Database) RecordOpportunity(botID *int64, opp *ArbitrageOpportunity) error {
+	path, err := json.Marshal(opp.Path)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.DB.Exec(
+		`INSERT INTO arbitrage_opportunities (bot_id, strategy_type, trading_path, initial_amount, final_amount,
+		                                      gross_profit, net_profit, profit_percent, confidence_score, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())`,
+		botID, opp.Type, string(path), opp.InitialAmount, opp.FinalAmount,
+		opp.GrossProfit, opp.NetProfit, opp.ProfitPercentage, opp.Confidence,
+	)
+	return err
+}
+
 // GetExchanges 获取用户的交易所配置
 func (d *Database) GetExchanges(userID int64) ([]*Exchange, error) {
 	rows, err := d.DB.Query(
@@ -352,9 +581,9 @@ func (d *Database) GetExchanges(userID int64) ([]*Exchange, error) {
 func (d *Database) GetExchangeByID(id int64, userID int64) (*Exchange, error) {
 	exchange := &Exchange{}
 	err := d.DB.QueryRow(
-		"SELECT id, user_id, name, api_key, api_secret, is_testnet, is_active FROM exchanges WHERE id = $1 AND user_id = $2",
+		"SELECT id, user_id, name, api_key, api_secret, COALESCE(passphrase, ''), is_testnet, is_active FROM exchanges WHERE id = $1 AND user_id = $2",
 		id, userID,
-	).Scan(&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.APIKey, &exchange.APISecret, &exchange.IsTestnet, &exchange.IsActive)
+	).Scan(&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.APIKey, &exchange.APISecret, &exchange.Passphrase, &exchange.IsTestnet, &exchange.IsActive)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -369,10 +598,10 @@ func (d *Database) GetExchangeByID(id int64, userID int64) (*Exchange, error) {
 // CreateExchange 创建交易所配置
 func (d *Database) CreateExchange(exchange *Exchange) error {
 	err := d.DB.QueryRow(
-		`INSERT INTO exchanges (user_id, name, api_key, api_secret, is_testnet, is_active)
-		 VALUES ($1, $2, $3, $4, $5, $6)
+		`INSERT INTO exchanges (user_id, name, api_key, api_secret, passphrase, is_testnet, is_active)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
 		 RETURNING id, created_at`,
-		exchange.UserID, exchange.Name, exchange.APIKey, exchange.APISecret, exchange.IsTestnet, exchange.IsActive,
+		exchange.UserID, exchange.Name, exchange.APIKey, exchange.APISecret, exchange.Passphrase, exchange.IsTestnet, exchange.IsActive,
 	).Scan(&exchange.ID, &exchange.CreatedAt)
 
 	return err
@@ -0,0 +1,319 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Exchange 交易所统一接口
+// 各交易所适配器将接口数据转换为下列标准化类型：价格和数量均为 float64，
+// 交易对名称统一为 BASEQUOTE 格式（例如 BTCUSDT），订单方向、类型和状态统一使用 Binance 的取值
+type Exchange interface {
+	// Name 交易所名称，例如 binance
+	Name() string
+
+	// ===== 行情 =====
+
+	// GetSymbols 获取全部交易对信息
+	GetSymbols() ([]*SymbolInfo, error)
+	// GetTicker 获取交易对行情
+	GetTicker(symbol string) (*Ticker, error)
+	// GetAllTickers 获取全部交易对行情
+	GetAllTickers() ([]*Ticker, error)
+	// GetOrderBook 获取最优 limit 档订单簿深度
+	GetOrderBook(symbol string, limit int) (*OrderBook, error)
+
+	// ===== 账户 =====
+
+	// GetBalances 获取全部资产余额
+	GetBalances() ([]*Balance, error)
+	// GetBalance 获取指定资产余额
+	GetBalance(asset string) (*Balance, error)
+
+	// ===== 订单 =====
+
+	// PlaceOrder 下单，orderType 为 LIMIT 或 MARKET，限价单按 GTC 挂单，市价单忽略 price
+	PlaceOrder(symbol, side, orderType string, quantity, price float64) (*Order, error)
+	// SubmitOrder 按下单请求下单，支持有效方式、按报价资产金额的市价单和只做maker的限价单
+	SubmitOrder(req *OrderRequest) (*Order, error)
+	// CancelOrder 撤销订单
+	CancelOrder(symbol, orderID string) (*Order, error)
+	// GetOrder 查询订单
+	GetOrder(symbol, orderID string) (*Order, error)
+	// GetOpenOrders 获取未成交订单，symbol 为空时返回全部交易对
+	GetOpenOrders(symbol string) ([]*Order, error)
+
+	// ===== 推送 =====
+
+	// SubscribeTickers 订阅盘口行情推送，symbols 为空时订阅全市场
+	// 推送的行情只包含买一卖一价格和数量，返回的连接需调用 Start 后开始接收
+	SubscribeTickers(symbols []string, callback func(*Ticker)) (Stream, error)
+	// SubscribeDepth 订阅订单簿深度增量推送，返回的连接需调用 Start 后开始接收
+	SubscribeDepth(symbols []string, callback func(*DepthUpdate)) (Stream, error)
+}
+
+// New 按交易所名称创建适配器，passphrase 仅 OKX 使用，recvWindow 仅 Binance 使用
+func New(name, apiKey, apiSecret, passphrase string, isTestnet bool, recvWindow int64) (Exchange, error) {
+	switch strings.ToLower(name) {
+	case "binance":
+		return NewBinanceClient(apiKey, apiSecret, isTestnet, recvWindow), nil
+	case "okx", "okex":
+		return NewOKXClient(apiKey, apiSecret, passphrase, isTestnet), nil
+	case "bybit":
+		return NewBybitClient(apiKey, apiSecret, isTestnet), nil
+	default:
+		return nil, fmt.Errorf("不支持的交易所: %s", name)
+	}
+}
+
+// Stream 行情推送连接
+type Stream interface {
+	// Start 启动连接，断线后自动重连
+	Start()
+	// Stop 关闭连接并停止重连
+	Stop()
+	// IsConnected 检查连接是否可用
+	IsConnected() bool
+	// SetStateHandler 设置连接状态变化回调，需在 Start 之前调用
+	SetStateHandler(handler func(connected bool))
+}
+
+// OrderSubscriber 支持私有订单推送的交易所
+type OrderSubscriber interface {
+	// SubscribeOrders 订阅订单状态变化推送，返回的连接需调用 Start 后开始接收
+	SubscribeOrders(callback func(*Order)) (Stream, error)
+}
+
+// UserDataSubscriber 通过同一个连接推送订单、成交和余额变化的交易所
+type UserDataSubscriber interface {
+	// SubscribeUserData 订阅用户数据推送，返回的连接需调用 Start 后开始接收
+	SubscribeUserData(handler UserDataHandler) (Stream, error)
+}
+
+// UserDataHandler 用户数据推送回调，不需要的回调可以为nil
+type UserDataHandler struct {
+	OnOrder     func(*Order)
+	OnExecution func(*Execution)
+	OnBalances  func([]*Balance) // 只包含发生变化的资产
+}
+
+// RateLimited 按请求频率限额节流的交易所，可查询当前使用情况
+type RateLimited interface {
+	RateLimitUsage() RateLimitUsage
+}
+
+// ClientOrderQuerier 可按客户端订单ID查询订单的交易所，下单结果未知时据此确认订单是否已被受理
+type ClientOrderQuerier interface {
+	// GetOrderByClientID 按客户端订单ID查询订单，订单不存在时返回的错误满足 IsOrderNotFound
+	GetOrderByClientID(symbol, clientOrderID string) (*Order, error)
+}
+
+// ErrOrderNotFound 交易所确认订单不存在
+var ErrOrderNotFound = errors.New("订单不存在")
+
+// FeeProvider 可查询账户手续费率的交易所
+type FeeProvider interface {
+	// GetTradeFees 获取账户的手续费率，Symbol 为空的一项适用于未单独列出的交易对
+	GetTradeFees() ([]*TradeFee, error)
+}
+
+// SymbolFeeProvider 可查询单个交易对手续费率和抵扣设置的交易所
+// 抵扣是否开启按交易对设置，账户费率列表中没有时通过该接口逐个查询
+type SymbolFeeProvider interface {
+	// GetTradeFee 获取交易对的手续费率，未开启抵扣时 DiscountAsset 为空
+	GetTradeFee(symbol string) (*TradeFee, error)
+}
+
+// TradeFee 交易对手续费率，均为小数（0.001 表示 0.1%），已包含账户的VIP等级
+type TradeFee struct {
+	Symbol        string
+	Maker         float64
+	Taker         float64
+	DiscountAsset string  // 可用于抵扣手续费的资产（例如 BNB），为空表示未开启抵扣
+	DiscountMaker float64 // 使用 DiscountAsset 支付时的挂单费率
+	DiscountTaker float64 // 使用 DiscountAsset 支付时的吃单费率
+}
+
+// RateLimitUsage 请求频率限额使用情况
+type RateLimitUsage struct {
+	Weight        int // 当前分钟已用请求权重
+	WeightLimit   int
+	Orders10s     int // 当前10秒已下单数
+	OrderLimit10s int
+	OrdersDay     int // 当天已下单数
+	OrderLimitDay int
+	BlockedUntil  time.Time // 被限流时恢复请求的时间，未被限流时为零值
+}
+
+// CanPlaceOrders 检查剩余额度是否足够连续下 n 个订单
+func (u RateLimitUsage) CanPlaceOrders(n int) bool {
+	if !u.BlockedUntil.IsZero() {
+		return false
+	}
+	return u.Orders10s+n <= u.OrderLimit10s && u.OrdersDay+n <= u.OrderLimitDay
+}
+
+// SymbolStatusTrading 交易对可交易状态
+const SymbolStatusTrading = "TRADING"
+
+// 订单状态
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusRejected        = "REJECTED"
+	OrderStatusExpired         = "EXPIRED"
+)
+
+// 订单类型
+const (
+	OrderTypeLimit      = "LIMIT"
+	OrderTypeMarket     = "MARKET"
+	OrderTypeLimitMaker = "LIMIT_MAKER" // 只做maker的限价单，会立即成交时被交易所拒绝
+)
+
+// 限价单有效方式
+const (
+	TimeInForceGTC = "GTC" // 成交为止
+	TimeInForceIOC = "IOC" // 立即成交可成交部分，剩余部分撤销
+	TimeInForceFOK = "FOK" // 全部立即成交，否则全部撤销
+)
+
+// OrderRequest 下单请求
+type OrderRequest struct {
+	Symbol        string
+	Side          string  // BUY, SELL
+	Type          string  // 见 OrderType 常量
+	TimeInForce   string  // 仅 LIMIT 使用，为空时按 GTC
+	Quantity      float64 // 基础资产数量
+	QuoteQuantity float64 // 仅 MARKET 使用，按报价资产金额下单，与 Quantity 二选一
+	Price         float64 // LIMIT 和 LIMIT_MAKER 的价格
+	ClientOrderID string  // 客户端订单ID，为空时由适配器生成或不指定
+}
+
+// Validate 检查下单参数组合是否有效
+func (r *OrderRequest) Validate() error {
+	if r.Symbol == "" {
+		return fmt.Errorf("交易对不能为空")
+	}
+	if r.Side != "BUY" && r.Side != "SELL" {
+		return fmt.Errorf("无效的订单方向: %s", r.Side)
+	}
+
+	switch r.Type {
+	case OrderTypeLimit, OrderTypeLimitMaker:
+		if r.Quantity <= 0 || r.Price <= 0 {
+			return fmt.Errorf("%s 订单需要数量和价格", r.Type)
+		}
+		if r.QuoteQuantity > 0 {
+			return fmt.Errorf("%s 订单不支持按报价资产金额下单", r.Type)
+		}
+		if r.Type == OrderTypeLimitMaker && r.TimeInForce != "" {
+			return fmt.Errorf("LIMIT_MAKER 订单不支持指定有效方式")
+		}
+		switch r.TimeInForce {
+		case "", TimeInForceGTC, TimeInForceIOC, TimeInForceFOK:
+		default:
+			return fmt.Errorf("无效的有效方式: %s", r.TimeInForce)
+		}
+	case OrderTypeMarket:
+		if (r.Quantity > 0) == (r.QuoteQuantity > 0) {
+			return fmt.Errorf("市价单需要且只能指定数量或报价资产金额之一")
+		}
+		if r.TimeInForce != "" {
+			return fmt.Errorf("市价单不支持指定有效方式")
+		}
+	default:
+		return fmt.Errorf("不支持的订单类型: %s", r.Type)
+	}
+	return nil
+}
+
+// TimeInForceOrDefault 限价单的有效方式，未指定时为 GTC
+func (r *OrderRequest) TimeInForceOrDefault() string {
+	if r.TimeInForce == "" {
+		return TimeInForceGTC
+	}
+	return r.TimeInForce
+}
+
+// SymbolInfo 交易对信息
+type SymbolInfo struct {
+	Symbol      string
+	Status      string // SymbolStatusTrading 表示可交易
+	BaseAsset   string
+	QuoteAsset  string
+	TickSize    float64 // 价格步长
+	StepSize    float64 // 数量步长
+	MinQty      float64 // 最小下单数量
+	MaxQty      float64 // 最大下单数量，0 表示不限制
+	MinNotional float64 // 最小成交额
+}
+
+// Ticker 行情数据
+type Ticker struct {
+	Symbol             string
+	BidPrice           float64
+	BidQty             float64
+	AskPrice           float64
+	AskQty             float64
+	LastPrice          float64
+	Volume             float64 // 24小时成交量（基础资产）
+	QuoteVolume        float64 // 24小时成交额（报价资产）
+	PriceChangePercent float64 // 24小时涨跌幅
+	UpdatedAt          time.Time
+}
+
+// Balance 资产余额
+type Balance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// Order 订单信息
+type Order struct {
+	Symbol           string
+	OrderID          string
+	ClientOrderID    string
+	Side             string // BUY, SELL
+	Type             string // LIMIT, MARKET
+	TimeInForce      string
+	Price            float64
+	OrigQty          float64
+	ExecutedQty      float64
+	ExecutedQuoteQty float64 // 累计成交额（报价资产）
+	Status           string  // 见 OrderStatus 常量
+	Time             time.Time
+	UpdateTime       time.Time
+	Fills            []*Execution // 下单时立即成交的明细，只有下单响应包含成交明细的交易所返回
+}
+
+// DepthUpdate 订单簿深度增量推送
+type DepthUpdate struct {
+	Symbol        string
+	FirstUpdateID int64 // U
+	FinalUpdateID int64 // u
+	Snapshot      bool  // 为 true 时是完整快照，应替换本地订单簿
+	Bids          []OrderBookLevel
+	Asks          []OrderBookLevel
+	EventTime     time.Time
+}
+
+// Execution 成交明细，一个订单可能对应多笔成交
+type Execution struct {
+	Symbol          string
+	OrderID         string
+	ClientOrderID   string
+	TradeID         string
+	Side            string // BUY, SELL
+	Price           float64
+	Quantity        float64
+	Commission      float64
+	CommissionAsset string
+	IsMaker         bool
+	Time            time.Time
+}
@@ -0,0 +1,266 @@
+package exchange
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 无法查询账户费率时使用的默认费率（0.1%）
+const (
+	DefaultMakerFee = 0.001
+	DefaultTakerFee = 0.001
+)
+
+// feeRefreshInterval 重新查询账户费率的间隔，VIP等级按日调整
+const feeRefreshInterval = time.Hour
+
+// feeLookupInterval 逐个查询交易对费率的间隔，单个交易对的查询权重较高，避免挤占行情和下单的请求额度
+const feeLookupInterval = 500 * time.Millisecond
+
+// FeeRate 一笔成交适用的手续费率
+type FeeRate struct {
+	Rate  float64 // 费率，0.001 表示 0.1%
+	Asset string  // 支付手续费的资产，为空表示从成交所得资产中扣除
+}
+
+// FeeModel 手续费模型
+// 从交易所查询账户各交易对的挂单/吃单费率（含VIP等级），查询失败或交易所不支持时使用默认费率。
+// BNB抵扣按交易对单独设置，通过 Track 对参与交易的交易对逐个查询；
+// 开启抵扣且抵扣资产有余额时，手续费以抵扣资产支付，不减少成交所得
+type FeeModel struct {
+	client     Exchange
+	mu         sync.RWMutex
+	fees       map[string]*TradeFee
+	symbolFees map[string]*TradeFee       // 逐个查询的交易对费率（含抵扣设置），优先于 fees
+	tracked    map[string]bool            // 需要逐个查询费率的交易对
+	queue      []string                   // 等待查询的交易对
+	loading    bool                       // 是否正在逐个查询
+	accountFee *TradeFee                  // 未单独列出的交易对使用的费率
+	balance    func(asset string) float64 // 抵扣资产的可用余额，为nil时不使用抵扣
+	updatedAt  time.Time
+	stopChan   chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewFeeModel 创建手续费模型，client 为nil时始终使用默认费率
+func NewFeeModel(client Exchange) *FeeModel {
+	return &FeeModel{
+		client:     client,
+		fees:       make(map[string]*TradeFee),
+		symbolFees: make(map[string]*TradeFee),
+		tracked:    make(map[string]bool),
+		accountFee: &TradeFee{Maker: DefaultMakerFee, Taker: DefaultTakerFee},
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// NewFixedFeeModel 创建全部交易对使用固定费率的手续费模型
+func NewFixedFeeModel(maker, taker float64) *FeeModel {
+	model := NewFeeModel(nil)
+	model.accountFee = &TradeFee{Maker: maker, Taker: taker}
+	return model
+}
+
+// Start 查询一次账户费率并定期刷新，查询失败时继续使用默认费率
+func (m *FeeModel) Start() error {
+	err := m.Refresh()
+	go m.refreshLoop()
+	return err
+}
+
+// Stop 停止定期刷新
+func (m *FeeModel) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+	})
+}
+
+// refreshLoop 定期刷新账户费率
+func (m *FeeModel) refreshLoop() {
+	ticker := time.NewTicker(feeRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stopChan:
+			return
+		case <-ticker.C:
+			if err := m.Refresh(); err != nil {
+				log.Printf("刷新手续费率失败: %v", err)
+			}
+		}
+	}
+}
+
+// Refresh 从交易所查询账户费率，并重新逐个查询已跟踪的交易对
+func (m *FeeModel) Refresh() error {
+	provider, ok := m.client.(FeeProvider)
+	if !ok {
+		return nil
+	}
+
+	fees, err := provider.GetTradeFees()
+	if err != nil {
+		return fmt.Errorf("查询手续费率失败: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fees = make(map[string]*TradeFee, len(fees))
+	for _, fee := range fees {
+		if fee.Symbol == "" {
+			m.accountFee = fee
+			continue
+		}
+		m.fees[fee.Symbol] = fee
+	}
+	m.updatedAt = time.Now()
+
+	m.queue = m.queue[:0]
+	for symbol := range m.tracked {
+		m.queue = append(m.queue, symbol)
+	}
+	m.startLookups()
+
+	log.Printf("✓ 已加载 %s 手续费率: %d 个交易对", m.client.Name(), len(fees))
+	return nil
+}
+
+// Track 在后台逐个查询交易对的费率和抵扣设置，已跟踪的交易对忽略，交易所不支持时不做任何事
+// 查询完成前使用账户费率列表中不含抵扣的费率
+func (m *FeeModel) Track(symbols []string) {
+	if _, ok := m.client.(SymbolFeeProvider); !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, symbol := range symbols {
+		if !m.tracked[symbol] {
+			m.tracked[symbol] = true
+			m.queue = append(m.queue, symbol)
+		}
+	}
+	m.startLookups()
+}
+
+// startLookups 有等待查询的交易对且未在查询时启动查询goroutine，调用方需持有写锁
+func (m *FeeModel) startLookups() {
+	if m.loading || len(m.queue) == 0 {
+		return
+	}
+	if _, ok := m.client.(SymbolFeeProvider); !ok {
+		return
+	}
+	m.loading = true
+	go m.lookupLoop()
+}
+
+// lookupLoop 按 feeLookupInterval 逐个查询等待中的交易对，查询失败的交易对取消跟踪，下次 Track 时重试
+func (m *FeeModel) lookupLoop() {
+	provider := m.client.(SymbolFeeProvider)
+
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 {
+			m.loading = false
+			m.mu.Unlock()
+			return
+		}
+		symbol := m.queue[0]
+		m.queue = m.queue[1:]
+		m.mu.Unlock()
+
+		fee, err := provider.GetTradeFee(symbol)
+
+		m.mu.Lock()
+		if err != nil {
+			log.Printf("查询 %s 手续费率失败: %v", symbol, err)
+			delete(m.tracked, symbol)
+		} else if m.tracked[symbol] {
+			m.symbolFees[symbol] = fee
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-m.stopChan:
+			m.mu.Lock()
+			m.loading = false
+			m.mu.Unlock()
+			return
+		case <-time.After(feeLookupInterval):
+		}
+	}
+}
+
+// SetBalanceSource 设置抵扣资产可用余额的来源，未设置时不使用抵扣
+func (m *FeeModel) SetBalanceSource(balance func(asset string) float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balance = balance
+}
+
+// UpdatedAt 最近一次成功查询账户费率的时间，从未查询成功时为零值
+func (m *FeeModel) UpdatedAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.updatedAt
+}
+
+// Fee 获取交易对的费率，优先使用逐个查询的费率，未单独列出时返回账户费率
+func (m *FeeModel) Fee(symbol string) TradeFee {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if fee, ok := m.symbolFees[symbol]; ok {
+		return *fee
+	}
+	if fee, ok := m.fees[symbol]; ok {
+		return *fee
+	}
+	fee := *m.accountFee
+	fee.Symbol = symbol
+	return fee
+}
+
+// Rate 获取一笔成交适用的费率，开启抵扣且抵扣资产有可用余额时返回抵扣后的费率
+func (m *FeeModel) Rate(symbol string, maker bool) FeeRate {
+	fee := m.Fee(symbol)
+	if fee.DiscountAsset != "" && m.DiscountBalance(fee.DiscountAsset) > 0 {
+		if maker {
+			return FeeRate{Rate: fee.DiscountMaker, Asset: fee.DiscountAsset}
+		}
+		return FeeRate{Rate: fee.DiscountTaker, Asset: fee.DiscountAsset}
+	}
+	return m.StandardRate(symbol, maker)
+}
+
+// StandardRate 获取从成交所得资产中扣除时的费率
+func (m *FeeModel) StandardRate(symbol string, maker bool) FeeRate {
+	fee := m.Fee(symbol)
+	if maker {
+		return FeeRate{Rate: fee.Maker}
+	}
+	return FeeRate{Rate: fee.Taker}
+}
+
+// Taker 获取交易对的吃单费率
+func (m *FeeModel) Taker(symbol string) float64 {
+	return m.Rate(symbol, false).Rate
+}
+
+// DiscountBalance 获取抵扣资产的可用余额，未设置余额来源时为0
+func (m *FeeModel) DiscountBalance(asset string) float64 {
+	m.mu.RLock()
+	balance := m.balance
+	m.mu.RUnlock()
+
+	if balance == nil {
+		return 0
+	}
+	return balance(asset)
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+
+	"inarbit/exchange"
 )
 
 // APIHandler API处理器
@@ -17,14 +19,16 @@ type APIHandler struct {
 	db          *Database
 	authService *AuthService
 	wsManager   *WebSocketManager
+	botManager  *BotManager
 }
 
 // NewAPIHandler 创建API处理器
-func NewAPIHandler(db *Database, authService *AuthService, wsManager *WebSocketManager) *APIHandler {
+func NewAPIHandler(db *Database, authService *AuthService, wsManager *WebSocketManager, botManager *BotManager) *APIHandler {
 	return &APIHandler{
 		db:          db,
 		authService: authService,
 		wsManager:   wsManager,
+		botManager:  botManager,
 	}
 }
 
@@ -54,6 +58,9 @@ func (h *APIHandler) RegisterRoutes(router *mux.Router) {
 	router.HandleFunc("/api/exchanges", h.AuthMiddleware(h.CreateExchange)).Methods("POST")
 	router.HandleFunc("/api/exchanges/{id}", h.AuthMiddleware(h.DeleteExchange)).Methods("DELETE")
 
+	// 账户路由
+	router.HandleFunc("/api/account/balances", h.AuthMiddleware(h.GetAccountBalances)).Methods("GET")
+
 	// WebSocket路由
 	router.HandleFunc("/ws", h.HandleWebSocket).Methods("GET")
 
@@ -228,6 +235,10 @@ func (h *APIHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
 		h.RespondError(w, http.StatusBadRequest, "缺少必要参数")
 		return
 	}
+	if err := h.validateHedgeExchange(userID, req.StrategyType, req.ExchangeID, req.HedgeExchangeID); err != nil {
+		h.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	// 设置默认值
 	if req.UpdateFrequency == 0 {
@@ -239,6 +250,7 @@ func (h *APIHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
 		Name:            req.Name,
 		StrategyType:    req.StrategyType,
 		ExchangeID:      req.ExchangeID,
+		HedgeExchangeID: req.HedgeExchangeID,
 		IsRunning:       false,
 		IsSimulation:    req.IsSimulation,
 		UpdateFrequency: req.UpdateFrequency,
@@ -294,11 +306,19 @@ func (h *APIHandler) UpdateBot(w http.ResponseWriter, r *http.Request) {
 	if req.ExchangeID != 0 {
 		bot.ExchangeID = req.ExchangeID
 	}
+	if req.HedgeExchangeID != nil {
+		bot.HedgeExchangeID = req.HedgeExchangeID
+	}
 	if req.UpdateFrequency > 0 {
 		bot.UpdateFrequency = req.UpdateFrequency
 	}
 	bot.IsSimulation = req.IsSimulation
 
+	if err := h.validateHedgeExchange(userID, bot.StrategyType, bot.ExchangeID, bot.HedgeExchangeID); err != nil {
+		h.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	err = h.db.UpdateBot(bot)
 	if err != nil {
 		log.Printf("更新机器人失败: %v", err)
@@ -311,6 +331,25 @@ func (h *APIHandler) UpdateBot(w http.ResponseWriter, r *http.Request) {
 	h.RespondSuccess(w, http.StatusOK, "更新机器人成功", bot)
 }
 
+// validateHedgeExchange 校验 cross_exchange 策略的对冲交易所：必须指定、与主交易所不同且属于当前用户
+func (h *APIHandler) validateHedgeExchange(userID int64, strategyType string, exchangeID int64, hedgeExchangeID *int64) error {
+	if strategyType != StrategyCrossExchange {
+		return nil
+	}
+	if hedgeExchangeID == nil || *hedgeExchangeID == 0 {
+		return fmt.Errorf("跨交易所策略需要指定对冲交易所")
+	}
+	if *hedgeExchangeID == exchangeID {
+		return fmt.Errorf("对冲交易所不能与主交易所相同")
+	}
+	for _, id := range []int64{exchangeID, *hedgeExchangeID} {
+		if _, err := h.db.GetExchangeByID(id, userID); err != nil {
+			return fmt.Errorf("交易所配置 %d 不存在", id)
+		}
+	}
+	return nil
+}
+
 // DeleteBot 删除机器人
 func (h *APIHandler) DeleteBot(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.GetUserID(r)
@@ -530,13 +569,20 @@ func (h *APIHandler) CreateExchange(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 名称决定使用的交易所适配器
+	if _, err := exchange.New(req.Name, req.APIKey, req.APISecret, req.Passphrase, req.IsTestnet, exchange.BinanceDefaultRecvWindow); err != nil {
+		h.RespondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	exchange := &Exchange{
-		UserID:    userID,
-		Name:      req.Name,
-		APIKey:    req.APIKey,
-		APISecret: req.APISecret,
-		IsTestnet: req.IsTestnet,
-		IsActive:  true,
+		UserID:     userID,
+		Name:       req.Name,
+		APIKey:     req.APIKey,
+		APISecret:  req.APISecret,
+		Passphrase: req.Passphrase,
+		IsTestnet:  req.IsTestnet,
+		IsActive:   true,
 	}
 
 	err = h.db.CreateExchange(exchange)
@@ -577,6 +623,28 @@ func (h *APIHandler) DeleteExchange(w http.ResponseWriter, r *http.Request) {
 	h.RespondSuccess(w, http.StatusOK, "删除交易所成功", nil)
 }
 
+// ===== 账户处理器 =====
+
+// GetAccountBalances 获取各资产的可用、冻结和进行中交易占用的余额
+func (h *APIHandler) GetAccountBalances(w http.ResponseWriter, r *http.Request) {
+	if h.botManager == nil {
+		h.RespondError(w, http.StatusServiceUnavailable, "账户数据不可用")
+		return
+	}
+
+	balances, err := h.botManager.AccountBalances()
+	if err != nil {
+		log.Printf("获取账户余额失败: %v", err)
+		h.RespondError(w, http.StatusServiceUnavailable, "获取账户余额失败")
+		return
+	}
+
+	h.RespondSuccess(w, http.StatusOK, "获取账户余额成功", map[string]interface{}{
+		"balances":     balances,
+		"reservations": h.botManager.BalanceReservations(),
+	})
+}
+
 // ===== WebSocket处理器 =====
 
 var upgrader = websocket.Upgrader{
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// localOrderBookSnapshotLimit 同步本地订单簿时获取的快照档位数量
+const localOrderBookSnapshotLimit = 1000
+
+// localOrderBookBufferSize 等待快照期间缓存的增量事件上限
+const localOrderBookBufferSize = 1000
+
+// errOrderBookGap 增量事件的更新序号出现缺口，需要重新同步
+var errOrderBookGap = errors.New("订单簿增量事件不连续")
+
+// LocalOrderBook 本地维护的完整订单簿
+// 按 Binance 文档流程同步：先订阅 @depth 增量流并缓存事件，再获取REST快照，
+// 丢弃 u <= lastUpdateId 的事件，之后每个事件须满足 U <= lastUpdateId+1 <= u，出现缺口时重新同步
+type LocalOrderBook struct {
+	symbol       string
+	mu           sync.RWMutex
+	bids         map[float64]float64 // 价格 -> 数量
+	asks         map[float64]float64
+	lastUpdateID int64
+	synced       bool
+	buffer       []*exchange.DepthUpdate // 未同步期间缓存的增量事件
+	updatedAt    time.Time
+}
+
+// NewLocalOrderBook 创建本地订单簿（初始为未同步状态）
+func NewLocalOrderBook(symbol string) *LocalOrderBook {
+	return &LocalOrderBook{
+		symbol: symbol,
+		bids:   make(map[float64]float64),
+		asks:   make(map[float64]float64),
+	}
+}
+
+// Apply 应用增量事件
+// 未同步时只缓存事件；已同步时序号出现缺口返回 errOrderBookGap，订单簿回到未同步状态并开始缓存。
+// 推送的完整快照（例如 OKX）直接替换订单簿并进入同步状态
+func (b *LocalOrderBook) Apply(update *exchange.DepthUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if update.Snapshot {
+		b.bids = make(map[float64]float64, len(update.Bids))
+		b.asks = make(map[float64]float64, len(update.Asks))
+		applyLevels(b.bids, update.Bids)
+		applyLevels(b.asks, update.Asks)
+		b.lastUpdateID = update.FinalUpdateID
+		b.updatedAt = time.Now()
+		b.synced = true
+		b.buffer = nil
+		return nil
+	}
+
+	if !b.synced {
+		b.buffer = append(b.buffer, update)
+		if len(b.buffer) > localOrderBookBufferSize {
+			b.buffer = b.buffer[len(b.buffer)-localOrderBookBufferSize:]
+		}
+		return nil
+	}
+
+	return b.applyLocked(update)
+}
+
+// LoadSnapshot 加载REST快照并依次应用缓存的增量事件
+// 快照早于缓存事件（中间存在缺口）时返回 errOrderBookGap，缓存的事件会保留，等待重新获取快照
+func (b *LocalOrderBook) LoadSnapshot(snapshot *exchange.OrderBook) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = make(map[float64]float64, len(snapshot.Bids))
+	b.asks = make(map[float64]float64, len(snapshot.Asks))
+	applyLevels(b.bids, snapshot.Bids)
+	applyLevels(b.asks, snapshot.Asks)
+	b.lastUpdateID = snapshot.LastUpdateID
+	b.updatedAt = time.Now()
+	b.synced = true
+
+	buffered := b.buffer
+	b.buffer = nil
+	for i, update := range buffered {
+		if err := b.applyLocked(update); err != nil {
+			b.buffer = append(b.buffer, buffered[i:]...)
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset 清空订单簿并回到未同步状态
+func (b *LocalOrderBook) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = make(map[float64]float64)
+	b.asks = make(map[float64]float64)
+	b.lastUpdateID = 0
+	b.synced = false
+	b.buffer = nil
+}
+
+// applyLocked 应用单个增量事件（调用方需持有写锁）
+func (b *LocalOrderBook) applyLocked(update *exchange.DepthUpdate) error {
+	// 已包含在快照中，或连接切换期间的重复推送
+	if update.FinalUpdateID <= b.lastUpdateID {
+		return nil
+	}
+	if update.FirstUpdateID > b.lastUpdateID+1 {
+		b.synced = false
+		b.buffer = nil
+		return errOrderBookGap
+	}
+
+	applyLevels(b.bids, update.Bids)
+	applyLevels(b.asks, update.Asks)
+	b.lastUpdateID = update.FinalUpdateID
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// IsSynced 检查订单簿是否已同步
+func (b *LocalOrderBook) IsSynced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// Snapshot 获取最优的 limit 档深度，LastUpdateID 为当前序号；未同步时返回nil
+func (b *LocalOrderBook) Snapshot(limit int) *exchange.OrderBook {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if !b.synced {
+		return nil
+	}
+
+	return &exchange.OrderBook{
+		Symbol:       b.symbol,
+		LastUpdateID: b.lastUpdateID,
+		Bids:         topLevels(b.bids, limit, true),
+		Asks:         topLevels(b.asks, limit, false),
+		UpdatedAt:    b.updatedAt,
+	}
+}
+
+// applyLevels 更新档位，数量为0表示删除该价格
+func applyLevels(side map[float64]float64, levels []exchange.OrderBookLevel) {
+	for _, level := range levels {
+		if level.Quantity == 0 {
+			delete(side, level.Price)
+		} else {
+			side[level.Price] = level.Quantity
+		}
+	}
+}
+
+// topLevels 按价格排序取前 limit 档，descending 为 true 时从高到低
+func topLevels(side map[float64]float64, limit int, descending bool) []exchange.OrderBookLevel {
+	levels := make([]exchange.OrderBookLevel, 0, len(side))
+	for price, qty := range side {
+		levels = append(levels, exchange.OrderBookLevel{Price: price, Quantity: qty})
+	}
+
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+
+	if limit > 0 && len(levels) > limit {
+		levels = levels[:limit]
+	}
+	return levels
+}
@@ -3,31 +3,55 @@ package main
 import (
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"time"
+
+	"inarbit/exchange"
 )
 
+// orderBookDepthLimit 获取订单簿时的档位数量
+const orderBookDepthLimit = 100
+
+// tickerStatsInterval 行情流正常时刷新24小时统计数据的间隔
+const tickerStatsInterval = 1 * time.Minute
+
+// orderBookResyncDelay 获取订单簿快照前等待增量事件缓存的时间
+const orderBookResyncDelay = 1 * time.Second
+
 // MarketManager 行情管理器
 type MarketManager struct {
-	client        *BinanceClient
-	tickers       map[string]*Ticker     // 交易对行情缓存
-	symbolInfo    map[string]*SymbolInfo // 交易对信息缓存
+	client        exchange.Exchange
+	tickers       map[string]*exchange.Ticker     // 交易对行情缓存
+	symbolInfo    map[string]*exchange.SymbolInfo // 交易对信息缓存
+	orderBooks    map[string]*exchange.OrderBook  // 订单簿深度缓存
+	tradingRules  map[string]*TradingRules        // 交易对下单规则缓存
 	mu            sync.RWMutex
 	updateTicker  time.Duration
 	lastUpdate    time.Time
 	stopChan      chan struct{}
-	updateChan    chan *Ticker
+	updateChan    chan *exchange.Ticker
+	stream        exchange.Stream            // 盘口行情推送连接
+	streamActive  bool                       // 行情流是否正常，断开时回退到REST轮询
+	localBooks    map[string]*LocalOrderBook // 本地维护的订单簿
+	depthStream   exchange.Stream            // 深度增量推送连接
+	resyncing     map[string]bool            // 正在重新同步的订单簿
+	symbolVersion uint64                     // 交易对集合每次变化时递增
 }
 
 // NewMarketManager 创建行情管理器
-func NewMarketManager(client *BinanceClient, updateInterval time.Duration) *MarketManager {
+func NewMarketManager(client exchange.Exchange, updateInterval time.Duration) *MarketManager {
 	return &MarketManager{
 		client:       client,
-		tickers:      make(map[string]*Ticker),
-		symbolInfo:   make(map[string]*SymbolInfo),
+		tickers:      make(map[string]*exchange.Ticker),
+		symbolInfo:   make(map[string]*exchange.SymbolInfo),
+		orderBooks:   make(map[string]*exchange.OrderBook),
+		tradingRules: make(map[string]*TradingRules),
+		localBooks:   make(map[string]*LocalOrderBook),
+		resyncing:    make(map[string]bool),
 		updateTicker: updateInterval,
 		stopChan:     make(chan struct{}),
-		updateChan:   make(chan *Ticker, 100),
+		updateChan:   make(chan *exchange.Ticker, 4096), // 行情流推送频繁，预留足够缓冲
 	}
 }
 
@@ -38,6 +62,12 @@ func (m *MarketManager) Start() error {
 		return fmt.Errorf("初始化交易对信息失败: %w", err)
 	}
 
+	// 先通过REST获取完整行情，再由WebSocket推送盘口更新
+	if err := m.updateAllTickers(); err != nil {
+		log.Printf("初始化行情失败: %v", err)
+	}
+	m.startStream()
+
 	// 启动定期更新goroutine
 	go m.updateLoop()
 
@@ -47,48 +77,149 @@ func (m *MarketManager) Start() error {
 
 // Stop 停止行情管理器
 func (m *MarketManager) Stop() {
+	if m.stream != nil {
+		m.stream.Stop()
+	}
+	if m.depthStream != nil {
+		m.depthStream.Stop()
+	}
 	close(m.stopChan)
 	log.Println("✓ 行情管理器已停止")
 }
 
 // initSymbolInfo 初始化交易对信息
 func (m *MarketManager) initSymbolInfo() error {
-	info, err := m.client.GetExchangeInfo()
+	symbols, err := m.client.GetSymbols()
 	if err != nil {
 		return err
 	}
 
+	symbolInfo := make(map[string]*exchange.SymbolInfo, len(symbols))
+	tradingRules := make(map[string]*TradingRules, len(symbols))
+	for _, symbol := range symbols {
+		if symbol.Status == exchange.SymbolStatusTrading {
+			symbolInfo[symbol.Symbol] = symbol
+			tradingRules[symbol.Symbol] = newTradingRules(symbol)
+		}
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for i, symbol := range info.Symbols {
-		if symbol.Status == "TRADING" {
-			m.symbolInfo[symbol.Symbol] = &info.Symbols[i]
-		}
+	// 下架的交易对随重新加载移除，交易对集合变化时递增版本号，套利引擎据此重建货币图
+	if !sameSymbols(m.symbolInfo, symbolInfo) {
+		m.symbolVersion++
 	}
+	m.symbolInfo = symbolInfo
+	m.tradingRules = tradingRules
 
 	log.Printf("✓ 已加载 %d 个交易对信息", len(m.symbolInfo))
 	return nil
 }
 
+// sameSymbols 判断两组交易对信息的交易对及其基础资产、报价资产是否相同
+func sameSymbols(a, b map[string]*exchange.SymbolInfo) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for symbol, info := range a {
+		other, ok := b[symbol]
+		if !ok || other.BaseAsset != info.BaseAsset || other.QuoteAsset != info.QuoteAsset {
+			return false
+		}
+	}
+	return true
+}
+
+// startStream 订阅全市场盘口行情流
+func (m *MarketManager) startStream() {
+	stream, err := m.client.SubscribeTickers(nil, m.applyBookTicker)
+	if err != nil {
+		log.Printf("订阅行情流失败，使用REST轮询: %v", err)
+		return
+	}
+
+	stream.SetStateHandler(func(connected bool) {
+		m.mu.Lock()
+		m.streamActive = connected
+		m.mu.Unlock()
+
+		if connected {
+			log.Println("✓ 行情流已连接")
+		} else {
+			log.Println("行情流已断开，回退到REST轮询")
+		}
+	})
+
+	m.stream = stream
+	stream.Start()
+}
+
+// IsStreamActive 检查行情流是否正常
+func (m *MarketManager) IsStreamActive() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.streamActive
+}
+
 // updateLoop 定期更新行情
+// 行情流正常时只定期刷新24小时统计数据，断开时按更新周期轮询REST接口
 func (m *MarketManager) updateLoop() {
 	ticker := time.NewTicker(m.updateTicker)
 	defer ticker.Stop()
 
+	statsTicker := time.NewTicker(tickerStatsInterval)
+	defer statsTicker.Stop()
+
 	for {
 		select {
 		case <-m.stopChan:
 			return
 
 		case <-ticker.C:
+			if m.IsStreamActive() {
+				continue
+			}
 			if err := m.updateAllTickers(); err != nil {
 				log.Printf("更新行情失败: %v", err)
 			}
+
+		case <-statsTicker.C:
+			if !m.IsStreamActive() {
+				continue
+			}
+			if err := m.updateAllTickers(); err != nil {
+				log.Printf("更新行情统计失败: %v", err)
+			}
 		}
 	}
 }
 
+// applyBookTicker 应用行情流推送的盘口数据
+// 已发布的行情对象不再修改，复制后替换，读取方无需加锁
+func (m *MarketManager) applyBookTicker(ts *exchange.Ticker) {
+	m.mu.Lock()
+	updated := &exchange.Ticker{Symbol: ts.Symbol}
+	if existing, ok := m.tickers[ts.Symbol]; ok {
+		*updated = *existing
+	}
+	updated.BidPrice = ts.BidPrice
+	updated.BidQty = ts.BidQty
+	updated.AskPrice = ts.AskPrice
+	updated.AskQty = ts.AskQty
+	updated.UpdatedAt = ts.UpdatedAt
+	m.tickers[ts.Symbol] = updated
+	m.lastUpdate = ts.UpdatedAt
+	m.mu.Unlock()
+
+	// 发送更新事件
+	select {
+	case m.updateChan <- updated:
+	default:
+		// 通道满，跳过
+	}
+}
+
 // updateAllTickers 更新所有交易对行情
 func (m *MarketManager) updateAllTickers() error {
 	tickers, err := m.client.GetAllTickers()
@@ -100,6 +231,14 @@ func (m *MarketManager) updateAllTickers() error {
 	defer m.mu.Unlock()
 
 	for _, ticker := range tickers {
+		// 行情流推送的盘口更及时，只刷新24小时统计数据
+		if existing, ok := m.tickers[ticker.Symbol]; ok && m.streamActive {
+			ticker.BidPrice, ticker.BidQty = existing.BidPrice, existing.BidQty
+			ticker.AskPrice, ticker.AskQty = existing.AskPrice, existing.AskQty
+			m.tickers[ticker.Symbol] = ticker
+			continue
+		}
+
 		m.tickers[ticker.Symbol] = ticker
 
 		// 发送更新事件
@@ -115,18 +254,18 @@ func (m *MarketManager) updateAllTickers() error {
 }
 
 // GetTicker 获取交易对行情
-func (m *MarketManager) GetTicker(symbol string) *Ticker {
+func (m *MarketManager) GetTicker(symbol string) *exchange.Ticker {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.tickers[symbol]
 }
 
 // GetTickers 获取多个交易对行情
-func (m *MarketManager) GetTickers(symbols []string) map[string]*Ticker {
+func (m *MarketManager) GetTickers(symbols []string) map[string]*exchange.Ticker {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	result := make(map[string]*Ticker)
+	result := make(map[string]*exchange.Ticker)
 	for _, symbol := range symbols {
 		if ticker, ok := m.tickers[symbol]; ok {
 			result[symbol] = ticker
@@ -135,8 +274,171 @@ func (m *MarketManager) GetTickers(symbols []string) map[string]*Ticker {
 	return result
 }
 
+// GetOrderBook 获取最优 orderBookDepthLimit 档深度，LastUpdateID 为订单簿序号
+// 优先使用已同步的本地订单簿，本地订单簿重新同步期间或未维护本地订单簿的交易对通过REST获取，缓存时间不超过行情更新周期
+func (m *MarketManager) GetOrderBook(symbol string) (*exchange.OrderBook, error) {
+	m.mu.RLock()
+	local := m.localBooks[symbol]
+	book, ok := m.orderBooks[symbol]
+	m.mu.RUnlock()
+
+	if local != nil {
+		if snapshot := local.Snapshot(orderBookDepthLimit); snapshot != nil {
+			return snapshot, nil
+		}
+	}
+
+	if ok && time.Since(book.UpdatedAt) < m.updateTicker {
+		return book, nil
+	}
+
+	book, err := m.client.GetOrderBook(symbol, orderBookDepthLimit)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 订单簿失败: %w", symbol, err)
+	}
+
+	m.mu.Lock()
+	m.orderBooks[symbol] = book
+	m.mu.Unlock()
+
+	return book, nil
+}
+
+// TrackOrderBooks 为指定交易对维护本地订单簿
+// 订阅全部已维护交易对的深度增量流（替换之前的订阅），连接建立（包括重连）后重新获取快照同步
+func (m *MarketManager) TrackOrderBooks(symbols []string) error {
+	m.mu.Lock()
+	for _, symbol := range symbols {
+		if _, ok := m.localBooks[symbol]; !ok {
+			m.localBooks[symbol] = NewLocalOrderBook(symbol)
+		}
+	}
+	symbols = make([]string, 0, len(m.localBooks))
+	for symbol := range m.localBooks {
+		symbols = append(symbols, symbol)
+	}
+	m.mu.Unlock()
+
+	stream, err := m.client.SubscribeDepth(symbols, m.applyDepthUpdate)
+	if err != nil {
+		return fmt.Errorf("订阅深度流失败: %w", err)
+	}
+
+	stream.SetStateHandler(func(connected bool) {
+		for _, symbol := range symbols {
+			m.mu.RLock()
+			book := m.localBooks[symbol]
+			m.mu.RUnlock()
+
+			// 断线期间的增量事件已丢失，必须基于新快照重新同步
+			book.Reset()
+			if connected {
+				m.resyncOrderBook(symbol)
+			}
+		}
+	})
+
+	if m.depthStream != nil {
+		m.depthStream.Stop()
+	}
+	m.depthStream = stream
+	stream.Start()
+
+	log.Printf("✓ 开始维护 %d 个本地订单簿", len(symbols))
+	return nil
+}
+
+// applyDepthUpdate 应用深度增量推送，序号出现缺口时重新同步
+func (m *MarketManager) applyDepthUpdate(update *exchange.DepthUpdate) {
+	m.mu.RLock()
+	book := m.localBooks[update.Symbol]
+	m.mu.RUnlock()
+
+	if book == nil {
+		return
+	}
+
+	if err := book.Apply(update); err == errOrderBookGap {
+		log.Printf("%s 订单簿序号不连续 (U=%d)，重新同步", update.Symbol, update.FirstUpdateID)
+		m.resyncOrderBook(update.Symbol)
+	}
+}
+
+// resyncOrderBook 在后台获取快照并同步本地订单簿，同一交易对同时只有一个同步任务
+func (m *MarketManager) resyncOrderBook(symbol string) {
+	m.mu.Lock()
+	book := m.localBooks[symbol]
+	if book == nil || m.resyncing[symbol] {
+		m.mu.Unlock()
+		return
+	}
+	m.resyncing[symbol] = true
+	m.mu.Unlock()
+
+	go func() {
+		for {
+			// 等待增量事件进入缓存后再获取快照
+			select {
+			case <-m.stopChan:
+				return
+			case <-time.After(orderBookResyncDelay):
+			}
+
+			// 推送中已包含完整快照的交易所无需再获取REST快照
+			if book.IsSynced() {
+				m.mu.Lock()
+				delete(m.resyncing, symbol)
+				m.mu.Unlock()
+				return
+			}
+
+			snapshot, err := m.client.GetOrderBook(symbol, localOrderBookSnapshotLimit)
+			if err != nil {
+				log.Printf("获取 %s 订单簿快照失败: %v", symbol, err)
+				continue
+			}
+
+			if err := book.LoadSnapshot(snapshot); err != nil {
+				log.Printf("%s 订单簿快照早于增量事件，重新获取", symbol)
+				continue
+			}
+
+			// 持锁确认同步完成后再清除标记，期间出现的新缺口由本任务继续处理
+			m.mu.Lock()
+			synced := book.IsSynced()
+			if synced {
+				delete(m.resyncing, symbol)
+			}
+			m.mu.Unlock()
+
+			if synced {
+				return
+			}
+		}
+	}()
+}
+
+// Updates 获取行情更新事件通道
+func (m *MarketManager) Updates() <-chan *exchange.Ticker {
+	return m.updateChan
+}
+
+// SymbolCount 获取交易对数量
+func (m *MarketManager) SymbolCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.symbolInfo)
+}
+
+// SymbolVersion 获取交易对集合的版本号，交易对上架、下架时递增
+func (m *MarketManager) SymbolVersion() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.symbolVersion
+}
+
 // GetSymbolInfo 获取交易对信息
-func (m *MarketManager) GetSymbolInfo(symbol string) *SymbolInfo {
+func (m *MarketManager) GetSymbolInfo(symbol string) *exchange.SymbolInfo {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return m.symbolInfo[symbol]
@@ -154,6 +456,18 @@ func (m *MarketManager) GetAllSymbols() []string {
 	return symbols
 }
 
+// GetAllSymbolInfo 获取所有交易对信息
+func (m *MarketManager) GetAllSymbolInfo() []*exchange.SymbolInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]*exchange.SymbolInfo, 0, len(m.symbolInfo))
+	for _, info := range m.symbolInfo {
+		infos = append(infos, info)
+	}
+	return infos
+}
+
 // ValidateSymbol 验证交易对是否存在
 func (m *MarketManager) ValidateSymbol(symbol string) bool {
 	m.mu.RLock()
@@ -233,77 +547,122 @@ func (m *MarketManager) GetSpreadPercentage(symbol string) float64 {
 	return (ticker.AskPrice - ticker.BidPrice) / ticker.BidPrice * 100
 }
 
-// ===== 交易对精度处理 =====
-
-// RoundQuantity 四舍五入数量到合法精度
-func (m *MarketManager) RoundQuantity(symbol string, quantity float64) (float64, error) {
-	symbolInfo := m.GetSymbolInfo(symbol)
-	if symbolInfo == nil {
-		return 0, fmt.Errorf("交易对 %s 不存在", symbol)
+// ConvertAmount 按直接交易对的中间价将 from 资产数量换算为 to 资产数量，没有直接交易对或行情时返回false
+func (m *MarketManager) ConvertAmount(amount float64, from, to string) (float64, bool) {
+	if from == to {
+		return amount, true
 	}
 
-	// 获取步长
-	stepSize := 0.00000001
-	for _, filter := range symbolInfo.Filters {
-		if filter.FilterType == "LOT_SIZE" {
-			var err error
-			stepSize, err = parseFloat(filter.StepSize)
-			if err != nil {
-				return 0, err
-			}
-			break
+	for _, symbol := range []string{from + to, to + from} {
+		info := m.GetSymbolInfo(symbol)
+		ticker := m.GetTicker(symbol)
+		if info == nil || ticker == nil || ticker.BidPrice <= 0 || ticker.AskPrice <= 0 {
+			continue
+		}
+
+		price := (ticker.BidPrice + ticker.AskPrice) / 2
+		switch {
+		case info.BaseAsset == from && info.QuoteAsset == to:
+			return amount * price, true
+		case info.BaseAsset == to && info.QuoteAsset == from:
+			return amount / price, true
 		}
 	}
+	return 0, false
+}
 
-	// 四舍五入
-	return roundToStep(quantity, stepSize), nil
+// ===== 交易对精度处理 =====
+
+// TradingRules 交易对下单规则（价格步长、数量步长、最小数量和最小成交额）
+type TradingRules struct {
+	TickSize    float64 // 价格步长
+	StepSize    float64 // 数量步长
+	MinQty      float64 // 最小下单数量
+	MaxQty      float64 // 最大下单数量，0 表示不限制
+	MinNotional float64 // 最小成交额
 }
 
-// RoundPrice 四舍五入价格到合法精度
-func (m *MarketManager) RoundPrice(symbol string, price float64) (float64, error) {
-	symbolInfo := m.GetSymbolInfo(symbol)
-	if symbolInfo == nil {
-		return 0, fmt.Errorf("交易对 %s 不存在", symbol)
+// newTradingRules 根据交易对信息构建下单规则，未提供步长时默认 0.00000001
+func newTradingRules(info *exchange.SymbolInfo) *TradingRules {
+	rules := &TradingRules{
+		TickSize:    0.00000001,
+		StepSize:    0.00000001,
+		MinQty:      info.MinQty,
+		MaxQty:      info.MaxQty,
+		MinNotional: info.MinNotional,
 	}
-
-	// 获取步长
-	tickSize := 0.00000001
-	for _, filter := range symbolInfo.Filters {
-		if filter.FilterType == "PRICE_FILTER" {
-			var err error
-			tickSize, err = parseFloat(filter.TickSize)
-			if err != nil {
-				return 0, err
-			}
-			break
-		}
+	if info.TickSize > 0 {
+		rules.TickSize = info.TickSize
+	}
+	if info.StepSize > 0 {
+		rules.StepSize = info.StepSize
 	}
 
-	// 四舍五入
-	return roundToStep(price, tickSize), nil
+	return rules
 }
 
-// ===== 辅助函数 =====
+// GetTradingRules 获取交易对下单规则
+func (m *MarketManager) GetTradingRules(symbol string) *TradingRules {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.tradingRules[symbol]
+}
 
-// parseFloat 安全地解析float
-func parseFloat(s string) (float64, error) {
-	if s == "" {
-		return 0, fmt.Errorf("空字符串")
+// RoundQuantity 将数量向下舍入到 LOT_SIZE 步长
+func (m *MarketManager) RoundQuantity(symbol string, quantity float64) (float64, error) {
+	rules := m.GetTradingRules(symbol)
+	if rules == nil {
+		return 0, fmt.Errorf("交易对 %s 不存在", symbol)
 	}
+	return roundToStep(quantity, rules.StepSize), nil
+}
 
-	// 使用简单的字符串转换
-	var result float64
-	_, err := fmt.Sscanf(s, "%f", &result)
-	if err != nil {
-		return 0, err
+// RoundPrice 将价格向下舍入到 PRICE_FILTER 步长
+func (m *MarketManager) RoundPrice(symbol string, price float64) (float64, error) {
+	rules := m.GetTradingRules(symbol)
+	if rules == nil {
+		return 0, fmt.Errorf("交易对 %s 不存在", symbol)
 	}
-	return result, nil
+	return roundToStep(price, rules.TickSize), nil
 }
 
-// roundToStep 四舍五入到指定步长
+// ===== 辅助函数 =====
+
+// stepEpsilon 按步长舍入时容忍的浮点误差（以步长为单位）
+const stepEpsilon = 1e-9
+
+// roundToStep 向下舍入到指定步长
+// 直接截断 value/step 会把 0.3/0.1=2.9999999999999996 舍成 2，因此先容忍微小误差再取整，
+// 并按步长的小数位数重新舍入，避免结果带上 0.30000000000000004 这样的尾差
 func roundToStep(value float64, step float64) float64 {
 	if step == 0 {
 		return value
 	}
-	return float64(int64(value/step)) * step
+	steps := math.Floor(value/step + stepEpsilon)
+	return roundToDecimals(steps*step, stepDecimals(step))
+}
+
+// ceilToStep 向上舍入到指定步长
+func ceilToStep(value float64, step float64) float64 {
+	if step == 0 {
+		return value
+	}
+	steps := math.Ceil(value/step - stepEpsilon)
+	return roundToDecimals(steps*step, stepDecimals(step))
+}
+
+// stepDecimals 获取步长的小数位数，例如 0.001 -> 3
+func stepDecimals(step float64) int {
+	decimals := 0
+	for decimals < 16 && math.Abs(step-math.Round(step)) > step*stepEpsilon {
+		step *= 10
+		decimals++
+	}
+	return decimals
+}
+
+// roundToDecimals 按小数位数四舍五入
+func roundToDecimals(value float64, decimals int) float64 {
+	factor := math.Pow(10, float64(decimals))
+	return math.Round(value*factor) / factor
 }
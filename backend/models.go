@@ -4,94 +4,100 @@ import "time"
 
 // User 用户模型
 type User struct {
-	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"-"` // 不序列化密码哈希
-	Email        string    `json:"email"`
-	IsActive     bool      `json:"is_active"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"` // 不序列化密码哈希
+	Email        string     `json:"email"`
+	IsActive     bool       `json:"is_active"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 	LastLogin    *time.Time `json:"last_login"`
 }
 
 // Bot 机器人模型
 type Bot struct {
-	ID              int64     `json:"id"`
-	UserID          int64     `json:"user_id"`
-	Name            string    `json:"name"`
-	StrategyType    string    `json:"strategy_type"` // triangular, quadrangular, pentagonal
-	ExchangeID      int64     `json:"exchange_id"`
-	IsRunning       bool      `json:"is_running"`
-	IsSimulation    bool      `json:"is_simulation"`
-	UpdateFrequency int       `json:"update_frequency"` // 秒
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              int64      `json:"id"`
+	UserID          int64      `json:"user_id"`
+	Name            string     `json:"name"`
+	StrategyType    string     `json:"strategy_type"` // triangular, quadrangular, pentagonal, cross_exchange
+	ExchangeID      int64      `json:"exchange_id"`
+	HedgeExchangeID *int64     `json:"hedge_exchange_id"` // cross_exchange 策略比较的另一个交易所
+	IsRunning       bool       `json:"is_running"`
+	IsSimulation    bool       `json:"is_simulation"`
+	UpdateFrequency int        `json:"update_frequency"` // 秒
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 	StartedAt       *time.Time `json:"started_at"`
 	StoppedAt       *time.Time `json:"stopped_at"`
-	TotalProfit     float64   `json:"total_profit"`
-	TotalTrades     int64     `json:"total_trades"`
+	TotalProfit     float64    `json:"total_profit"`
+	TotalTrades     int64      `json:"total_trades"`
 }
 
 // Strategy 策略模型
 type Strategy struct {
-	ID                   int64     `json:"id"`
-	BotID                int64     `json:"bot_id"`
-	Name                 string    `json:"name"`
-	StrategyType         string    `json:"strategy_type"`
-	BasePair             string    `json:"base_pair"`
-	QuoteCurrency        string    `json:"quote_currency"`
-	Pair1                string    `json:"pair1"`
-	Pair2                string    `json:"pair2"`
-	Pair3                string    `json:"pair3"`
-	Pair4                *string   `json:"pair4"`
-	Pair5                *string   `json:"pair5"`
-	MinProfitPercentage  float64   `json:"min_profit_percentage"`
-	MaxTradeAmount       float64   `json:"max_trade_amount"`
-	MinTradeAmount       float64   `json:"min_trade_amount"`
-	MaxLossPercentage    float64   `json:"max_loss_percentage"`
-	MaxConcurrentTrades  int       `json:"max_concurrent_trades"`
-	UseMargin            bool      `json:"use_margin"`
-	Leverage             float64   `json:"leverage"`
-	IsActive             bool      `json:"is_active"`
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                  int64     `json:"id"`
+	BotID               int64     `json:"bot_id"`
+	Name                string    `json:"name"`
+	StrategyType        string    `json:"strategy_type"`
+	BasePair            string    `json:"base_pair"`
+	QuoteCurrency       string    `json:"quote_currency"`
+	Pair1               string    `json:"pair1"`
+	Pair2               string    `json:"pair2"`
+	Pair3               string    `json:"pair3"`
+	Pair4               *string   `json:"pair4"`
+	Pair5               *string   `json:"pair5"`
+	MinProfitPercentage float64   `json:"min_profit_percentage"`
+	MaxTradeAmount      float64   `json:"max_trade_amount"`
+	MinTradeAmount      float64   `json:"min_trade_amount"`
+	MaxLossPercentage   float64   `json:"max_loss_percentage"`
+	MaxConcurrentTrades int       `json:"max_concurrent_trades"`
+	UseMargin           bool      `json:"use_margin"`
+	Leverage            float64   `json:"leverage"`
+	OrderType           string    `json:"order_type"`         // 各步骤的下单类型 LIMIT/LIMIT_MAKER/MARKET，为空时按 IOC 限价单
+	TimeInForce         string    `json:"time_in_force"`      // 限价单有效方式 GTC/IOC/FOK，为空时按 IOC
+	UnwindPolicy        string    `json:"unwind_policy"`      // 某一步失败后的回滚方式 market/retry/hold，为空时按 market
+	UnwindCapPercent    float64   `json:"unwind_cap_percent"` // retry 重试剩余步骤时成交价相对计划价格允许变差的百分比
+	IsActive            bool      `json:"is_active"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
 }
 
 // Trade 交易模型
 type Trade struct {
-	ID                int64      `json:"id"`
-	BotID             int64      `json:"bot_id"`
-	StrategyID        int64      `json:"strategy_id"`
-	TradeType         string     `json:"trade_type"` // triangular, quadrangular, pentagonal
-	Status            string     `json:"status"`     // pending, executing, completed, failed, cancelled
-	Pair1             string     `json:"pair1"`
-	Pair2             string     `json:"pair2"`
-	Pair3             string     `json:"pair3"`
-	Pair4             *string    `json:"pair4"`
-	Pair5             *string    `json:"pair5"`
-	InitialAmount     float64    `json:"initial_amount"`
-	FinalAmount       *float64   `json:"final_amount"`
-	Profit            *float64   `json:"profit"`
-	ProfitPercentage  *float64   `json:"profit_percentage"`
-	TotalFees         float64    `json:"total_fees"`
-	CreatedAt         time.Time  `json:"created_at"`
-	CompletedAt       *time.Time `json:"completed_at"`
-	ExecutionTimeMs   *int       `json:"execution_time_ms"`
-	Details           interface{} `json:"details"`
-	ErrorMessage      *string    `json:"error_message"`
+	ID               int64       `json:"id"`
+	BotID            int64       `json:"bot_id"`
+	StrategyID       int64       `json:"strategy_id"`
+	TradeType        string      `json:"trade_type"` // triangular, quadrangular, pentagonal
+	Status           string      `json:"status"`     // pending, executing, completed, failed, cancelled
+	Pair1            string      `json:"pair1"`
+	Pair2            string      `json:"pair2"`
+	Pair3            string      `json:"pair3"`
+	Pair4            *string     `json:"pair4"`
+	Pair5            *string     `json:"pair5"`
+	InitialAmount    float64     `json:"initial_amount"`
+	FinalAmount      *float64    `json:"final_amount"`
+	Profit           *float64    `json:"profit"`
+	ProfitPercentage *float64    `json:"profit_percentage"`
+	TotalFees        float64     `json:"total_fees"`
+	CreatedAt        time.Time   `json:"created_at"`
+	CompletedAt      *time.Time  `json:"completed_at"`
+	ExecutionTimeMs  *int        `json:"execution_time_ms"`
+	Details          interface{} `json:"details"`
+	ErrorMessage     *string     `json:"error_message"`
 }
 
 // Exchange 交易所模型
 type Exchange struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Name      string    `json:"name"`
-	APIKey    string    `json:"-"` // 不序列化API密钥
-	APISecret string    `json:"-"` // 不序列化API密钥
-	IsTestnet bool      `json:"is_testnet"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name"`
+	APIKey     string    `json:"-"` // 不序列化API密钥
+	APISecret  string    `json:"-"` // 不序列化API密钥
+	Passphrase string    `json:"-"` // OKX API密码短语
+	IsTestnet  bool      `json:"is_testnet"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
 
 // DashboardStats 仪表板统计数据
@@ -126,6 +132,7 @@ type CreateBotRequest struct {
 	Name            string `json:"name" binding:"required"`
 	StrategyType    string `json:"strategy_type" binding:"required"`
 	ExchangeID      int64  `json:"exchange_id" binding:"required"`
+	HedgeExchangeID *int64 `json:"hedge_exchange_id"` // cross_exchange 策略必填
 	IsSimulation    bool   `json:"is_simulation"`
 	UpdateFrequency int    `json:"update_frequency"`
 }
@@ -135,6 +142,7 @@ type UpdateBotRequest struct {
 	Name            string `json:"name"`
 	StrategyType    string `json:"strategy_type"`
 	ExchangeID      int64  `json:"exchange_id"`
+	HedgeExchangeID *int64 `json:"hedge_exchange_id"`
 	IsSimulation    bool   `json:"is_simulation"`
 	UpdateFrequency int    `json:"update_frequency"`
 }
@@ -146,30 +154,31 @@ type SwitchModeRequest struct {
 
 // CreateStrategyRequest 创建策略请求
 type CreateStrategyRequest struct {
-	Name                string   `json:"name" binding:"required"`
-	StrategyType        string   `json:"strategy_type" binding:"required"`
-	BasePair            string   `json:"base_pair" binding:"required"`
-	QuoteCurrency       string   `json:"quote_currency" binding:"required"`
-	Pair1               string   `json:"pair1" binding:"required"`
-	Pair2               string   `json:"pair2" binding:"required"`
-	Pair3               string   `json:"pair3" binding:"required"`
-	Pair4               *string  `json:"pair4"`
-	Pair5               *string  `json:"pair5"`
-	MinProfitPercentage float64  `json:"min_profit_percentage"`
-	MaxTradeAmount      float64  `json:"max_trade_amount"`
-	MinTradeAmount      float64  `json:"min_trade_amount"`
-	MaxLossPercentage   float64  `json:"max_loss_percentage"`
-	MaxConcurrentTrades int      `json:"max_concurrent_trades"`
-	UseMargin           bool     `json:"use_margin"`
-	Leverage            float64  `json:"leverage"`
+	Name                string  `json:"name" binding:"required"`
+	StrategyType        string  `json:"strategy_type" binding:"required"`
+	BasePair            string  `json:"base_pair" binding:"required"`
+	QuoteCurrency       string  `json:"quote_currency" binding:"required"`
+	Pair1               string  `json:"pair1" binding:"required"`
+	Pair2               string  `json:"pair2" binding:"required"`
+	Pair3               string  `json:"pair3" binding:"required"`
+	Pair4               *string `json:"pair4"`
+	Pair5               *string `json:"pair5"`
+	MinProfitPercentage float64 `json:"min_profit_percentage"`
+	MaxTradeAmount      float64 `json:"max_trade_amount"`
+	MinTradeAmount      float64 `json:"min_trade_amount"`
+	MaxLossPercentage   float64 `json:"max_loss_percentage"`
+	MaxConcurrentTrades int     `json:"max_concurrent_trades"`
+	UseMargin           bool    `json:"use_margin"`
+	Leverage            float64 `json:"leverage"`
 }
 
 // CreateExchangeRequest 创建交易所请求
 type CreateExchangeRequest struct {
-	Name      string `json:"name" binding:"required"`
-	APIKey    string `json:"api_key" binding:"required"`
-	APISecret string `json:"api_secret" binding:"required"`
-	IsTestnet bool   `json:"is_testnet"`
+	Name       string `json:"name" binding:"required"` // 交易所适配器: binance, okx, bybit
+	APIKey     string `json:"api_key" binding:"required"`
+	APISecret  string `json:"api_secret" binding:"required"`
+	Passphrase string `json:"passphrase"` // OKX 必填
+	IsTestnet  bool   `json:"is_testnet"`
 }
 
 // APIResponse 通用API响应
@@ -189,10 +198,10 @@ type WebSocketMessage struct {
 
 // StatsUpdatePayload 统计更新负载
 type StatsUpdatePayload struct {
-	TotalProfit float64 `json:"total_profit"`
-	TotalTrades int64   `json:"total_trades"`
-	ActiveBots  int64   `json:"active_bots"`
-	WinRate     float64 `json:"win_rate"`
+	TotalProfit float64   `json:"total_profit"`
+	TotalTrades int64     `json:"total_trades"`
+	ActiveBots  int64     `json:"active_bots"`
+	WinRate     float64   `json:"win_rate"`
 	Timestamp   time.Time `json:"timestamp"`
 }
 
@@ -212,6 +221,18 @@ type BotStatusPayload struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// OpportunityPayload 套利机会负载
+type OpportunityPayload struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	Path             []string  `json:"path"`
+	InitialAmount    float64   `json:"initial_amount"`
+	NetProfit        float64   `json:"net_profit"`
+	ProfitPercentage float64   `json:"profit_percentage"`
+	Confidence       float64   `json:"confidence"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
 // LogPayload 日志负载
 type LogPayload struct {
 	BotID   *int64    `json:"bot_id"`
@@ -0,0 +1,238 @@
+package arbitrage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// ===== 多边套利公共逻辑 =====
+
+// fetchTickerSnapshot 获取全部行情并构建行情快照
+func fetchTickerSnapshot(client exchange.Exchange) (map[string]*exchange.Ticker, error) {
+	tickers, err := client.GetAllTickers()
+	if err != nil {
+		return nil, fmt.Errorf("获取行情失败: %v", err)
+	}
+
+	snapshot := make(map[string]*exchange.Ticker, len(tickers))
+	for _, ticker := range tickers {
+		snapshot[ticker.Symbol] = ticker
+	}
+	return snapshot, nil
+}
+
+// buildRateEdges 根据交易步骤和行情快照构建汇率边
+// BUY 按卖一价换算为 1/ask，SELL 按买一价换算为 bid，均按手续费模型扣除taker手续费
+func buildRateEdges(legs []TriangleLeg, tickers map[string]*exchange.Ticker, fees *exchange.FeeModel) []RateEdge {
+	edges := make([]RateEdge, 0, len(legs))
+
+	for _, leg := range legs {
+		ticker, ok := tickers[leg.Symbol]
+		if !ok || ticker == nil {
+			continue
+		}
+
+		var rate float64
+		feeFactor := 1 - fees.Taker(leg.Symbol)
+		if leg.Side == "BUY" {
+			if ticker.AskPrice <= 0 {
+				continue
+			}
+			rate = 1 / ticker.AskPrice * feeFactor
+		} else {
+			if ticker.BidPrice <= 0 {
+				continue
+			}
+			rate = ticker.BidPrice * feeFactor
+		}
+
+		edges = append(edges, RateEdge{
+			From:   leg.From,
+			To:     leg.To,
+			Symbol: leg.Symbol,
+			Side:   leg.Side,
+			Rate:   rate,
+		})
+	}
+
+	return edges
+}
+
+// refreshFees 从交易所刷新手续费率，失败时继续使用上次的费率
+func refreshFees(fees *exchange.FeeModel) {
+	if err := fees.Refresh(); err != nil {
+		log.Printf("刷新手续费率失败，继续使用当前费率: %v", err)
+	}
+}
+
+// scanCycles 查找指定长度且满足最低利润的闭环，按利润从高到低排序
+func scanCycles(index *TriangleIndex, startAssets []string, tickers map[string]*exchange.Ticker, fees *exchange.FeeModel, length int, minProfitPercent float64) []*RateCycle {
+	edges := buildRateEdges(index.Legs(), tickers, fees)
+	found := FindNegativeCycles(edges, startAssets, length, length)
+
+	cycles := make([]*RateCycle, 0, len(found))
+	for _, cycle := range found {
+		if len(cycle.Edges) == length && cycle.ProfitPercent() >= minProfitPercent {
+			cycles = append(cycles, cycle)
+		}
+	}
+
+	sort.Slice(cycles, func(i, j int) bool {
+		return cycles[i].Product > cycles[j].Product
+	})
+	return cycles
+}
+
+// ===== 四角套利 =====
+
+// RefreshSymbols 从交易所信息更新资产索引，同时刷新手续费率
+func (qae *QuadrangularArbitrageEngine) RefreshSymbols() error {
+	symbols, err := qae.client.GetSymbols()
+	if err != nil {
+		return fmt.Errorf("获取交易所信息失败: %v", err)
+	}
+	qae.index.Update(symbols)
+	refreshFees(qae.fees)
+	return nil
+}
+
+// SetFeeModel 设置手续费模型
+func (qae *QuadrangularArbitrageEngine) SetFeeModel(fees *exchange.FeeModel) {
+	qae.mu.Lock()
+	defer qae.mu.Unlock()
+	qae.fees = fees
+}
+
+// UpdateTickers 更新行情数据
+func (qae *QuadrangularArbitrageEngine) UpdateTickers() error {
+	snapshot, err := fetchTickerSnapshot(qae.client)
+	if err != nil {
+		return err
+	}
+
+	qae.mu.Lock()
+	qae.tickers = snapshot
+	qae.mu.Unlock()
+	return nil
+}
+
+// ScanOpportunities 扫描四角套利机会
+func (qae *QuadrangularArbitrageEngine) ScanOpportunities() []*QuadrangularOpportunity {
+	qae.mu.RLock()
+	tickers := qae.tickers
+	fees := qae.fees
+	qae.mu.RUnlock()
+
+	cycles := scanCycles(qae.index, qae.startAssets, tickers, fees, 4, qae.minProfitPercent)
+
+	opportunities := make([]*QuadrangularOpportunity, 0, len(cycles))
+	for _, cycle := range cycles {
+		opportunities = append(opportunities, &QuadrangularOpportunity{
+			ID:            fmt.Sprintf("QUAD_%d", time.Now().UnixNano()),
+			Path:          cycle.Path(),
+			StartAsset:    cycle.StartAsset,
+			InitialAmount: 1.0,
+			FinalAmount:   cycle.Product,
+			NetProfit:     cycle.Product - 1,
+			ProfitPercent: cycle.ProfitPercent(),
+			Timestamp:     time.Now(),
+			IsValid:       true,
+		})
+	}
+
+	qae.mu.Lock()
+	qae.opportunities = opportunities
+	qae.mu.Unlock()
+
+	return opportunities
+}
+
+// GetTopOpportunities 获取前N个最佳四角套利机会
+func (qae *QuadrangularArbitrageEngine) GetTopOpportunities(limit int) []*QuadrangularOpportunity {
+	qae.mu.RLock()
+	defer qae.mu.RUnlock()
+
+	if limit > len(qae.opportunities) {
+		limit = len(qae.opportunities)
+	}
+	return qae.opportunities[:limit]
+}
+
+// ===== 五角套利 =====
+
+// RefreshSymbols 从交易所信息更新资产索引，同时刷新手续费率
+func (pae *PentagonalArbitrageEngine) RefreshSymbols() error {
+	symbols, err := pae.client.GetSymbols()
+	if err != nil {
+		return fmt.Errorf("获取交易所信息失败: %v", err)
+	}
+	pae.index.Update(symbols)
+	refreshFees(pae.fees)
+	return nil
+}
+
+// SetFeeModel 设置手续费模型
+func (pae *PentagonalArbitrageEngine) SetFeeModel(fees *exchange.FeeModel) {
+	pae.mu.Lock()
+	defer pae.mu.Unlock()
+	pae.fees = fees
+}
+
+// UpdateTickers 更新行情数据
+func (pae *PentagonalArbitrageEngine) UpdateTickers() error {
+	snapshot, err := fetchTickerSnapshot(pae.client)
+	if err != nil {
+		return err
+	}
+
+	pae.mu.Lock()
+	pae.tickers = snapshot
+	pae.mu.Unlock()
+	return nil
+}
+
+// ScanOpportunities 扫描五角套利机会
+func (pae *PentagonalArbitrageEngine) ScanOpportunities() []*PentagonalOpportunity {
+	pae.mu.RLock()
+	tickers := pae.tickers
+	fees := pae.fees
+	pae.mu.RUnlock()
+
+	cycles := scanCycles(pae.index, pae.startAssets, tickers, fees, 5, pae.minProfitPercent)
+
+	opportunities := make([]*PentagonalOpportunity, 0, len(cycles))
+	for _, cycle := range cycles {
+		opportunities = append(opportunities, &PentagonalOpportunity{
+			ID:            fmt.Sprintf("PENTA_%d", time.Now().UnixNano()),
+			Path:          cycle.Path(),
+			StartAsset:    cycle.StartAsset,
+			InitialAmount: 1.0,
+			FinalAmount:   cycle.Product,
+			NetProfit:     cycle.Product - 1,
+			ProfitPercent: cycle.ProfitPercent(),
+			Timestamp:     time.Now(),
+			IsValid:       true,
+		})
+	}
+
+	pae.mu.Lock()
+	pae.opportunities = opportunities
+	pae.mu.Unlock()
+
+	return opportunities
+}
+
+// GetTopOpportunities 获取前N个最佳五角套利机会
+func (pae *PentagonalArbitrageEngine) GetTopOpportunities(limit int) []*PentagonalOpportunity {
+	pae.mu.RLock()
+	defer pae.mu.RUnlock()
+
+	if limit > len(pae.opportunities) {
+		limit = len(pae.opportunities)
+	}
+	return pae.opportunities[:limit]
+}
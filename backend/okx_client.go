@@ -0,0 +1,812 @@
+package exchange
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// okxMaxBookDepth REST订单簿接口最多返回的档位数量
+const okxMaxBookDepth = 400
+
+// okxErrOrderNotFound 查询的订单不存在
+const okxErrOrderNotFound = "51603"
+
+// OKXClient OKX v5 现货API客户端，实现 Exchange 接口
+// OKX 的产品ID形如 BTC-USDT，对外统一转换为 BTCUSDT
+type OKXClient struct {
+	APIKey           string
+	APISecret        string
+	Passphrase       string
+	BaseURL          string
+	PublicStreamURL  string
+	PrivateStreamURL string
+	IsTestnet        bool // 模拟盘，请求携带 x-simulated-trading 头
+	HTTPClient       *http.Client
+
+	mu      sync.RWMutex
+	instIDs map[string]string // 交易对 -> 产品ID
+}
+
+var (
+	_ Exchange        = (*OKXClient)(nil)
+	_ OrderSubscriber = (*OKXClient)(nil)
+	_ FeeProvider     = (*OKXClient)(nil)
+)
+
+// NewOKXClient 创建OKX客户端
+func NewOKXClient(apiKey, apiSecret, passphrase string, isTestnet bool) *OKXClient {
+	publicURL := "wss://ws.okx.com:8443/ws/v5/public"
+	privateURL := "wss://ws.okx.com:8443/ws/v5/private"
+	if isTestnet {
+		publicURL = "wss://wspap.okx.com:8443/ws/v5/public"
+		privateURL = "wss://wspap.okx.com:8443/ws/v5/private"
+	}
+
+	return &OKXClient{
+		APIKey:           apiKey,
+		APISecret:        apiSecret,
+		Passphrase:       passphrase,
+		BaseURL:          "https://www.okx.com",
+		PublicStreamURL:  publicURL,
+		PrivateStreamURL: privateURL,
+		IsTestnet:        isTestnet,
+		HTTPClient:       &http.Client{Timeout: 10 * time.Second},
+		instIDs:          make(map[string]string),
+	}
+}
+
+// Name 交易所名称
+func (c *OKXClient) Name() string {
+	return "okx"
+}
+
+// ===== OKX接口数据结构 =====
+
+// okxInstrument 产品信息
+type okxInstrument struct {
+	InstID   string `json:"instId"`
+	BaseCcy  string `json:"baseCcy"`
+	QuoteCcy string `json:"quoteCcy"`
+	TickSz   string `json:"tickSz"`
+	LotSz    string `json:"lotSz"`
+	MinSz    string `json:"minSz"`
+	MaxLmtSz string `json:"maxLmtSz"`
+	State    string `json:"state"` // live 表示可交易
+}
+
+// okxTicker 行情
+type okxTicker struct {
+	InstID    string `json:"instId"`
+	Last      string `json:"last"`
+	AskPx     string `json:"askPx"`
+	AskSz     string `json:"askSz"`
+	BidPx     string `json:"bidPx"`
+	BidSz     string `json:"bidSz"`
+	Open24h   string `json:"open24h"`
+	Vol24h    string `json:"vol24h"`
+	VolCcy24h string `json:"volCcy24h"` // 现货为报价资产成交额
+	Ts        string `json:"ts"`
+}
+
+// okxOrder 订单信息
+type okxOrder struct {
+	InstID    string `json:"instId"`
+	OrdID     string `json:"ordId"`
+	ClOrdID   string `json:"clOrdId"`
+	Px        string `json:"px"`
+	Sz        string `json:"sz"`
+	OrdType   string `json:"ordType"`
+	Side      string `json:"side"`
+	State     string `json:"state"`
+	AccFillSz string `json:"accFillSz"`
+	AvgPx     string `json:"avgPx"`
+	CTime     string `json:"cTime"`
+	UTime     string `json:"uTime"`
+}
+
+// okxOrderResult 下单和撤单结果
+type okxOrderResult struct {
+	OrdID   string `json:"ordId"`
+	ClOrdID string `json:"clOrdId"`
+	SCode   string `json:"sCode"`
+	SMsg    string `json:"sMsg"`
+}
+
+// okxBook 订单簿
+type okxBook struct {
+	Asks      [][]string `json:"asks"` // [价格, 数量, 废弃字段, 订单数]
+	Bids      [][]string `json:"bids"`
+	Ts        string     `json:"ts"`
+	PrevSeqID int64      `json:"prevSeqId"` // 仅推送，快照为 -1
+	SeqID     int64      `json:"seqId"`
+}
+
+// toSymbolInfo 转换为标准交易对信息
+func (i *okxInstrument) toSymbolInfo() *SymbolInfo {
+	status := strings.ToUpper(i.State)
+	if i.State == "live" {
+		status = SymbolStatusTrading
+	}
+
+	return &SymbolInfo{
+		Symbol:     okxSymbol(i.InstID),
+		Status:     status,
+		BaseAsset:  i.BaseCcy,
+		QuoteAsset: i.QuoteCcy,
+		TickSize:   parseDecimal(i.TickSz),
+		StepSize:   parseDecimal(i.LotSz),
+		MinQty:     parseDecimal(i.MinSz),
+		MaxQty:     parseDecimal(i.MaxLmtSz),
+	}
+}
+
+// toTicker 转换为标准行情
+func (t *okxTicker) toTicker() *Ticker {
+	ticker := &Ticker{
+		Symbol:      okxSymbol(t.InstID),
+		BidPrice:    parseDecimal(t.BidPx),
+		BidQty:      parseDecimal(t.BidSz),
+		AskPrice:    parseDecimal(t.AskPx),
+		AskQty:      parseDecimal(t.AskSz),
+		LastPrice:   parseDecimal(t.Last),
+		Volume:      parseDecimal(t.Vol24h),
+		QuoteVolume: parseDecimal(t.VolCcy24h),
+		UpdatedAt:   okxTime(t.Ts),
+	}
+	if open := parseDecimal(t.Open24h); open > 0 {
+		ticker.PriceChangePercent = (ticker.LastPrice - open) / open * 100
+	}
+	return ticker
+}
+
+// toOrder 转换为标准订单，fok/ioc 视为对应有效方式的限价单，post_only 视为 LIMIT_MAKER
+func (o *okxOrder) toOrder() *Order {
+	order := &Order{
+		Symbol:        okxSymbol(o.InstID),
+		OrderID:       o.OrdID,
+		ClientOrderID: o.ClOrdID,
+		Side:          strings.ToUpper(o.Side),
+		Type:          "LIMIT",
+		Price:         parseDecimal(o.Px),
+		OrigQty:       parseDecimal(o.Sz),
+		ExecutedQty:   parseDecimal(o.AccFillSz),
+		Time:          okxTime(o.CTime),
+		UpdateTime:    okxTime(o.UTime),
+	}
+	order.ExecutedQuoteQty = order.ExecutedQty * parseDecimal(o.AvgPx)
+
+	switch o.OrdType {
+	case "market":
+		order.Type = "MARKET"
+	case "post_only":
+		order.Type = OrderTypeLimitMaker
+		order.TimeInForce = "GTC"
+	case "fok":
+		order.TimeInForce = "FOK"
+	case "ioc":
+		order.TimeInForce = "IOC"
+	default:
+		order.TimeInForce = "GTC"
+	}
+
+	switch o.State {
+	case "live":
+		order.Status = OrderStatusNew
+	case "partially_filled":
+		order.Status = OrderStatusPartiallyFilled
+	case "filled":
+		order.Status = OrderStatusFilled
+	case "canceled", "mmp_canceled":
+		order.Status = OrderStatusCanceled
+	default:
+		order.Status = strings.ToUpper(o.State)
+	}
+
+	return order
+}
+
+// ===== 行情 =====
+
+// GetSymbols 获取全部现货交易对信息，同时更新交易对到产品ID的映射
+func (c *OKXClient) GetSymbols() ([]*SymbolInfo, error) {
+	params := url.Values{}
+	params.Add("instType", "SPOT")
+
+	var instruments []okxInstrument
+	if err := c.doRequest("GET", "/api/v5/public/instruments", params, nil, false, &instruments); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]*SymbolInfo, len(instruments))
+	c.mu.Lock()
+	for i := range instruments {
+		symbols[i] = instruments[i].toSymbolInfo()
+		c.instIDs[symbols[i].Symbol] = instruments[i].InstID
+	}
+	c.mu.Unlock()
+
+	return symbols, nil
+}
+
+// GetTicker 获取交易对行情
+func (c *OKXClient) GetTicker(symbol string) (*Ticker, error) {
+	instID, err := c.instID(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("instId", instID)
+
+	var tickers []okxTicker
+	if err := c.doRequest("GET", "/api/v5/market/ticker", params, nil, false, &tickers); err != nil {
+		return nil, err
+	}
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("交易对 %s 无行情数据", symbol)
+	}
+
+	return tickers[0].toTicker(), nil
+}
+
+// GetAllTickers 获取全部现货交易对行情
+func (c *OKXClient) GetAllTickers() ([]*Ticker, error) {
+	params := url.Values{}
+	params.Add("instType", "SPOT")
+
+	var raw []okxTicker
+	if err := c.doRequest("GET", "/api/v5/market/tickers", params, nil, false, &raw); err != nil {
+		return nil, err
+	}
+
+	tickers := make([]*Ticker, len(raw))
+	for i := range raw {
+		tickers[i] = raw[i].toTicker()
+	}
+	return tickers, nil
+}
+
+// GetOrderBook 获取订单簿深度，最多 okxMaxBookDepth 档
+// REST深度不含序号，LastUpdateID 为0，本地订单簿通过推送的快照同步
+func (c *OKXClient) GetOrderBook(symbol string, limit int) (*OrderBook, error) {
+	instID, err := c.instID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if limit > okxMaxBookDepth {
+		limit = okxMaxBookDepth
+	}
+
+	params := url.Values{}
+	params.Add("instId", instID)
+	params.Add("sz", strconv.Itoa(limit))
+
+	var books []okxBook
+	if err := c.doRequest("GET", "/api/v5/market/books", params, nil, false, &books); err != nil {
+		return nil, err
+	}
+	if len(books) == 0 {
+		return nil, fmt.Errorf("交易对 %s 无订单簿数据", symbol)
+	}
+
+	bids, err := parseDepthLevels(books[0].Bids)
+	if err != nil {
+		return nil, fmt.Errorf("解析买单深度失败: %w", err)
+	}
+	asks, err := parseDepthLevels(books[0].Asks)
+	if err != nil {
+		return nil, fmt.Errorf("解析卖单深度失败: %w", err)
+	}
+
+	return &OrderBook{
+		Symbol:    symbol,
+		Bids:      bids,
+		Asks:      asks,
+		UpdatedAt: okxTime(books[0].Ts),
+	}, nil
+}
+
+// ===== 账户 =====
+
+// GetBalances 获取交易账户全部资产余额
+func (c *OKXClient) GetBalances() ([]*Balance, error) {
+	var accounts []struct {
+		Details []struct {
+			Ccy       string `json:"ccy"`
+			AvailBal  string `json:"availBal"`
+			FrozenBal string `json:"frozenBal"`
+		} `json:"details"`
+	}
+	if err := c.doRequest("GET", "/api/v5/account/balance", nil, nil, true, &accounts); err != nil {
+		return nil, err
+	}
+
+	balances := make([]*Balance, 0)
+	for _, account := range accounts {
+		for _, detail := range account.Details {
+			balances = append(balances, &Balance{
+				Asset:  detail.Ccy,
+				Free:   parseDecimal(detail.AvailBal),
+				Locked: parseDecimal(detail.FrozenBal),
+			})
+		}
+	}
+	return balances, nil
+}
+
+// GetBalance 获取指定资产余额
+func (c *OKXClient) GetBalance(asset string) (*Balance, error) {
+	balances, err := c.GetBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			return balance, nil
+		}
+	}
+
+	return nil, fmt.Errorf("资产 %s 不存在", asset)
+}
+
+// GetTradeFees 获取账户的现货手续费率，OKX 按账户等级统一收费，返回 Symbol 为空的一项
+// 接口返回的费率为负数表示收取手续费，正数表示返佣
+func (c *OKXClient) GetTradeFees() ([]*TradeFee, error) {
+	params := url.Values{}
+	params.Add("instType", "SPOT")
+
+	var rates []struct {
+		Maker string `json:"maker"`
+		Taker string `json:"taker"`
+	}
+	if err := c.doRequest("GET", "/api/v5/account/trade-fee", params, nil, true, &rates); err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("未返回手续费率")
+	}
+
+	return []*TradeFee{{
+		Maker: -parseDecimal(rates[0].Maker),
+		Taker: -parseDecimal(rates[0].Taker),
+	}}, nil
+}
+
+// ===== 订单 =====
+
+// PlaceOrder 下单（现货模式），限价单按 GTC 挂单，市价单数量按基础资产计
+func (c *OKXClient) PlaceOrder(symbol, side, orderType string, quantity, price float64) (*Order, error) {
+	req := &OrderRequest{Symbol: symbol, Side: side, Type: orderType, Quantity: quantity}
+	if orderType == OrderTypeLimit {
+		req.Price = price
+	}
+	return c.SubmitOrder(req)
+}
+
+// SubmitOrder 按下单请求下单（现货模式）
+// 限价单的有效方式对应 OKX 的 ordType (limit/ioc/fok)，LIMIT_MAKER 对应 post_only；
+// OKX 的客户端订单ID只允许字母和数字，未指定时不传
+func (c *OKXClient) SubmitOrder(req *OrderRequest) (*Order, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	instID, err := c.instID(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]string{
+		"instId": instID,
+		"tdMode": "cash",
+		"side":   strings.ToLower(req.Side),
+		"sz":     strconv.FormatFloat(req.Quantity, 'f', -1, 64),
+	}
+	switch req.Type {
+	case OrderTypeLimit:
+		body["ordType"] = okxLimitOrderTypes[req.TimeInForceOrDefault()]
+		body["px"] = strconv.FormatFloat(req.Price, 'f', -1, 64)
+	case OrderTypeLimitMaker:
+		body["ordType"] = "post_only"
+		body["px"] = strconv.FormatFloat(req.Price, 'f', -1, 64)
+	case OrderTypeMarket:
+		body["ordType"] = "market"
+		body["tgtCcy"] = "base_ccy"
+		if req.QuoteQuantity > 0 {
+			body["tgtCcy"] = "quote_ccy"
+			body["sz"] = strconv.FormatFloat(req.QuoteQuantity, 'f', -1, 64)
+		}
+	}
+	if req.ClientOrderID != "" {
+		body["clOrdId"] = req.ClientOrderID
+	}
+
+	result, err := c.orderRequest("/api/v5/trade/order", body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	order := &Order{
+		Symbol:        req.Symbol,
+		OrderID:       result.OrdID,
+		ClientOrderID: result.ClOrdID,
+		Side:          req.Side,
+		Type:          req.Type,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		Status:        OrderStatusNew,
+		Time:          now,
+		UpdateTime:    now,
+	}
+	switch req.Type {
+	case OrderTypeLimit:
+		order.TimeInForce = req.TimeInForceOrDefault()
+	case OrderTypeLimitMaker:
+		order.TimeInForce = TimeInForceGTC
+	}
+	return order, nil
+}
+
+// okxLimitOrderTypes 限价单有效方式对应的 OKX 订单类型
+var okxLimitOrderTypes = map[string]string{
+	TimeInForceGTC: "limit",
+	TimeInForceIOC: "ioc",
+	TimeInForceFOK: "fok",
+}
+
+// CancelOrder 撤销订单，返回撤单后的订单状态
+func (c *OKXClient) CancelOrder(symbol, orderID string) (*Order, error) {
+	instID, err := c.instID(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]string{"instId": instID, "ordId": orderID}
+	if _, err := c.orderRequest("/api/v5/trade/cancel-order", body); err != nil {
+		return nil, err
+	}
+
+	return c.GetOrder(symbol, orderID)
+}
+
+// GetOrder 查询订单
+func (c *OKXClient) GetOrder(symbol, orderID string) (*Order, error) {
+	instID, err := c.instID(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("instId", instID)
+	params.Add("ordId", orderID)
+
+	var orders []okxOrder
+	if err := c.doRequest("GET", "/api/v5/trade/order", params, nil, true, &orders); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("订单 %s 不存在", orderID)
+	}
+
+	return orders[0].toOrder(), nil
+}
+
+// GetOrderByClientID 按客户端订单ID查询订单
+func (c *OKXClient) GetOrderByClientID(symbol, clientOrderID string) (*Order, error) {
+	instID, err := c.instID(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("instId", instID)
+	params.Add("clOrdId", clientOrderID)
+
+	var orders []okxOrder
+	if err := c.doRequest("GET", "/api/v5/trade/order", params, nil, true, &orders); err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, clientOrderID)
+	}
+
+	return orders[0].toOrder(), nil
+}
+
+// GetOpenOrders 获取未成交订单
+func (c *OKXClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	params := url.Values{}
+	params.Add("instType", "SPOT")
+	if symbol != "" {
+		instID, err := c.instID(symbol)
+		if err != nil {
+			return nil, err
+		}
+		params.Add("instId", instID)
+	}
+
+	var raw []okxOrder
+	if err := c.doRequest("GET", "/api/v5/trade/orders-pending", params, nil, true, &raw); err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, len(raw))
+	for i := range raw {
+		orders[i] = raw[i].toOrder()
+	}
+	return orders, nil
+}
+
+// orderRequest 发送下单或撤单请求，单个订单的 sCode 非0时返回错误
+func (c *OKXClient) orderRequest(endpoint string, body map[string]string) (*okxOrderResult, error) {
+	var results []okxOrderResult
+	if err := c.doRequest("POST", endpoint, nil, body, true, &results); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("订单请求无返回结果")
+	}
+	if results[0].SCode != "0" {
+		return nil, fmt.Errorf("订单请求失败 (sCode %s): %s", results[0].SCode, results[0].SMsg)
+	}
+	return &results[0], nil
+}
+
+// ===== 推送 =====
+
+// SubscribeTickers 订阅行情频道 (tickers)，symbols 为空时订阅全部可交易的现货交易对
+func (c *OKXClient) SubscribeTickers(symbols []string, callback func(*Ticker)) (Stream, error) {
+	if len(symbols) == 0 {
+		infos, err := c.GetSymbols()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.Status == SymbolStatusTrading {
+				symbols = append(symbols, info.Symbol)
+			}
+		}
+	}
+
+	args, err := c.channelArgs("tickers", symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOKXStream(c.PublicStreamURL, args, nil, func(push *okxPush) {
+		var tickers []okxTicker
+		if err := json.Unmarshal(push.Data, &tickers); err != nil {
+			log.Printf("解析OKX行情推送失败 (%s): %v", push.Arg.InstID, err)
+			return
+		}
+		for i := range tickers {
+			callback(tickers[i].toTicker())
+		}
+	})
+}
+
+// SubscribeDepth 订阅深度频道 (books)
+// 订阅后先推送完整快照，之后每个增量的 prevSeqId 应等于上一条的 seqId；
+// 序号不连续时丢弃该增量并重新订阅，以获取新的快照
+func (c *OKXClient) SubscribeDepth(symbols []string, callback func(*DepthUpdate)) (Stream, error) {
+	args, err := c.channelArgs("books", symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream *OKXStream
+	lastSeq := make(map[string]int64) // 只在读取协程中访问
+	stream, err = newOKXStream(c.PublicStreamURL, args, nil, func(push *okxPush) {
+		var books []okxBook
+		if err := json.Unmarshal(push.Data, &books); err != nil || len(books) == 0 {
+			log.Printf("解析OKX深度推送失败 (%s): %v", push.Arg.InstID, err)
+			return
+		}
+		book := books[0]
+
+		bids, err := parseDepthLevels(book.Bids)
+		if err != nil {
+			log.Printf("解析OKX深度推送失败 (%s): %v", push.Arg.InstID, err)
+			return
+		}
+		asks, err := parseDepthLevels(book.Asks)
+		if err != nil {
+			log.Printf("解析OKX深度推送失败 (%s): %v", push.Arg.InstID, err)
+			return
+		}
+
+		update := &DepthUpdate{
+			Symbol:        okxSymbol(push.Arg.InstID),
+			FirstUpdateID: book.PrevSeqID + 1,
+			FinalUpdateID: book.SeqID,
+			Bids:          bids,
+			Asks:          asks,
+			EventTime:     okxTime(book.Ts),
+		}
+
+		if push.Action == "snapshot" {
+			update.FirstUpdateID = 0
+			update.Snapshot = true
+		} else if last, ok := lastSeq[push.Arg.InstID]; !ok || book.PrevSeqID != last {
+			log.Printf("OKX %s 深度序号不连续 (prevSeqId=%d)，重新订阅", push.Arg.InstID, book.PrevSeqID)
+			delete(lastSeq, push.Arg.InstID)
+			go stream.resubscribe(push.Arg)
+			return
+		}
+
+		lastSeq[push.Arg.InstID] = book.SeqID
+		callback(update)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// SubscribeOrders 订阅私有订单频道 (orders)，推送现货订单的状态变化
+func (c *OKXClient) SubscribeOrders(callback func(*Order)) (Stream, error) {
+	args := []okxArg{{Channel: "orders", InstType: "SPOT"}}
+	credentials := &okxCredentials{apiKey: c.APIKey, apiSecret: c.APISecret, passphrase: c.Passphrase}
+
+	return newOKXStream(c.PrivateStreamURL, args, credentials, func(push *okxPush) {
+		var orders []okxOrder
+		if err := json.Unmarshal(push.Data, &orders); err != nil {
+			log.Printf("解析OKX订单推送失败: %v", err)
+			return
+		}
+		for i := range orders {
+			callback(orders[i].toOrder())
+		}
+	})
+}
+
+// channelArgs 构建按产品订阅的频道参数
+func (c *OKXClient) channelArgs(channel string, symbols []string) ([]okxArg, error) {
+	args := make([]okxArg, len(symbols))
+	for i, symbol := range symbols {
+		instID, err := c.instID(symbol)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = okxArg{Channel: channel, InstID: instID}
+	}
+	return args, nil
+}
+
+// ===== 私有请求辅助方法 =====
+
+// doRequest 执行HTTP请求并将返回的 data 解析到 result
+// 签名为 Base64(HMAC-SHA256(timestamp + method + requestPath + body))
+func (c *OKXClient) doRequest(method string, endpoint string, params url.Values, body interface{}, signed bool, result interface{}) error {
+	requestPath := endpoint
+	if len(params) > 0 {
+		requestPath += "?" + params.Encode()
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+requestPath, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.IsTestnet {
+		req.Header.Set("x-simulated-trading", "1")
+	}
+	if signed {
+		timestamp := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+		req.Header.Set("OK-ACCESS-KEY", c.APIKey)
+		req.Header.Set("OK-ACCESS-SIGN", okxSign(c.APISecret, timestamp+method+requestPath+string(payload)))
+		req.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+		req.Header.Set("OK-ACCESS-PASSPHRASE", c.Passphrase)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	// 返回格式: {"code":"0","msg":"","data":[...]}
+	var envelope struct {
+		Code string          `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("API错误 (HTTP %d): %s", resp.StatusCode, string(respBody))
+		}
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || envelope.Code != "0" {
+		// 批量类接口的具体原因在 data[].sMsg 中
+		msg := envelope.Msg
+		var results []okxOrderResult
+		if json.Unmarshal(envelope.Data, &results) == nil && len(results) > 0 && results[0].SMsg != "" {
+			msg = results[0].SMsg
+		}
+		if envelope.Code == okxErrOrderNotFound {
+			return fmt.Errorf("%w (code %s): %s", ErrOrderNotFound, envelope.Code, msg)
+		}
+		return fmt.Errorf("API错误 (HTTP %d, code %s): %s", resp.StatusCode, envelope.Code, msg)
+	}
+
+	if err := json.Unmarshal(envelope.Data, result); err != nil {
+		return fmt.Errorf("解析响应数据失败: %w", err)
+	}
+	return nil
+}
+
+// instID 获取交易对对应的产品ID，未知时刷新产品列表
+func (c *OKXClient) instID(symbol string) (string, error) {
+	c.mu.RLock()
+	instID, ok := c.instIDs[symbol]
+	c.mu.RUnlock()
+	if ok {
+		return instID, nil
+	}
+
+	if _, err := c.GetSymbols(); err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	instID, ok = c.instIDs[symbol]
+	c.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("交易对 %s 不存在", symbol)
+	}
+	return instID, nil
+}
+
+// ===== 辅助函数 =====
+
+// okxSign 计算 Base64(HMAC-SHA256) 签名
+func okxSign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// okxSymbol 将产品ID转换为交易对，例如 BTC-USDT -> BTCUSDT
+func okxSymbol(instID string) string {
+	return strings.ReplaceAll(instID, "-", "")
+}
+
+// okxTime 解析毫秒时间戳字符串
+func okxTime(ms string) time.Time {
+	v, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || v <= 0 {
+		return time.Now()
+	}
+	return time.UnixMilli(v)
+}
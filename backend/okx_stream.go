@@ -0,0 +1,313 @@
+package exchange
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	okxPingInterval     = 20 * time.Second // 服务器30秒内未收到数据会断开连接，定期发送 "ping"
+	okxLoginTimeout     = 10 * time.Second // 等待登录结果的超时时间
+	okxSubscribeBatch   = 100              // 单条订阅消息最多包含的频道数量
+	okxLoginRequestPath = "/users/self/verify"
+)
+
+// okxArg OKX WebSocket频道参数
+type okxArg struct {
+	Channel  string `json:"channel"`
+	InstID   string `json:"instId,omitempty"`
+	InstType string `json:"instType,omitempty"`
+}
+
+// okxPush OKX WebSocket推送消息
+type okxPush struct {
+	Arg    okxArg          `json:"arg"`
+	Action string          `json:"action"` // 深度频道: snapshot 或 update
+	Data   json.RawMessage `json:"data"`
+}
+
+// okxCredentials 私有频道登录凭证
+type okxCredentials struct {
+	apiKey     string
+	apiSecret  string
+	passphrase string
+}
+
+// OKXStream OKX WebSocket连接（公共或私有频道）
+// 断线后按指数退避自动重连并重新订阅，私有频道在订阅前先登录
+type OKXStream struct {
+	url           string
+	args          []okxArg
+	credentials   *okxCredentials // 为nil时为公共频道
+	handler       func(push *okxPush)
+	onStateChange func(connected bool)
+
+	mu        sync.RWMutex
+	connected bool
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ Stream = (*OKXStream)(nil)
+
+// newOKXStream 创建OKX连接，调用 Start 后开始接收
+func newOKXStream(url string, args []okxArg, credentials *okxCredentials, handler func(push *okxPush)) (*OKXStream, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("未指定订阅的频道")
+	}
+
+	return &OKXStream{
+		url:         url,
+		args:        args,
+		credentials: credentials,
+		handler:     handler,
+		stopChan:    make(chan struct{}),
+	}, nil
+}
+
+// SetStateHandler 设置连接状态变化回调，需在 Start 之前调用
+func (s *OKXStream) SetStateHandler(handler func(connected bool)) {
+	s.onStateChange = handler
+}
+
+// Start 启动连接
+func (s *OKXStream) Start() {
+	go s.run()
+}
+
+// Stop 关闭连接并停止重连
+func (s *OKXStream) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
+
+// IsConnected 检查连接是否可用
+func (s *OKXStream) IsConnected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// setConnected 更新连接状态并通知回调
+func (s *OKXStream) setConnected(connected bool, conn *websocket.Conn) {
+	s.mu.Lock()
+	changed := s.connected != connected
+	s.connected = connected
+	s.conn = conn
+	s.mu.Unlock()
+
+	if changed && s.onStateChange != nil {
+		s.onStateChange(connected)
+	}
+}
+
+// resubscribe 取消并重新订阅频道，用于深度序号不连续时重新获取快照
+func (s *OKXStream) resubscribe(arg okxArg) {
+	s.mu.RLock()
+	conn := s.conn
+	s.mu.RUnlock()
+
+	if conn == nil {
+		return
+	}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "unsubscribe", "args": []okxArg{arg}}); err != nil {
+		log.Printf("OKX取消订阅失败: %v", err)
+		return
+	}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "subscribe", "args": []okxArg{arg}}); err != nil {
+		log.Printf("OKX重新订阅失败: %v", err)
+	}
+}
+
+// run 维护连接的主循环
+func (s *OKXStream) run() {
+	defer s.setConnected(false, nil)
+
+	backoff := streamMinBackoff
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(s.url, nil)
+		if err == nil {
+			err = s.setup(conn)
+			if err != nil {
+				conn.Close()
+			}
+		}
+
+		if err != nil {
+			s.setConnected(false, nil)
+			log.Printf("OKX连接失败: %v, %v 后重试", err, backoff)
+
+			select {
+			case <-s.stopChan:
+				return
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+			if backoff > streamMaxBackoff {
+				backoff = streamMaxBackoff
+			}
+			continue
+		}
+
+		backoff = streamMinBackoff
+		s.setConnected(true, conn)
+
+		err = s.serve(conn)
+		conn.Close()
+
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+
+		s.setConnected(false, nil)
+		log.Printf("OKX连接断开: %v, 正在重连", err)
+	}
+}
+
+// setup 登录（私有频道）并订阅全部频道
+func (s *OKXStream) setup(conn *websocket.Conn) error {
+	if s.credentials != nil {
+		if err := s.login(conn); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(s.args); start += okxSubscribeBatch {
+		end := start + okxSubscribeBatch
+		if end > len(s.args) {
+			end = len(s.args)
+		}
+		if err := s.writeJSON(conn, map[string]interface{}{"op": "subscribe", "args": s.args[start:end]}); err != nil {
+			return fmt.Errorf("订阅失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// login 登录私有频道，签名内容为 timestamp + "GET" + "/users/self/verify"
+func (s *OKXStream) login(conn *websocket.Conn) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	args := []map[string]string{{
+		"apiKey":     s.credentials.apiKey,
+		"passphrase": s.credentials.passphrase,
+		"timestamp":  timestamp,
+		"sign":       okxSign(s.credentials.apiSecret, timestamp+"GET"+okxLoginRequestPath),
+	}}
+	if err := s.writeJSON(conn, map[string]interface{}{"op": "login", "args": args}); err != nil {
+		return fmt.Errorf("发送登录请求失败: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(okxLoginTimeout))
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("等待登录结果失败: %w", err)
+		}
+
+		var event struct {
+			Event string `json:"event"`
+			Code  string `json:"code"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			continue
+		}
+		switch event.Event {
+		case "login":
+			if event.Code != "0" {
+				return fmt.Errorf("登录失败 (code %s): %s", event.Code, event.Msg)
+			}
+			return nil
+		case "error":
+			return fmt.Errorf("登录失败 (code %s): %s", event.Code, event.Msg)
+		}
+	}
+}
+
+// serve 处理单个连接，连接出错或停止时返回
+func (s *OKXStream) serve(conn *websocket.Conn) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- s.readLoop(conn)
+	}()
+
+	ping := time.NewTicker(okxPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			s.writeMu.Lock()
+			conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(streamWriteTimeout),
+			)
+			s.writeMu.Unlock()
+			return nil
+		case err := <-errChan:
+			return err
+		case <-ping.C:
+			s.writeMu.Lock()
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			err := conn.WriteMessage(websocket.TextMessage, []byte("ping"))
+			s.writeMu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLoop 读取消息并分发
+func (s *OKXStream) readLoop(conn *websocket.Conn) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if string(message) == "pong" {
+			continue
+		}
+
+		var push struct {
+			okxPush
+			Event string `json:"event"`
+			Code  string `json:"code"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal(message, &push); err != nil {
+			continue
+		}
+		if push.Event == "error" {
+			log.Printf("OKX推送错误 (code %s): %s", push.Code, push.Msg)
+			continue
+		}
+		if push.Event != "" || len(push.Data) == 0 {
+			continue
+		}
+		s.handler(&push.okxPush)
+	}
+}
+
+// writeJSON 发送JSON消息
+func (s *OKXStream) writeJSON(conn *websocket.Conn, v interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return conn.WriteJSON(v)
+}
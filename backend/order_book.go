@@ -0,0 +1,123 @@
+package exchange
+
+import "time"
+
+// OrderBookLevel 订单簿档位
+type OrderBookLevel struct {
+	Price    float64
+	Quantity float64
+}
+
+// OrderBook 订单簿深度
+type OrderBook struct {
+	Symbol       string
+	LastUpdateID int64
+	Bids         []OrderBookLevel // 买单，价格从高到低
+	Asks         []OrderBookLevel // 卖单，价格从低到高
+	UpdatedAt    time.Time
+}
+
+// DepthFill 按订单簿深度吃单的结果（未扣除手续费）
+type DepthFill struct {
+	AmountIn   float64 // 实际付出的资产数量
+	AmountOut  float64 // 实际得到的资产数量
+	AvgPrice   float64 // 成交均价 (报价资产/基础资产)，用于估算利润
+	BestPrice  float64 // 盘口最优价
+	WorstPrice float64 // 吃到的最差一档价格，按该价格下IOC限价单才能吃完计算的全部档位
+	Complete   bool    // 深度是否足以吃完全部数量
+}
+
+// Fill 按方向吃单
+// BUY: amountIn 为报价资产数量，逐档吃卖单；SELL: amountIn 为基础资产数量，逐档吃买单
+func (ob *OrderBook) Fill(side string, amountIn float64) *DepthFill {
+	if side == "BUY" {
+		return ob.fillBuy(amountIn)
+	}
+	return ob.fillSell(amountIn)
+}
+
+// Capacity 获取指定方向订单簿可承载的最大付出数量
+// BUY 以报价资产计，SELL 以基础资产计
+func (ob *OrderBook) Capacity(side string) float64 {
+	capacity := 0.0
+	if side == "BUY" {
+		for _, level := range ob.Asks {
+			capacity += level.Price * level.Quantity
+		}
+		return capacity
+	}
+
+	for _, level := range ob.Bids {
+		capacity += level.Quantity
+	}
+	return capacity
+}
+
+// fillBuy 用报价资产逐档买入基础资产
+func (ob *OrderBook) fillBuy(quoteAmount float64) *DepthFill {
+	fill := &DepthFill{}
+	if len(ob.Asks) > 0 {
+		fill.BestPrice = ob.Asks[0].Price
+	}
+
+	remaining := quoteAmount
+	for _, level := range ob.Asks {
+		if remaining <= 0 {
+			break
+		}
+		if level.Price <= 0 || level.Quantity <= 0 {
+			continue
+		}
+
+		fill.WorstPrice = level.Price
+		cost := level.Price * level.Quantity
+		if cost >= remaining {
+			fill.AmountOut += remaining / level.Price
+			remaining = 0
+			break
+		}
+		fill.AmountOut += level.Quantity
+		remaining -= cost
+	}
+
+	fill.AmountIn = quoteAmount - remaining
+	fill.Complete = remaining <= quoteAmount*1e-12
+	if fill.AmountOut > 0 {
+		fill.AvgPrice = fill.AmountIn / fill.AmountOut
+	}
+	return fill
+}
+
+// fillSell 逐档卖出基础资产得到报价资产
+func (ob *OrderBook) fillSell(baseQty float64) *DepthFill {
+	fill := &DepthFill{}
+	if len(ob.Bids) > 0 {
+		fill.BestPrice = ob.Bids[0].Price
+	}
+
+	remaining := baseQty
+	for _, level := range ob.Bids {
+		if remaining <= 0 {
+			break
+		}
+		if level.Price <= 0 || level.Quantity <= 0 {
+			continue
+		}
+
+		fill.WorstPrice = level.Price
+		if level.Quantity >= remaining {
+			fill.AmountOut += remaining * level.Price
+			remaining = 0
+			break
+		}
+		fill.AmountOut += level.Quantity * level.Price
+		remaining -= level.Quantity
+	}
+
+	fill.AmountIn = baseQty - remaining
+	fill.Complete = remaining <= baseQty*1e-12
+	if fill.AmountIn > 0 {
+		fill.AvgPrice = fill.AmountOut / fill.AmountIn
+	}
+	return fill
+}
@@ -0,0 +1,153 @@
+package main
+
+import "math"
+
+// sizingIterations 黄金分割搜索的迭代次数
+const sizingIterations = 40
+
+// goldenRatio 黄金分割比例
+var goldenRatio = (math.Sqrt(5) - 1) / 2
+
+// SizingLimits 交易规模约束（以起始资产计，0 表示不限制）
+type SizingLimits struct {
+	MinAmount        float64 // 策略最小交易金额
+	MaxAmount        float64 // 策略最大交易金额
+	Balance          float64 // 起始资产可用余额
+	MinProfitPercent float64 // 策略最低利润百分比，为0时只要求净利润为正
+}
+
+// NewSizingLimits 根据策略和可用余额构建交易规模约束
+func NewSizingLimits(strategy *Strategy, balance float64) SizingLimits {
+	limits := SizingLimits{Balance: balance}
+	if strategy != nil {
+		limits.MinAmount = strategy.MinTradeAmount
+		limits.MaxAmount = strategy.MaxTradeAmount
+		limits.MinProfitPercent = strategy.MinProfitPercentage
+	}
+	return limits
+}
+
+// OptimizeSize 在约束范围内寻找绝对净利润最大的起始金额，返回按该金额重新计算的套利机会
+// 净利润随金额先增后减（价差收益线性增长，深度滑点逐档加剧），因此用黄金分割搜索求极值。
+// 不存在满足全部约束且达到策略最低利润的金额时返回nil
+func (e *ArbitrageEngine) OptimizeSize(opp *ArbitrageOpportunity, limits SizingLimits) *ArbitrageOpportunity {
+	legs := e.legsOf(opp)
+	if legs == nil {
+		return nil
+	}
+
+	lo, hi, limit := e.sizeBounds(opp, limits)
+	if hi <= 0 || lo > hi {
+		return nil
+	}
+
+	evaluate := func(amount float64) *ArbitrageOpportunity {
+		candidate := e.simulateLegs(opp.StartAsset, legs, amount)
+		if candidate == nil || !e.applyDepth(candidate, legs) {
+			return nil
+		}
+		return candidate
+	}
+	profit := func(candidate *ArbitrageOpportunity) float64 {
+		if candidate == nil {
+			return math.Inf(-1)
+		}
+		return candidate.NetProfit
+	}
+
+	best := evaluate(hi)
+	if lower := evaluate(lo); profit(lower) > profit(best) {
+		best = lower
+	}
+
+	a, b := lo, hi
+	x1 := b - goldenRatio*(b-a)
+	x2 := a + goldenRatio*(b-a)
+	c1, c2 := evaluate(x1), evaluate(x2)
+	for i := 0; i < sizingIterations && b-a > 1e-8*hi; i++ {
+		if profit(c1) >= profit(c2) {
+			b, x2, c2 = x2, x1, c1
+			x1 = b - goldenRatio*(b-a)
+			c1 = evaluate(x1)
+		} else {
+			a, x1, c1 = x1, x2, c2
+			x2 = a + goldenRatio*(b-a)
+			c2 = evaluate(x2)
+		}
+	}
+	for _, candidate := range []*ArbitrageOpportunity{c1, c2} {
+		if profit(candidate) > profit(best) {
+			best = candidate
+		}
+	}
+
+	if best == nil || best.NetProfit <= 0 || best.ProfitPercentage < limits.MinProfitPercent {
+		return nil
+	}
+
+	best.ID = opp.ID
+	best.Details.MinAmount = lo
+	if best.InitialAmount < hi*(1-1e-6) {
+		limit = "profit"
+	}
+	best.Details.SizeLimit = limit
+	return best
+}
+
+// sizeBounds 计算起始金额的上下限及决定上限的约束
+// 交易所过滤器按各步骤数量与起始金额的比例折算为起始资产
+func (e *ArbitrageEngine) sizeBounds(opp *ArbitrageOpportunity, limits SizingLimits) (lo float64, hi float64, limit string) {
+	lo = limits.MinAmount
+	hi = opp.Details.MaxAmount
+	limit = "depth"
+
+	if limits.MaxAmount > 0 && limits.MaxAmount < hi {
+		hi, limit = limits.MaxAmount, "strategy"
+	}
+	if limits.Balance > 0 && limits.Balance < hi {
+		hi, limit = limits.Balance, "balance"
+	}
+
+	for _, step := range opp.Details.Steps() {
+		rules := e.marketManager.GetTradingRules(step.Symbol)
+		if rules == nil || step.Quantity <= 0 {
+			continue
+		}
+
+		// 每单位起始资产对应的基础资产数量和名义价值
+		qtyRatio := step.Quantity / opp.InitialAmount
+		notionalRatio := qtyRatio * step.Price
+
+		lo = math.Max(lo, rules.MinQty/qtyRatio)
+		lo = math.Max(lo, rules.MinNotional/notionalRatio)
+		if rules.MaxQty > 0 && rules.MaxQty/qtyRatio < hi {
+			hi, limit = rules.MaxQty/qtyRatio, "exchange"
+		}
+	}
+
+	return lo, hi, limit
+}
+
+// legsOf 根据套利机会的交易步骤还原闭环的有向边
+func (e *ArbitrageEngine) legsOf(opp *ArbitrageOpportunity) []*CurrencyEdge {
+	if opp.Details == nil {
+		return nil
+	}
+
+	steps := opp.Details.Steps()
+	legs := make([]*CurrencyEdge, len(steps))
+	for i, step := range steps {
+		info := e.marketManager.GetSymbolInfo(step.Symbol)
+		if info == nil {
+			return nil
+		}
+
+		legs[i] = &CurrencyEdge{Symbol: step.Symbol, Side: step.Side}
+		if step.Side == "BUY" {
+			legs[i].From, legs[i].To = info.QuoteAsset, info.BaseAsset
+		} else {
+			legs[i].From, legs[i].To = info.BaseAsset, info.QuoteAsset
+		}
+	}
+	return legs
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// preTradeDataMaxAge 发送第一步订单前行情数据允许的最长未更新时间
+const preTradeDataMaxAge = 10 * time.Second
+
+// PreTradeCheck 发送第一步订单前的校验条件，零值不做任何校验
+// 跨交易所套利的两侧行情不在套利引擎的行情管理器中，Engine 为nil：
+// 机会由 CrossExchangeArbitrage.Scan 在下单前按两侧最新盘口计算，行情是否新鲜由调用方检查，只校验有效时间和最低利润
+type PreTradeCheck struct {
+	Engine           *ArbitrageEngine // 按最新行情重新定价的套利引擎，为nil时不重新定价也不检查行情是否新鲜
+	MinProfitPercent float64          // 重新定价后的最低利润百分比，Engine 为nil时按机会本身的利润校验
+	MaxAge           time.Duration    // 套利机会的最长有效时间，为0时不限制
+	DataMaxAge       time.Duration    // 行情数据允许的最长未更新时间
+}
+
+// NewPreTradeCheck 按策略配置创建交易前校验，strategy 为nil时只要求重新定价后仍有利润
+// engine 为nil时不重新定价，用于跨交易所套利
+func NewPreTradeCheck(engine *ArbitrageEngine, strategy *Strategy) PreTradeCheck {
+	check := PreTradeCheck{
+		Engine:     engine,
+		MaxAge:     opportunityMaxAge,
+		DataMaxAge: preTradeDataMaxAge,
+	}
+	if strategy != nil {
+		check.MinProfitPercent = strategy.MinProfitPercentage
+	}
+	return check
+}
+
+// Validate 校验套利机会是否仍可执行，返回按最新行情重新定价后的机会
+// 超过有效时间、行情数据过旧、无法重新定价或利润低于要求时返回错误
+func (c PreTradeCheck) Validate(opp *ArbitrageOpportunity) (*ArbitrageOpportunity, error) {
+	if age := time.Since(opp.Timestamp); c.MaxAge > 0 && age > c.MaxAge {
+		return nil, fmt.Errorf("套利机会已过期 (%s > %s)", age.Round(time.Millisecond), c.MaxAge)
+	}
+	if c.Engine == nil {
+		if opp.ProfitPercentage < c.MinProfitPercent {
+			return nil, fmt.Errorf("利润 %.4f%% 低于要求 %.4f%%", opp.ProfitPercentage, c.MinProfitPercent)
+		}
+		return opp, nil
+	}
+
+	if !c.Engine.marketManager.IsDataFresh(c.DataMaxAge) {
+		return nil, fmt.Errorf("行情数据过旧")
+	}
+
+	fresh := c.Engine.Reprice(opp)
+	if fresh == nil {
+		return nil, fmt.Errorf("按最新行情无法完成全部步骤")
+	}
+	if fresh.NetProfit <= 0 || fresh.ProfitPercentage < c.MinProfitPercent {
+		return nil, fmt.Errorf("按最新行情利润降至 %.4f%% (发现时 %.4f%%，要求 %.4f%%)",
+			fresh.ProfitPercentage, opp.ProfitPercentage, c.MinProfitPercent)
+	}
+	return fresh, nil
+}
+
+// Reprice 按最新盘口和订单簿深度以原起始金额重新计算套利机会，行情缺失或深度不足时返回nil
+// 重新计算的机会保留原机会的ID、规模约束和各步骤的下单方式
+func (e *ArbitrageEngine) Reprice(opp *ArbitrageOpportunity) *ArbitrageOpportunity {
+	legs := e.legsOf(opp)
+	if legs == nil {
+		return nil
+	}
+
+	fresh := e.simulateLegs(opp.StartAsset, legs, opp.InitialAmount)
+	if fresh == nil || !e.applyDepth(fresh, legs) {
+		return nil
+	}
+
+	fresh.ID = opp.ID
+	fresh.Details.MinAmount = opp.Details.MinAmount
+	fresh.Details.SizeLimit = opp.Details.SizeLimit
+	planned := opp.Details.Steps()
+	for i, step := range fresh.Details.Steps() {
+		step.Exchange = planned[i].Exchange
+		step.OrderType = planned[i].OrderType
+		step.TimeInForce = planned[i].TimeInForce
+	}
+	return fresh
+}
+
+// logPreTradeAbort 将放弃执行的原因写入系统日志
+func (e *TradeExecutor) logPreTradeAbort(botID int64, opp *ArbitrageOpportunity, reason error) {
+	message := fmt.Sprintf("放弃执行套利机会 %s: %v", opp.ID, reason)
+	log.Printf("机器人 %d: %s", botID, message)
+	if e.db == nil {
+		return
+	}
+
+	if err := e.db.LogSystemEvent(&botID, "WARN", message, nil); err != nil {
+		log.Printf("写入系统日志失败: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"inarbit/exchange"
 )
 
 // SimulatedTrade 模拟交易
@@ -33,6 +35,7 @@ type SimulatedExchange struct {
 	account *SimulatedAccount
 	trades  map[string]*SimulatedTrade
 	prices  map[string]float64
+	fees    *exchange.FeeModel
 	mu      sync.RWMutex
 }
 
@@ -49,9 +52,17 @@ func NewSimulatedExchange(initialBalance map[string]float64) *SimulatedExchange
 		},
 		trades: make(map[string]*SimulatedTrade),
 		prices: make(map[string]float64),
+		fees:   exchange.NewFeeModel(nil),
 	}
 }
 
+// SetFeeModel 设置手续费模型，默认按 0.1% 吃单费率从成交所得中扣除
+func (se *SimulatedExchange) SetFeeModel(fees *exchange.FeeModel) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	se.fees = fees
+}
+
 // SetPrice 设置交易对价格
 func (se *SimulatedExchange) SetPrice(symbol string, price float64) {
 	se.mu.Lock()
@@ -107,22 +118,31 @@ func (se *SimulatedExchange) PlaceOrder(symbol, side string, quantity, price flo
 }
 
 // executeOrder 执行订单
+// 手续费按吃单费率计算：开启抵扣且抵扣资产余额足够时以抵扣资产支付；
+// 否则卖出从报价资产中扣除，买入以基础资产计，只记录不扣除余额（便于按下单数量平仓）
 func (se *SimulatedExchange) executeOrder(trade *SimulatedTrade, baseAsset, quoteAsset string) {
-	// 计算手续费（0.1%）
-	commission := trade.Quantity * trade.Price * 0.001
+	notional := trade.Quantity * trade.Price
 
 	if trade.Side == "BUY" {
 		// 买入
-		se.account.Balances[quoteAsset] -= (trade.Quantity * trade.Price)
+		se.account.Balances[quoteAsset] -= notional
 		se.account.Balances[baseAsset] += trade.Quantity
-		trade.CommissionAsset = baseAsset
-		trade.Commission = trade.Quantity * 0.001
 	} else if trade.Side == "SELL" {
 		// 卖出
 		se.account.Balances[baseAsset] -= trade.Quantity
-		se.account.Balances[quoteAsset] += (trade.Quantity * trade.Price) - commission
-		trade.CommissionAsset = quoteAsset
-		trade.Commission = commission
+		se.account.Balances[quoteAsset] += notional
+	}
+
+	fee := se.fees.Fee(trade.Symbol)
+	if !se.payDiscountCommission(trade, fee, quoteAsset, notional) {
+		if trade.Side == "BUY" {
+			trade.CommissionAsset = baseAsset
+			trade.Commission = trade.Quantity * fee.Taker
+		} else {
+			trade.CommissionAsset = quoteAsset
+			trade.Commission = notional * fee.Taker
+			se.account.Balances[quoteAsset] -= trade.Commission
+		}
 	}
 
 	trade.ExecutedQty = trade.Quantity
@@ -131,6 +151,42 @@ func (se *SimulatedExchange) executeOrder(trade *SimulatedTrade, baseAsset, quot
 	trade.UpdatedAt = time.Now()
 }
 
+// payDiscountCommission 以抵扣资产支付手续费，未开启抵扣、缺少价格或余额不足时返回false
+func (se *SimulatedExchange) payDiscountCommission(trade *SimulatedTrade, fee exchange.TradeFee, quoteAsset string, notional float64) bool {
+	if fee.DiscountAsset == "" {
+		return false
+	}
+
+	price := se.priceIn(fee.DiscountAsset, quoteAsset)
+	if price <= 0 {
+		return false
+	}
+
+	commission := notional * fee.DiscountTaker / price
+	if se.account.Balances[fee.DiscountAsset] < commission {
+		return false
+	}
+
+	se.account.Balances[fee.DiscountAsset] -= commission
+	trade.CommissionAsset = fee.DiscountAsset
+	trade.Commission = commission
+	return true
+}
+
+// priceIn 获取 asset 以 quoteAsset 计的价格，没有对应交易对的价格时返回0
+func (se *SimulatedExchange) priceIn(asset, quoteAsset string) float64 {
+	if asset == quoteAsset {
+		return 1
+	}
+	if price := se.prices[asset+quoteAsset]; price > 0 {
+		return price
+	}
+	if price := se.prices[quoteAsset+asset]; price > 0 {
+		return 1 / price
+	}
+	return 0
+}
+
 // GetBalance 获取余额
 func (se *SimulatedExchange) GetBalance(asset string) float64 {
 	se.mu.RLock()
@@ -234,17 +290,17 @@ func parseSymbol(symbol string) (string, string) {
 
 // SimulationResult 模拟结果
 type SimulationResult struct {
-	InitialBalance map[string]float64
-	FinalBalance   map[string]float64
-	Profit         map[string]float64
-	ProfitPercent  map[string]float64
-	TotalTrades    int
+	InitialBalance   map[string]float64
+	FinalBalance     map[string]float64
+	Profit           map[string]float64
+	ProfitPercent    map[string]float64
+	TotalTrades      int
 	SuccessfulTrades int
-	FailedTrades   int
-	TotalCommission float64
-	ExecutionTime  time.Duration
-	StartTime      time.Time
-	EndTime        time.Time
+	FailedTrades     int
+	TotalCommission  float64
+	ExecutionTime    time.Duration
+	StartTime        time.Time
+	EndTime          time.Time
 }
 
 // RunSimulation 运行模拟
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
+
+	"inarbit/exchange"
 )
 
 // TradeExecution 交易执行记录
@@ -13,14 +17,19 @@ type TradeExecution struct {
 	BotID               int64
 	OpportunityID       string
 	Status              string // pending, executing, completed, failed, cancelled
-	Type                string // triangular, quadrangular, pentagonal
+	Type                string // triangular, quadrangular, pentagonal, cross_exchange
+	IsSimulation        bool
 	Path                []string
+	StartAsset          string
+	Steps               []*TradeStep // 计划的交易步骤，重启后据此继续执行
+	Unwind              UnwindPolicy // 某一步失败后处理中间资产的策略
 	InitialAmount       float64
 	FinalAmount         float64
 	ActualProfit        float64
 	ActualProfitPercent float64
 	TotalFees           float64
 	Slippage            float64
+	RealizedLoss        float64 // 回滚中间资产实际造成的亏损（起始资产计）
 	Orders              []*ExecutedOrder
 	StartTime           time.Time
 	EndTime             time.Time
@@ -28,49 +37,119 @@ type TradeExecution struct {
 	ErrorMessage        string
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
+
+	attempts map[int]int // 步骤 -> 已下单次数，用于生成不重复的客户端订单ID
 }
 
 // ExecutedOrder 已执行的订单
 type ExecutedOrder struct {
-	OrderID       int64
-	Symbol        string
-	Side          string
-	Type          string
-	Price         float64
-	Quantity      float64
-	ExecutedQty   float64
+	Step           int // 第几步（从1开始），超过计划步骤数的是回滚订单
+	Exchange       string
+	OrderID        string
+	ClientOrderID  string // 由交易ID、步骤和下单次数生成，保存到数据库，重启后据此查询订单
+	Attempt        int    // 该步骤的第几次下单（从1开始）
+	Symbol         string
+	Side           string
+	Type           string
+	Price          float64
+	Quantity       float64
+	ExecutedQty    float64
 	CummulativeQty float64
-	Status        string
-	Fee           float64
-	FeeAsset      string
-	ExecutedAt    time.Time
+	AvgPrice       float64 // 成交均价
+	Status         string
+	Fee            float64 // 按成交明细计算，缺少明细的成交按费率估算
+	FeeAsset       string
+	Fills          []*exchange.Execution // 成交明细，来自下单响应和账户推送
+	ExecutedAt     time.Time
 }
 
 // TradeExecutor 交易执行器
 type TradeExecutor struct {
-	client              *BinanceClient
+	client              exchange.Exchange
+	venues              map[string]exchange.Exchange // 跨交易所套利使用的其他交易所
 	marketManager       *MarketManager
+	accountManager      *AccountManager // 默认交易所的账户推送，为nil时轮询订单状态
 	db                  *Database
 	maxConcurrentTrades int
+	orderTimeout        time.Duration // 等待订单结束的最长时间，超时后撤销未成交的剩余部分
 	executingTrades     map[string]*TradeExecution
 	mu                  sync.RWMutex
 	stopChan            chan struct{}
 }
 
+// defaultOrderTimeout 默认等待订单结束的最长时间
+const defaultOrderTimeout = 30 * time.Second
+
+// 下单结果未知时确认订单状态并重新下单的次数和间隔
+const (
+	placeOrderAttempts   = 3
+	placeOrderRetryDelay = 500 * time.Millisecond
+)
+
+// maxClientOrderIDLength 客户端订单ID的最大长度（OKX 限制为32个字符）
+const maxClientOrderIDLength = 32
+
 // NewTradeExecutor 创建交易执行器
-func NewTradeExecutor(client *BinanceClient, marketManager *MarketManager, db *Database) *TradeExecutor {
+func NewTradeExecutor(client exchange.Exchange, marketManager *MarketManager, db *Database) *TradeExecutor {
 	return &TradeExecutor{
 		client:              client,
+		venues:              make(map[string]exchange.Exchange),
 		marketManager:       marketManager,
 		db:                  db,
 		maxConcurrentTrades: 5,
+		orderTimeout:        defaultOrderTimeout,
 		executingTrades:     make(map[string]*TradeExecution),
 		stopChan:            make(chan struct{}),
 	}
 }
 
-// ExecuteArbitrage 执行套利交易
-func (e *TradeExecutor) ExecuteArbitrage(botID int64, opp *ArbitrageOpportunity, isSimulation bool) (*TradeExecution, error) {
+// SetAccountManager 设置默认交易所的账户管理器，之后由推送获取订单成交状态
+func (e *TradeExecutor) SetAccountManager(accountManager *AccountManager) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.accountManager = accountManager
+}
+
+// AccountManager 获取默认交易所的账户管理器，未设置时返回nil
+func (e *TradeExecutor) AccountManager() *AccountManager {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.accountManager
+}
+
+// RegisterVenue 注册跨交易所套利使用的交易所，name 与 TradeStep.Exchange 对应
+func (e *TradeExecutor) RegisterVenue(name string, client exchange.Exchange) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.venues[name] = client
+}
+
+// UnregisterVenue 移除已注册的交易所
+func (e *TradeExecutor) UnregisterVenue(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.venues, name)
+}
+
+// clientFor 获取步骤对应的交易所客户端，name 为空时使用默认交易所
+func (e *TradeExecutor) clientFor(name string) (exchange.Exchange, error) {
+	if name == "" {
+		return e.client, nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	client, ok := e.venues[name]
+	if !ok {
+		return nil, fmt.Errorf("交易所 %s 未注册", name)
+	}
+	return client, nil
+}
+
+// ExecuteArbitrage 执行套利交易，unwind 为某一步失败后处理已持有中间资产的策略
+// 发送第一步订单前按 check 以最新行情重新定价，未通过校验时放弃执行并写入系统日志
+func (e *TradeExecutor) ExecuteArbitrage(botID int64, opp *ArbitrageOpportunity, isSimulation bool, unwind UnwindPolicy, check PreTradeCheck) (*TradeExecution, error) {
 	// 检查并发限制
 	e.mu.Lock()
 	if len(e.executingTrades) >= e.maxConcurrentTrades {
@@ -79,29 +158,61 @@ func (e *TradeExecutor) ExecuteArbitrage(botID int64, opp *ArbitrageOpportunity,
 	}
 	e.mu.Unlock()
 
+	// 扫描到执行之间行情可能已经变化，按最新行情重新计算后再执行
+	fresh, err := check.Validate(opp)
+	if err != nil {
+		e.logPreTradeAbort(botID, opp, err)
+		return nil, fmt.Errorf("交易前校验未通过: %w", err)
+	}
+	opp = fresh
+
 	execution := &TradeExecution{
 		ID:            generateTradeID(),
 		BotID:         botID,
 		OpportunityID: opp.ID,
 		Status:        "pending",
 		Type:          opp.Type,
+		IsSimulation:  isSimulation,
 		Path:          opp.Path,
+		StartAsset:    opp.StartAsset,
+		Unwind:        unwind,
 		InitialAmount: opp.InitialAmount,
 		Orders:        make([]*ExecutedOrder, 0),
 		StartTime:     time.Now(),
 		CreatedAt:     time.Now(),
 	}
+	if opp.Details != nil {
+		execution.Steps = opp.Details.Steps()
+	}
+
+	// 占用起始资产，已被其他进行中的交易占用时拒绝执行
+	// 跨交易所套利在其他交易所下单，不占用默认交易所的余额
+	accountManager := e.AccountManager()
+	reserve := !isSimulation && opp.Type != StrategyCrossExchange && accountManager != nil
+	if reserve {
+		if err := accountManager.Reserve(opp.StartAsset, opp.InitialAmount, execution.ID); err != nil {
+			return nil, fmt.Errorf("占用起始资产失败: %w", err)
+		}
+	}
 
 	// 添加到执行中的交易列表
 	e.mu.Lock()
 	e.executingTrades[execution.ID] = execution
 	e.mu.Unlock()
+	e.recordExecution(execution)
 
 	// 执行交易
 	if isSimulation {
 		go e.executeSimulation(execution, opp)
+	} else if opp.Type == StrategyCrossExchange {
+		go e.executeCrossExchange(execution, opp)
 	} else {
-		go e.executeReal(execution, opp)
+		go func() {
+			if reserve {
+				defer accountManager.Release(execution.ID)
+			}
+			e.executeReal(execution)
+		}()
 	}
 
 	return execution, nil
@@ -110,6 +221,7 @@ func (e *TradeExecutor) ExecuteArbitrage(botID int64, opp *ArbitrageOpportunity,
 // executeSimulation 执行模拟交易
 func (e *TradeExecutor) executeSimulation(execution *TradeExecution, opp *ArbitrageOpportunity) {
 	execution.Status = "executing"
+	e.recordExecution(execution)
 
 	// 模拟交易延迟
 	time.Sleep(time.Duration(opp.ExecutionTime) * time.Millisecond)
@@ -117,8 +229,9 @@ func (e *TradeExecutor) executeSimulation(execution *TradeExecution, opp *Arbitr
 	// 模拟滑点
 	slippage := opp.NetProfit * 0.1 // 假设滑点为利润的10%
 
+	// 净利润已扣除以抵扣资产支付的手续费
 	execution.FinalAmount = opp.FinalAmount - slippage
-	execution.ActualProfit = execution.FinalAmount - execution.InitialAmount
+	execution.ActualProfit = opp.NetProfit - slippage
 	execution.ActualProfitPercent = (execution.ActualProfit / execution.InitialAmount) * 100
 	execution.TotalFees = opp.Details.TotalFees
 	execution.Slippage = slippage
@@ -138,171 +251,599 @@ func (e *TradeExecutor) executeSimulation(execution *TradeExecution, opp *Arbitr
 	e.mu.Unlock()
 }
 
-// executeReal 执行真实交易
-func (e *TradeExecutor) executeReal(execution *TradeExecution, opp *ArbitrageOpportunity) {
+// executeReal 执行真实交易，从第一个没有成交订单的步骤开始，重启后恢复的交易跳过已成交的步骤
+// 某一步失败时按交易的回滚策略处理之前步骤成交留下的中间资产
+func (e *TradeExecutor) executeReal(execution *TradeExecution) {
 	execution.Status = "executing"
+	e.recordExecution(execution)
 
-	// 执行第一步：买入第一个交易对
-	order1, err := e.executeStep(execution, opp.Details.Step1, 1)
-	if err != nil {
-		execution.Status = "failed"
-		execution.ErrorMessage = fmt.Sprintf("第一步失败: %v", err)
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
-		log.Printf("✗ 交易失败: %s, 错误: %s", execution.ID, execution.ErrorMessage)
-		return
-	}
-	execution.Orders = append(execution.Orders, order1)
+	for i := len(execution.Orders); i < len(execution.Steps); i++ {
+		stepNum := i + 1
 
-	// 等待订单成交
-	if !e.waitForOrder(opp.Path[0], order1.OrderID, 30*time.Second) {
-		execution.Status = "failed"
-		execution.ErrorMessage = "第一步订单超时"
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
-		return
+		// 第二步起按上一步的实际成交所得计算下单数量
+		step, err := e.chainStep(execution, execution.Steps[i])
+		if err != nil {
+			e.abortExecution(execution, fmt.Sprintf("第%d步失败: %v", stepNum, err))
+			return
+		}
+
+		// 执行当前步骤，下单结果未知的订单也加入记录，回滚前按客户端订单ID校正
+		order, err := e.executeStep(execution, step, stepNum, execution.nextAttempt(stepNum))
+		if order != nil {
+			execution.Orders = append(execution.Orders, order)
+			e.recordExecution(execution)
+		}
+		if err != nil {
+			e.abortExecution(execution, fmt.Sprintf("第%d步失败: %v", stepNum, err))
+			return
+		}
+
+		// 等待订单结束，部分成交时撤销剩余部分，按实际成交继续下一步
+		if !e.awaitFill(e.client, order, step) {
+			e.abortExecution(execution, fmt.Sprintf("第%d步订单未成交或超时", stepNum))
+			return
+		}
+		e.recordOrder(execution, order)
+		if order.Status != exchange.OrderStatusFilled {
+			log.Printf("第%d步部分成交: %.8f/%.8f %s", stepNum, order.ExecutedQty, order.Quantity, order.Symbol)
+		}
 	}
 
-	// 执行第二步：卖出第一个交易对，买入第二个
-	order2, err := e.executeStep(execution, opp.Details.Step2, 2)
-	if err != nil {
-		execution.Status = "failed"
-		execution.ErrorMessage = fmt.Sprintf("第二步失败: %v", err)
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
+	e.completeReal(execution)
+}
+
+// completeReal 按已成交的订单计算实际结果并将交易标记为完成
+func (e *TradeExecutor) completeReal(execution *TradeExecution) {
+	if len(execution.Orders) == 0 {
+		e.failExecution(execution, "没有成交的订单")
 		return
 	}
-	execution.Orders = append(execution.Orders, order2)
 
-	// 等待订单成交
-	if !e.waitForOrder(opp.Path[1], order2.OrderID, 30*time.Second) {
-		execution.Status = "failed"
-		execution.ErrorMessage = "第二步订单超时"
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
-		return
+	// 部分成交或舍入留下的中间资产按回滚策略处理，仍持有的按当前中间价折算计入结果
+	held, heldValue := e.settleLeftovers(execution)
+
+	// 计算实际结果，以抵扣资产支付的手续费没有从成交所得中扣除，折算为起始资产后计入成本
+	// 按起始资产的净变化计算，部分成交时未用完的起始资产不计为亏损
+	totalFees, commission := e.feeCosts(execution.Orders, execution.StartAsset)
+	execution.FinalAmount = execution.Orders[len(execution.Orders)-1].CummulativeQty
+	if changes, err := e.assetChanges(execution.Orders); err == nil {
+		execution.FinalAmount = execution.InitialAmount + changes[execution.StartAsset] + heldValue
 	}
+	if len(held) > 0 {
+		execution.ErrorMessage = fmt.Sprintf("部分成交剩余 %s 保留在账户中，按当前价格计入结果", strings.Join(held, ", "))
+		e.logHeldAssets(execution)
+	}
+	execution.ActualProfit = execution.FinalAmount - commission - execution.InitialAmount
+	execution.ActualProfitPercent = (execution.ActualProfit / execution.InitialAmount) * 100
+	execution.TotalFees = totalFees
+	execution.EndTime = time.Now()
+	execution.ExecutionTime = execution.EndTime.Sub(execution.StartTime).Milliseconds()
+	execution.Status = "completed"
+	execution.UpdatedAt = time.Now()
 
-	// 执行第三步：卖出第二个交易对
-	order3, err := e.executeStep(execution, opp.Details.Step3, 3)
-	if err != nil {
-		execution.Status = "failed"
-		execution.ErrorMessage = fmt.Sprintf("第三步失败: %v", err)
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
-		return
+	// 记录交易
+	e.recordExecution(execution)
+
+	log.Printf("✓ 交易完成: %s, 利润: %.2f (%+.2f%%)", execution.ID, execution.ActualProfit, execution.ActualProfitPercent)
+
+	// 清除执行记录
+	e.mu.Lock()
+	delete(e.executingTrades, execution.ID)
+	e.mu.Unlock()
+}
+
+// executeCrossExchange 执行跨交易所套利，两侧同时下单
+// 任一侧失败时另一侧的成交不回滚，形成的持仓偏差由持仓校正和再平衡处理
+func (e *TradeExecutor) executeCrossExchange(execution *TradeExecution, opp *ArbitrageOpportunity) {
+	execution.Status = "executing"
+	e.recordExecution(execution)
+
+	steps := opp.Details.Steps()
+	orders := make([]*ExecutedOrder, len(steps))
+	errs := make([]error, len(steps))
+
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func(i int, step *TradeStep, attempt int) {
+			defer wg.Done()
+			orders[i], errs[i] = e.executeCrossExchangeLeg(execution, step, i+1, attempt)
+		}(i, step, execution.nextAttempt(i+1))
 	}
-	execution.Orders = append(execution.Orders, order3)
+	wg.Wait()
 
-	// 等待订单成交
-	if !e.waitForOrder(opp.Path[2], order3.OrderID, 30*time.Second) {
-		execution.Status = "failed"
-		execution.ErrorMessage = "第三步订单超时"
-		e.recordExecution(execution)
-		e.mu.Lock()
-		delete(e.executingTrades, execution.ID)
-		e.mu.Unlock()
+	failures := make([]string, 0)
+	for i, order := range orders {
+		if order != nil {
+			execution.Orders = append(execution.Orders, order)
+		}
+		if errs[i] != nil {
+			failures = append(failures, fmt.Sprintf("%s 第%d步失败: %v", steps[i].Exchange, i+1, errs[i]))
+		}
+	}
+	if len(failures) > 0 {
+		e.failExecution(execution, strings.Join(failures, "; "))
 		return
 	}
 
-	// 计算实际结果
-	execution.FinalAmount = order3.CummulativeQty
+	// 买入侧花费报价资产，卖出侧获得报价资产，手续费折算为报价资产
+	buy, sell := orders[0], orders[1]
+	execution.InitialAmount = buy.CummulativeQty
+	for i, order := range orders {
+		execution.TotalFees += e.crossExchangeFee(execution, order, steps[i])
+	}
+	execution.FinalAmount = sell.CummulativeQty - execution.TotalFees
 	execution.ActualProfit = execution.FinalAmount - execution.InitialAmount
-	execution.ActualProfitPercent = (execution.ActualProfit / execution.InitialAmount) * 100
-	execution.TotalFees = order1.Fee + order2.Fee + order3.Fee
+	if execution.InitialAmount > 0 {
+		execution.ActualProfitPercent = (execution.ActualProfit / execution.InitialAmount) * 100
+	}
 	execution.EndTime = time.Now()
 	execution.ExecutionTime = execution.EndTime.Sub(execution.StartTime).Milliseconds()
 	execution.Status = "completed"
 	execution.UpdatedAt = time.Now()
 
-	// 记录交易
 	e.recordExecution(execution)
 
-	log.Printf("✓ 交易完成: %s, 利润: %.2f (%+.2f%%)", execution.ID, execution.ActualProfit, execution.ActualProfitPercent)
+	log.Printf("✓ 跨交易所交易完成: %s, 利润: %.2f (%+.2f%%)", execution.ID, execution.ActualProfit, execution.ActualProfitPercent)
 
-	// 清除执行记录
 	e.mu.Lock()
 	delete(e.executingTrades, execution.ID)
 	e.mu.Unlock()
 }
 
-// executeStep 执行交易步骤
-func (e *TradeExecutor) executeStep(execution *TradeExecution, step *TradeStep, stepNum int) (*ExecutedOrder, error) {
-	log.Printf("执行第%d步: %s %s %.8f @ %.8f", stepNum, step.Side, step.Symbol, step.Quantity, step.Price)
+// crossExchangeFee 获取跨交易所订单折算为报价资产（起始资产）的手续费
+// 交易所返回了成交明细时使用实际手续费，以基础资产支付的按成交均价折算，以其他资产（例如 BNB）支付的按行情折算；
+// 没有明细或无法折算时按预估费率计算，并记录到订单
+func (e *TradeExecutor) crossExchangeFee(execution *TradeExecution, order *ExecutedOrder, step *TradeStep) float64 {
+	if len(order.Fills) > 0 {
+		switch {
+		case order.FeeAsset == execution.StartAsset:
+			return order.Fee
+		case order.Symbol == order.FeeAsset+execution.StartAsset && order.AvgPrice > 0:
+			return order.Fee * order.AvgPrice
+		case e.marketManager != nil:
+			if value, ok := e.marketManager.ConvertAmount(order.Fee, order.FeeAsset, execution.StartAsset); ok {
+				return value
+			}
+		}
+	}
+
+	order.Fee, order.FeeAsset = order.CummulativeQty*step.FeePercentage, execution.StartAsset
+	return order.Fee
+}
+
+// executeCrossExchangeLeg 在步骤对应的交易所下单并等待成交，返回最终的订单状态
+func (e *TradeExecutor) executeCrossExchangeLeg(execution *TradeExecution, step *TradeStep, stepNum int, attempt int) (*ExecutedOrder, error) {
+	client, err := e.clientFor(step.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := e.executeStep(execution, step, stepNum, attempt)
+	if err != nil {
+		return order, err
+	}
+	e.recordOrder(execution, order)
+
+	filled := e.awaitFill(client, order, step)
+	e.recordOrder(execution, order)
+	if !filled {
+		return order, fmt.Errorf("订单未成交或超时")
+	}
+	return order, nil
+}
 
-	var order *Order
-	var err error
+// failExecution 将交易标记为失败并清除执行记录
+func (e *TradeExecutor) failExecution(execution *TradeExecution, message string) {
+	execution.Status = "failed"
+	execution.ErrorMessage = message
+	execution.UpdatedAt = time.Now()
+	e.recordExecution(execution)
 
-	if step.Side == "BUY" {
-		order, err = e.client.PlaceOrder(step.Symbol, "BUY", step.Quantity, step.Price)
-	} else {
-		order, err = e.client.PlaceOrder(step.Symbol, "SELL", step.Quantity, step.Price)
+	e.mu.Lock()
+	delete(e.executingTrades, execution.ID)
+	e.mu.Unlock()
+
+	log.Printf("✗ 交易失败: %s, 错误: %s", execution.ID, execution.ErrorMessage)
+}
+
+// executeStep 执行交易步骤，attempt 为该步骤的第几次下单
+// 下单结果未知时同时返回下单中的订单记录和错误，调用方按客户端订单ID校正
+func (e *TradeExecutor) executeStep(execution *TradeExecution, step *TradeStep, stepNum int, attempt int) (*ExecutedOrder, error) {
+	req := step.OrderRequest()
+	log.Printf("执行第%d步: %s %s %.8f @ %.8f (%s %s)", stepNum, step.Side, step.Symbol, step.Quantity, req.Price, req.Type, req.TimeInForce)
+
+	client, err := e.clientFor(step.Exchange)
+	if err != nil {
+		return nil, err
 	}
 
+	order, err := e.submitOrder(execution, client, step.Exchange, req, stepNum, attempt)
 	if err != nil {
-		return nil, fmt.Errorf("下单失败: %w", err)
+		// 交易规则已变化时重新加载，之后的机会按新规则计算
+		if exchange.ClassifyError(err) == exchange.ErrorResync && step.Exchange == "" && e.marketManager != nil {
+			go func() {
+				if err := e.marketManager.initSymbolInfo(); err != nil {
+					log.Printf("重新加载交易对信息失败: %v", err)
+				}
+			}()
+		}
 	}
+	return order, err
+}
 
-	executedOrder := &ExecutedOrder{
-		OrderID:       order.OrderID,
-		Symbol:        order.Symbol,
-		Side:          order.Side,
-		Type:          order.Type,
-		Price:         order.Price,
-		Quantity:      order.OrigQty,
-		ExecutedQty:   order.ExecutedQty,
-		CummulativeQty: order.CummulativeQuoteQty,
-		Status:        order.Status,
+// submitOrder 按交易ID、步骤和下单次数生成客户端订单ID后下单，返回订单记录
+// 下单前先保存下单中的订单记录，下单期间服务重启时可按客户端订单ID查询；
+// 下单被拒绝时将记录标记为 REJECTED，结果仍未知时同时返回下单中的订单记录和错误
+func (e *TradeExecutor) submitOrder(execution *TradeExecution, client exchange.Exchange, exchangeName string, req *exchange.OrderRequest, stepNum int, attempt int) (*ExecutedOrder, error) {
+	req.ClientOrderID = clientOrderID(execution.ID, stepNum, attempt)
+	pending := &ExecutedOrder{
+		Step:          stepNum,
+		Exchange:      exchangeName,
+		ClientOrderID: req.ClientOrderID,
+		Attempt:       attempt,
+		Symbol:        req.Symbol,
+		Side:          req.Side,
+		Type:          req.Type,
+		Price:         req.Price,
+		Quantity:      req.Quantity,
+		Status:        exchange.OrderStatusNew,
 		ExecutedAt:    time.Now(),
 	}
+	e.recordOrder(execution, pending)
 
+	order, err := e.placeOrder(client, req)
+	if err != nil {
+		if orderOutcomeUnknown(err) {
+			return pending, fmt.Errorf("下单结果未知: %w", err)
+		}
+		pending.Status = exchange.OrderStatusRejected
+		e.recordOrder(execution, pending)
+		return nil, fmt.Errorf("下单失败: %w", err)
+	}
+
+	executedOrder := newExecutedOrder(stepNum, exchangeName, order)
+	executedOrder.ClientOrderID = req.ClientOrderID
+	executedOrder.Attempt = attempt
 	return executedOrder, nil
 }
 
-// waitForOrder 等待订单成交
-func (e *TradeExecutor) waitForOrder(symbol string, orderID int64, timeout time.Duration) bool {
+// placeOrder 下单，发送结果未知（网络错误、超时、服务端错误）时先按客户端订单ID确认订单是否已被受理，
+// 已受理则返回该订单，确认不存在才用同一客户端订单ID重新下单，避免重复成交
+func (e *TradeExecutor) placeOrder(client exchange.Exchange, req *exchange.OrderRequest) (*exchange.Order, error) {
+	order, err := client.SubmitOrder(req)
+	for attempt := 1; err != nil && attempt < placeOrderAttempts && orderOutcomeUnknown(err); attempt++ {
+		log.Printf("下单 %s 结果未知: %v, %v 后确认订单状态", req.ClientOrderID, err, placeOrderRetryDelay)
+		time.Sleep(placeOrderRetryDelay)
+
+		existing, queryErr := queryClientOrder(client, req.Symbol, req.ClientOrderID)
+		if queryErr == nil {
+			return existing, nil
+		}
+		if !exchange.IsOrderNotFound(queryErr) {
+			return nil, fmt.Errorf("%w (确认订单状态失败: %v)", err, queryErr)
+		}
+
+		order, err = client.SubmitOrder(req)
+	}
+	return order, err
+}
+
+// queryClientOrder 按客户端订单ID查询订单
+func queryClientOrder(client exchange.Exchange, symbol, clientOrderID string) (*exchange.Order, error) {
+	querier, ok := client.(exchange.ClientOrderQuerier)
+	if !ok || clientOrderID == "" {
+		return nil, fmt.Errorf("%s 不支持按客户端订单ID查询订单", client.Name())
+	}
+	return querier.GetOrderByClientID(symbol, clientOrderID)
+}
+
+// orderOutcomeUnknown 检查下单错误是否说明请求可能已被交易所受理
+// 本地限流时请求没有发出，交易所明确拒绝的错误也不会产生订单
+func orderOutcomeUnknown(err error) bool {
+	return exchange.ClassifyError(err) == exchange.ErrorRetryable && !errors.Is(err, exchange.ErrRateLimited)
+}
+
+// newExecutedOrder 根据下单响应创建订单记录
+func newExecutedOrder(stepNum int, exchangeName string, order *exchange.Order) *ExecutedOrder {
+	executedOrder := &ExecutedOrder{
+		Step:       stepNum,
+		Exchange:   exchangeName,
+		OrderID:    order.OrderID,
+		Symbol:     order.Symbol,
+		Side:       order.Side,
+		Type:       order.Type,
+		Price:      order.Price,
+		Quantity:   order.OrigQty,
+		ExecutedAt: time.Now(),
+	}
+	executedOrder.update(order)
+
+	return executedOrder
+}
+
+// update 按交易所返回的订单状态更新成交数量、成交均价和成交明细
+// 有成交明细时按明细记录实际手续费，没有明细时保留原有的手续费（例如重启前记录的）
+func (o *ExecutedOrder) update(order *exchange.Order) {
+	o.ExecutedQty = order.ExecutedQty
+	o.CummulativeQty = order.ExecutedQuoteQty
+	o.Status = order.Status
+	if o.ExecutedQty > 0 {
+		o.AvgPrice = o.CummulativeQty / o.ExecutedQty
+	}
+
+	o.addFills(order.Fills)
+	if len(o.Fills) > 0 {
+		o.Fee, o.FeeAsset, _ = o.fillFees()
+	}
+}
+
+// addFills 合并成交明细，下单响应和账户推送可能包含同一笔成交，按成交ID去重
+func (o *ExecutedOrder) addFills(fills []*exchange.Execution) {
+	for _, fill := range fills {
+		duplicate := false
+		for _, existing := range o.Fills {
+			if existing.TradeID == fill.TradeID {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			o.Fills = append(o.Fills, fill)
+		}
+	}
+}
+
+// fillFees 汇总成交明细的手续费，返回手续费、支付手续费的资产和明细覆盖的成交数量
+func (o *ExecutedOrder) fillFees() (fee float64, asset string, quantity float64) {
+	for _, fill := range o.Fills {
+		fee += fill.Commission
+		asset = fill.CommissionAsset
+		quantity += fill.Quantity
+	}
+	return fee, asset, quantity
+}
+
+// awaitFill 等待订单结束并更新成交数量和手续费，超时后撤销未成交的剩余部分
+// 没有任何成交或无法确认最终状态时返回false
+func (e *TradeExecutor) awaitFill(client exchange.Exchange, order *ExecutedOrder, step *TradeStep) bool {
+	if !isOrderFinal(order.Status) {
+		latest := e.waitForOrder(client, order.Symbol, order.OrderID, e.orderTimeout)
+		if latest == nil {
+			return false
+		}
+		order.update(latest)
+	}
+
+	if accountManager := e.AccountManager(); accountManager != nil && client == e.client {
+		order.addFills(accountManager.GetFills(order.Symbol, order.OrderID))
+	}
+	e.settleFee(order, step)
+	return order.ExecutedQty > 0
+}
+
+// settleFee 按成交明细计算订单的实际手续费
+// 没有明细的成交数量按已有明细的实际费率估算；完全没有明细时按步骤的计划费率估算，
+// 计划以抵扣资产支付时折算为抵扣资产，否则从成交所得中扣除。step 为nil时只使用明细
+func (e *TradeExecutor) settleFee(order *ExecutedOrder, step *TradeStep) {
+	fee, feeAsset, filledQty := order.fillFees()
+	remaining := order.ExecutedQty - filledQty
+
+	switch {
+	case remaining <= order.ExecutedQty*stepEpsilon:
+	case filledQty > 0:
+		fee += fee / filledQty * remaining
+	case step == nil || step.FeePercentage == 0:
+		return
+	default:
+		asset, amount, err := e.proceeds(order, false)
+		if err != nil {
+			log.Printf("估算第%d步手续费失败: %v", order.Step, err)
+			return
+		}
+		fee, feeAsset = amount*step.FeePercentage, asset
+		if step.FeeAsset != "" {
+			if value, ok := e.marketManager.ConvertAmount(fee, asset, step.FeeAsset); ok {
+				fee, feeAsset = value, step.FeeAsset
+			}
+		}
+	}
+	order.Fee, order.FeeAsset = fee, feeAsset
+}
+
+// proceeds 获取订单成交所得的资产和数量，net 为true时扣除从成交所得中支付的手续费
+func (e *TradeExecutor) proceeds(order *ExecutedOrder, net bool) (string, float64, error) {
+	if e.marketManager == nil {
+		return "", 0, fmt.Errorf("未设置行情管理器")
+	}
+	info := e.marketManager.GetSymbolInfo(order.Symbol)
+	if info == nil {
+		return "", 0, fmt.Errorf("交易对 %s 不存在", order.Symbol)
+	}
+
+	asset, amount := info.QuoteAsset, order.CummulativeQty
+	if order.Side == "BUY" {
+		asset, amount = info.BaseAsset, order.ExecutedQty
+	}
+	if net && order.FeeAsset == asset {
+		amount -= order.Fee
+	}
+	return asset, amount, nil
+}
+
+// chainStep 按上一步扣除手续费后的实际成交所得重新计算该步骤的下单数量，并按交易规则舍入
+// 上一步部分成交时只用成交的部分继续；第一步按计划数量下单
+func (e *TradeExecutor) chainStep(execution *TradeExecution, step *TradeStep) (*TradeStep, error) {
+	if len(execution.Orders) == 0 {
+		return step, nil
+	}
+
+	asset, amount, err := e.proceeds(execution.Orders[len(execution.Orders)-1], true)
+	if err != nil {
+		return nil, err
+	}
+	info := e.marketManager.GetSymbolInfo(step.Symbol)
+	rules := e.marketManager.GetTradingRules(step.Symbol)
+	if info == nil || rules == nil {
+		return nil, fmt.Errorf("交易对 %s 不存在", step.Symbol)
+	}
+
+	chained := *step
+	switch {
+	case step.Side == "SELL" && asset == info.BaseAsset:
+		chained.Quantity = roundToStep(amount, rules.StepSize)
+	case step.Side == "BUY" && asset == info.QuoteAsset && step.OrderType == exchange.OrderTypeMarket:
+		// 市价买单按报价资产金额下单，不会因价格变化花费超过持有的数量
+		chained.Quantity, chained.QuoteQuantity = 0, roundToStep(amount, 0.00000001)
+	case step.Side == "BUY" && asset == info.QuoteAsset && step.limitPrice() > 0:
+		// 限价买单按限价冻结报价资产，数量按限价计算才不会超过持有的数量
+		chained.Quantity = roundToStep(amount/step.limitPrice(), rules.StepSize)
+	default:
+		return nil, fmt.Errorf("上一步成交所得 %s 不能用于 %s %s", asset, step.Side, step.Symbol)
+	}
+
+	if chained.QuoteQuantity == 0 && (chained.Quantity <= 0 || chained.Quantity < rules.MinQty) {
+		return nil, fmt.Errorf("上一步成交所得 %.8f %s 低于 %s 最小下单量", amount, asset, step.Symbol)
+	}
+	notional := chained.QuoteQuantity + chained.Quantity*step.limitPrice()
+	if (chained.QuoteQuantity > 0 || step.limitPrice() > 0) && notional < rules.MinNotional {
+		return nil, fmt.Errorf("上一步成交所得 %.8f %s 低于 %s 最小成交额", amount, asset, step.Symbol)
+	}
+	return &chained, nil
+}
+
+// feeCosts 将订单手续费折算为 asset，返回全部手续费和其中以成交所得以外的资产（例如 BNB）支付的部分
+// 无法折算时按手续费原数量计入
+func (e *TradeExecutor) feeCosts(orders []*ExecutedOrder, asset string) (total float64, commission float64) {
+	for _, order := range orders {
+		if order.Fee == 0 {
+			continue
+		}
+
+		fee := order.Fee
+		if e.marketManager == nil || order.FeeAsset == "" {
+			total += fee
+			continue
+		}
+		if value, ok := e.marketManager.ConvertAmount(fee, order.FeeAsset, asset); ok {
+			fee = value
+		}
+		total += fee
+
+		info := e.marketManager.GetSymbolInfo(order.Symbol)
+		if info == nil {
+			continue
+		}
+		received := info.QuoteAsset
+		if order.Side == "BUY" {
+			received = info.BaseAsset
+		}
+		if order.FeeAsset != received {
+			commission += fee
+		}
+	}
+	return total, commission
+}
+
+// waitForOrder 等待订单结束，超时后撤销未成交的剩余部分，返回有成交的最终订单状态
+// 没有任何成交、撤单后仍无法确认最终状态时返回nil。
+// 默认交易所设置了账户管理器时由推送获取订单状态，其他交易所轮询查询
+func (e *TradeExecutor) waitForOrder(client exchange.Exchange, symbol string, orderID string, timeout time.Duration) *exchange.Order {
+	order := e.pollOrder(client, symbol, orderID, timeout)
+	if order == nil || !isOrderFinal(order.Status) {
+		latest, err := cancelRemaining(client, symbol, orderID)
+		if err != nil {
+			log.Printf("撤销订单 %s 剩余部分失败: %v", orderID, err)
+			return nil
+		}
+		order = latest
+	}
+
+	if !isOrderFinal(order.Status) || order.ExecutedQty <= 0 {
+		return nil
+	}
+	return order
+}
+
+// pollOrder 等待订单结束，返回最后获取到的订单状态（超时时可能未结束或为nil）
+func (e *TradeExecutor) pollOrder(client exchange.Exchange, symbol string, orderID string, timeout time.Duration) *exchange.Order {
+	if accountManager := e.AccountManager(); accountManager != nil && client == e.client {
+		order, err := accountManager.WaitForOrder(symbol, orderID, timeout, func(order *exchange.Order) bool {
+			return isOrderFinal(order.Status)
+		})
+		if err != nil {
+			log.Printf("等待订单失败: %v", err)
+		}
+		return order
+	}
+
 	startTime := time.Now()
 
+	var last *exchange.Order
 	for {
 		if time.Since(startTime) > timeout {
-			return false
+			return last
 		}
 
-		order, err := e.client.GetOrder(symbol, orderID)
+		order, err := client.GetOrder(symbol, orderID)
 		if err != nil {
 			log.Printf("查询订单失败: %v", err)
+			// 交易所明确返回的订单不存在、密钥无效等错误重试无效
+			var apiErr *exchange.APIError
+			if errors.As(err, &apiErr) && apiErr.Class() == exchange.ErrorFatal {
+				return last
+			}
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		if order.Status == "FILLED" || order.Status == "PARTIALLY_FILLED" {
-			return true
-		}
-
-		if order.Status == "CANCELED" || order.Status == "REJECTED" {
-			return false
+		last = order
+		if isOrderFinal(order.Status) {
+			return order
 		}
 
 		time.Sleep(500 * time.Millisecond)
 	}
 }
 
-// recordExecution 记录交易执行
+// cancelRemaining 撤销订单未成交的剩余部分并重新查询最终状态
+// 撤单失败可能是订单刚好结束，以重新查询的状态为准
+func cancelRemaining(client exchange.Exchange, symbol string, orderID string) (*exchange.Order, error) {
+	if _, err := client.CancelOrder(symbol, orderID); err != nil {
+		log.Printf("撤销订单 %s 失败: %v", orderID, err)
+	}
+	return client.GetOrder(symbol, orderID)
+}
+
+// recordExecution 将交易执行记录及其订单保存到数据库，每次状态变化时调用，重启后据此恢复未结束的交易
+// 保存失败只记录日志，不中断交易
 func (e *TradeExecutor) recordExecution(execution *TradeExecution) {
-	// 保存到数据库
-	// TODO: 实现数据库保存逻辑
-	
-	log.Printf("记录交易: %s, 状态: %s, 利润: %.2f", execution.ID, execution.Status, execution.ActualProfit)
+	if isExecutionFinal(execution.Status) {
+		log.Printf("记录交易: %s, 状态: %s, 利润: %.2f", execution.ID, execution.Status, execution.ActualProfit)
+	}
+	if e.db == nil {
+		return
+	}
+
+	if err := e.db.SaveTradeExecution(execution); err != nil {
+		log.Printf("✗ 保存交易 %s 失败: %v", execution.ID, err)
+	}
+}
+
+// recordOrder 保存单个订单的最新状态，跨交易所套利两侧并发下单时使用，避免同时写入整个执行记录
+func (e *TradeExecutor) recordOrder(execution *TradeExecution, order *ExecutedOrder) {
+	if e.db == nil {
+		return
+	}
+
+	if err := e.db.SaveExecutedOrder(execution.ID, order); err != nil {
+		log.Printf("✗ 保存交易 %s 第%d步订单失败: %v", execution.ID, order.Step, err)
+	}
+}
+
+// isExecutionFinal 检查交易执行状态是否已结束
+func isExecutionFinal(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
 }
 
 // GetExecution 获取交易执行记录
@@ -341,7 +882,12 @@ func (e *TradeExecutor) CancelExecution(executionID string) error {
 	// 取消所有未成交的订单
 	for _, order := range execution.Orders {
 		if order.Status != "FILLED" {
-			_, err := e.client.CancelOrder(order.Symbol, order.OrderID)
+			client, err := e.clientFor(order.Exchange)
+			if err != nil {
+				log.Printf("取消订单失败: %v", err)
+				continue
+			}
+			_, err = client.CancelOrder(order.Symbol, order.OrderID)
 			if err != nil {
 				log.Printf("取消订单失败: %v", err)
 			}
@@ -350,6 +896,7 @@ func (e *TradeExecutor) CancelExecution(executionID string) error {
 
 	execution.Status = "cancelled"
 	execution.UpdatedAt = time.Now()
+	e.recordExecution(execution)
 
 	return nil
 }
@@ -360,3 +907,39 @@ func (e *TradeExecutor) CancelExecution(executionID string) error {
 func generateTradeID() string {
 	return fmt.Sprintf("trade_%d", time.Now().UnixNano())
 }
+
+// clientOrderID 由交易ID、步骤和下单次数生成客户端订单ID，同一次下单的重试始终使用同一个ID
+// 只包含字母和数字且不超过 maxClientOrderIDLength 个字符，满足各交易所的格式要求，过长时保留交易ID的末尾
+func clientOrderID(executionID string, stepNum int, attempt int) string {
+	base := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return -1
+	}, executionID)
+
+	suffix := fmt.Sprintf("s%da%d", stepNum, attempt)
+	if extra := len(base) + len(suffix) - maxClientOrderIDLength; extra > 0 {
+		base = base[extra:]
+	}
+	return base + suffix
+}
+
+// nextAttempt 获取步骤下一次下单的次数
+func (t *TradeExecution) nextAttempt(stepNum int) int {
+	if t.attempts == nil {
+		t.attempts = make(map[int]int)
+	}
+	t.attempts[stepNum]++
+	return t.attempts[stepNum]
+}
+
+// noteAttempt 记录已有订单的下单次数，之后同一步骤的下单使用新的客户端订单ID
+func (t *TradeExecution) noteAttempt(order *ExecutedOrder) {
+	if t.attempts == nil {
+		t.attempts = make(map[int]int)
+	}
+	if order.Attempt > t.attempts[order.Step] {
+		t.attempts[order.Step] = order.Attempt
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// recoveryResumeWindow 重启后继续执行剩余步骤的最长间隔，超过后计划的价格已不可信，按回滚策略处理持有的中间资产
+const recoveryResumeWindow = 30 * time.Second
+
+// RecoverExecutions 重新加载重启前尚未结束的交易，按交易所的订单状态校正后继续执行或回滚
+// 恢复的交易计入并发交易数，在后台处理
+func (e *TradeExecutor) RecoverExecutions() error {
+	if e.db == nil {
+		return nil
+	}
+
+	executions, err := e.db.GetUnfinishedExecutions()
+	if err != nil {
+		return fmt.Errorf("加载未结束的交易失败: %w", err)
+	}
+
+	for _, execution := range executions {
+		log.Printf("恢复交易: %s, 状态: %s, 已下单 %d/%d 步", execution.ID, execution.Status, len(execution.Orders), len(execution.Steps))
+
+		e.mu.Lock()
+		e.executingTrades[execution.ID] = execution
+		e.mu.Unlock()
+
+		go e.recoverExecution(execution)
+	}
+	return nil
+}
+
+// recoverExecution 恢复一笔重启前未结束的交易
+// 模拟交易没有实际持仓，直接标记为失败；跨交易所套利只校正订单状态，持仓偏差由再平衡处理。
+// 其他交易全部步骤已成交时按完成处理；未超过 recoveryResumeWindow 时按最后一步的实际成交继续执行剩余步骤；
+// 否则（包括重启前正在回滚）按交易的回滚策略处理持有的中间资产
+func (e *TradeExecutor) recoverExecution(execution *TradeExecution) {
+	if execution.IsSimulation {
+		e.failExecution(execution, "服务重启，模拟交易中断")
+		return
+	}
+
+	if err := e.reconcileOrders(execution); err != nil {
+		e.failExecution(execution, fmt.Sprintf("服务重启后校正订单失败，需人工处理: %v", err))
+		return
+	}
+
+	if execution.Type == StrategyCrossExchange {
+		e.failExecution(execution, "服务重启，跨交易所交易中断")
+		return
+	}
+
+	if len(execution.Orders) == 0 {
+		e.failExecution(execution, "服务重启，交易未成交")
+		return
+	}
+
+	// 校正后的订单均已结束且有成交，最后一个不是回滚订单时可以继续
+	resumable := execution.Orders[len(execution.Orders)-1].Step <= len(execution.Steps)
+	switch {
+	case resumable && len(execution.Orders) == len(execution.Steps):
+		e.completeReal(execution)
+	case resumable && time.Since(execution.StartTime) < recoveryResumeWindow:
+		log.Printf("继续执行交易 %s, 从第%d步开始", execution.ID, len(execution.Orders)+1)
+		e.executeReal(execution)
+	default:
+		e.abortExecution(execution, "服务重启")
+	}
+}
+
+// reconcileOrders 向交易所查询未结束订单的最新状态，仍在挂单的订单撤销后按实际成交记录
+// 没有任何成交的订单从执行记录中移除，之后的步骤从该订单的步骤重新开始
+func (e *TradeExecutor) reconcileOrders(execution *TradeExecution) error {
+	orders := make([]*ExecutedOrder, 0, len(execution.Orders))
+	for _, order := range execution.Orders {
+		execution.noteAttempt(order)
+		if !isOrderFinal(order.Status) {
+			latest, err := e.settleOrder(order)
+			if err != nil {
+				return err
+			}
+			order.update(latest)
+			e.recordOrder(execution, order)
+		}
+		if order.ExecutedQty > 0 {
+			orders = append(orders, order)
+		}
+	}
+
+	execution.Orders = orders
+	return nil
+}
+
+// settleOrder 查询订单的最新状态，仍未结束时撤销后重新查询
+func (e *TradeExecutor) settleOrder(order *ExecutedOrder) (*exchange.Order, error) {
+	client, err := e.clientFor(order.Exchange)
+	if err != nil {
+		return nil, err
+	}
+
+	// 没有交易所订单ID的是下单结果未知或重启前的订单，按客户端订单ID查询
+	var latest *exchange.Order
+	if order.OrderID != "" {
+		latest, err = client.GetOrder(order.Symbol, order.OrderID)
+	} else {
+		latest, err = queryClientOrder(client, order.Symbol, order.ClientOrderID)
+		if exchange.IsOrderNotFound(err) {
+			// 下单请求没有被受理
+			return &exchange.Order{Symbol: order.Symbol, Status: exchange.OrderStatusRejected}, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询第%d步订单 %s 失败: %w", order.Step, order.ClientOrderID, err)
+	}
+	order.OrderID = latest.OrderID
+	if isOrderFinal(latest.Status) {
+		return latest, nil
+	}
+
+	latest, err = cancelRemaining(client, order.Symbol, order.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("查询第%d步订单 %s 失败: %w", order.Step, order.ClientOrderID, err)
+	}
+	return latest, nil
+}
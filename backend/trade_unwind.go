@@ -0,0 +1,378 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"inarbit/exchange"
+)
+
+// 回滚方式：某一步失败后如何处理之前步骤成交留下的中间资产
+const (
+	UnwindMarket = "market" // 以市价单换回起始资产
+	UnwindRetry  = "retry"  // 以限价上限重试剩余步骤，仍未完成时以市价单换回起始资产
+	UnwindHold   = "hold"   // 保留中间资产并告警，由人工处理
+)
+
+// defaultUnwindPriceCapPercent UnwindRetry 未配置限价上限时，成交价相对计划价格允许变差的百分比
+const defaultUnwindPriceCapPercent = 0.5
+
+// unwindRetryAttempts UnwindRetry 每一步的重试次数
+const unwindRetryAttempts = 3
+
+// unwindRetryDelay UnwindRetry 两次重试之间的间隔
+const unwindRetryDelay = 500 * time.Millisecond
+
+// UnwindPolicy 回滚策略
+type UnwindPolicy struct {
+	Mode            string  // 见 Unwind 常量，为空时按 UnwindMarket
+	PriceCapPercent float64 // UnwindRetry 成交价相对计划价格允许变差的百分比，为0时按 defaultUnwindPriceCapPercent
+}
+
+// NewUnwindPolicy 按策略配置创建回滚策略，strategy 为nil时以市价单换回起始资产
+func NewUnwindPolicy(strategy *Strategy) UnwindPolicy {
+	if strategy == nil {
+		return UnwindPolicy{Mode: UnwindMarket}
+	}
+	return UnwindPolicy{Mode: strategy.UnwindPolicy, PriceCapPercent: strategy.UnwindCapPercent}
+}
+
+// priceCap 获取 UnwindRetry 的限价上限百分比
+func (p UnwindPolicy) priceCap() float64 {
+	if p.PriceCapPercent > 0 {
+		return p.PriceCapPercent
+	}
+	return defaultUnwindPriceCapPercent
+}
+
+// abortExecution 某一步失败后按交易的回滚策略处理之前步骤成交留下的中间资产，没有任何成交时直接标记为失败
+func (e *TradeExecutor) abortExecution(execution *TradeExecution, reason string) {
+	if err := e.reconcileOrders(execution); err != nil {
+		e.alertAndFail(execution, fmt.Sprintf("%s，校正订单失败，需人工处理: %v", reason, err))
+		return
+	}
+	if len(execution.Orders) == 0 {
+		e.failExecution(execution, reason)
+		return
+	}
+
+	switch execution.Unwind.Mode {
+	case UnwindHold:
+		e.holdExecution(execution, reason)
+	case UnwindRetry:
+		if e.retryRemainingSteps(execution) {
+			e.completeReal(execution)
+			return
+		}
+		e.unwindExecution(execution, reason)
+	default:
+		e.unwindExecution(execution, reason)
+	}
+}
+
+// retryRemainingSteps 以限价上限逐步重试剩余步骤，全部完成时返回true
+// 已有回滚订单时不再重试，直接返回false
+func (e *TradeExecutor) retryRemainingSteps(execution *TradeExecution) bool {
+	if len(execution.Orders) > len(execution.Steps) {
+		return false
+	}
+
+	for stepNum := len(execution.Orders) + 1; stepNum <= len(execution.Steps); stepNum++ {
+		if !e.retryStep(execution, stepNum) {
+			return false
+		}
+	}
+	return true
+}
+
+// retryStep 重试一个步骤，有成交时返回true，部分成交时撤销剩余部分后按实际成交继续；没有成交时最多重试 unwindRetryAttempts 次
+func (e *TradeExecutor) retryStep(execution *TradeExecution, stepNum int) bool {
+	for attempt := 1; attempt <= unwindRetryAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(unwindRetryDelay)
+		}
+
+		step, err := e.cappedStep(execution, execution.Steps[stepNum-1])
+		if err != nil {
+			log.Printf("重试交易 %s 第%d步失败: %v", execution.ID, stepNum, err)
+			return false
+		}
+
+		log.Printf("重试交易 %s 第%d步 (第%d次), 限价 %.8f", execution.ID, stepNum, attempt, step.Price)
+		order, err := e.executeStep(execution, step, stepNum, execution.nextAttempt(stepNum))
+		if order == nil {
+			log.Printf("重试交易 %s 第%d步失败: %v", execution.ID, stepNum, err)
+			continue
+		}
+		execution.Orders = append(execution.Orders, order)
+		e.recordExecution(execution)
+
+		if err == nil && e.awaitFill(e.client, order, step) {
+			e.recordOrder(execution, order)
+			return true
+		}
+
+		// 按交易所状态校正成交数量，下单结果未知的订单按客户端订单ID确认，没有成交的订单被移除
+		if err := e.reconcileOrders(execution); err != nil {
+			log.Printf("重试交易 %s 第%d步失败: %v", execution.ID, stepNum, err)
+			return false
+		}
+		if len(execution.Orders) == stepNum {
+			return true
+		}
+	}
+	return false
+}
+
+// cappedStep 构建重试步骤：IOC限价单，价格按计划限价放宽 PriceCapPercent，数量按持有的付出资产计算且不超过计划数量
+func (e *TradeExecutor) cappedStep(execution *TradeExecution, step *TradeStep) (*TradeStep, error) {
+	changes, err := e.assetChanges(execution.Orders)
+	if err != nil {
+		return nil, err
+	}
+	info := e.marketManager.GetSymbolInfo(step.Symbol)
+	rules := e.marketManager.GetTradingRules(step.Symbol)
+	if info == nil || rules == nil {
+		return nil, fmt.Errorf("交易对 %s 不存在", step.Symbol)
+	}
+
+	capPercent := execution.Unwind.priceCap()
+	retry := *step
+	retry.OrderType = exchange.OrderTypeLimit
+	retry.TimeInForce = exchange.TimeInForceIOC
+
+	var quantity float64
+	if step.Side == "BUY" {
+		retry.Price = roundToStep(step.limitPrice()*(1+capPercent/100), rules.TickSize)
+		quantity = changes[info.QuoteAsset] / retry.Price
+	} else {
+		retry.Price = ceilToStep(step.limitPrice()*(1-capPercent/100), rules.TickSize)
+		quantity = changes[info.BaseAsset]
+	}
+	retry.LimitPrice = 0
+
+	retry.Quantity = roundToStep(math.Min(quantity, step.Quantity), rules.StepSize)
+	if retry.Quantity <= 0 || retry.Quantity < rules.MinQty {
+		return nil, fmt.Errorf("持有的资产不足以下单 %s", step.Symbol)
+	}
+	return &retry, nil
+}
+
+// holdExecution 保留持有的中间资产并告警，交易标记为失败，由人工处理
+func (e *TradeExecutor) holdExecution(execution *TradeExecution, reason string) {
+	held := make([]string, 0)
+	if changes, err := e.assetChanges(execution.Orders); err == nil {
+		for _, asset := range heldAssets(changes, execution.StartAsset) {
+			held = append(held, fmt.Sprintf("%.8f %s", changes[asset], asset))
+		}
+	}
+	e.alertAndFail(execution, fmt.Sprintf("%s，保留持有的 %s，需人工处理", reason, strings.Join(held, ", ")))
+}
+
+// alertAndFail 将交易标记为失败，并写入系统日志提醒人工处理
+func (e *TradeExecutor) alertAndFail(execution *TradeExecution, message string) {
+	e.failExecution(execution, message)
+	if e.db == nil {
+		return
+	}
+
+	botID := execution.BotID
+	if err := e.db.LogSystemEvent(&botID, "ERROR", fmt.Sprintf("交易 %s: %s", execution.ID, message), nil); err != nil {
+		log.Printf("写入系统日志失败: %v", err)
+	}
+}
+
+// unwindExecution 将交易持有的中间资产以市价单换回起始资产，按换回后的金额记录实际盈亏和亏损并将交易标记为失败
+// 低于最小下单量的零头无法换回，保留在账户中
+func (e *TradeExecutor) unwindExecution(execution *TradeExecution, reason string) {
+	changes, err := e.assetChanges(execution.Orders)
+	if err != nil {
+		e.alertAndFail(execution, fmt.Sprintf("%s，计算持仓失败，需人工处理: %v", reason, err))
+		return
+	}
+
+	failures := make([]string, 0)
+	for _, asset := range heldAssets(changes, execution.StartAsset) {
+		order, err := e.unwindAsset(execution, asset, changes[asset], execution.unwindStep())
+		if order != nil {
+			execution.Orders = append(execution.Orders, order)
+			e.recordExecution(execution)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", asset, err))
+		}
+	}
+
+	// 起始资产的净变化即为实际盈亏，以抵扣资产支付的手续费折算为起始资产后计入成本
+	changes, err = e.assetChanges(execution.Orders)
+	if err != nil {
+		e.alertAndFail(execution, fmt.Sprintf("%s，计算回滚结果失败，需人工处理: %v", reason, err))
+		return
+	}
+	totalFees, commission := e.feeCosts(execution.Orders, execution.StartAsset)
+	execution.FinalAmount = execution.InitialAmount + changes[execution.StartAsset]
+	execution.ActualProfit = execution.FinalAmount - commission - execution.InitialAmount
+	if execution.InitialAmount > 0 {
+		execution.ActualProfitPercent = (execution.ActualProfit / execution.InitialAmount) * 100
+	}
+	execution.RealizedLoss = math.Max(0, -execution.ActualProfit)
+	execution.TotalFees = totalFees
+	execution.EndTime = time.Now()
+	execution.ExecutionTime = execution.EndTime.Sub(execution.StartTime).Milliseconds()
+
+	if len(failures) > 0 {
+		e.alertAndFail(execution, fmt.Sprintf("%s，部分资产未能换回 %s，需人工处理: %s", reason, execution.StartAsset, strings.Join(failures, "; ")))
+		return
+	}
+	e.failExecution(execution, fmt.Sprintf("%s，已换回 %s，实际亏损 %.8f", reason, execution.StartAsset, execution.RealizedLoss))
+}
+
+// settleLeftovers 处理全部步骤完成后部分成交或舍入留下的中间资产
+// UnwindHold 保留，其他方式以市价单换回起始资产；仍持有的资产（包括低于最小下单量的零头和换回失败的）
+// 按当前中间价折算为起始资产，返回这些资产的说明和折算后的总价值
+func (e *TradeExecutor) settleLeftovers(execution *TradeExecution) ([]string, float64) {
+	changes, err := e.assetChanges(execution.Orders)
+	if err != nil {
+		return nil, 0
+	}
+
+	if execution.Unwind.Mode != UnwindHold {
+		for _, asset := range heldAssets(changes, execution.StartAsset) {
+			order, err := e.unwindAsset(execution, asset, changes[asset], execution.unwindStep())
+			if order != nil {
+				execution.Orders = append(execution.Orders, order)
+				e.recordExecution(execution)
+			}
+			if err != nil {
+				log.Printf("交易 %s 换回剩余的 %s 失败: %v", execution.ID, asset, err)
+			}
+		}
+		if changes, err = e.assetChanges(execution.Orders); err != nil {
+			return nil, 0
+		}
+	}
+
+	held := make([]string, 0)
+	var value float64
+	for _, asset := range heldAssets(changes, execution.StartAsset) {
+		mark, ok := e.marketManager.ConvertAmount(changes[asset], asset, execution.StartAsset)
+		if !ok {
+			held = append(held, fmt.Sprintf("%.8f %s (无法估值)", changes[asset], asset))
+			continue
+		}
+		value += mark
+		held = append(held, fmt.Sprintf("%.8f %s (约 %.8f %s)", changes[asset], asset, mark, execution.StartAsset))
+	}
+	return held, value
+}
+
+// logHeldAssets 将交易完成后仍持有中间资产写入系统日志提醒人工处理
+func (e *TradeExecutor) logHeldAssets(execution *TradeExecution) {
+	log.Printf("交易 %s: %s", execution.ID, execution.ErrorMessage)
+	if e.db == nil {
+		return
+	}
+
+	botID := execution.BotID
+	if err := e.db.LogSystemEvent(&botID, "WARN", fmt.Sprintf("交易 %s: %s", execution.ID, execution.ErrorMessage), nil); err != nil {
+		log.Printf("写入系统日志失败: %v", err)
+	}
+}
+
+// unwindStep 获取下一个回滚订单的步骤，排在计划步骤和之前的回滚订单之后
+func (t *TradeExecution) unwindStep() int {
+	stepNum := len(t.Steps) + 1
+	if n := len(t.Orders); n > 0 && t.Orders[n-1].Step >= stepNum {
+		stepNum = t.Orders[n-1].Step + 1
+	}
+	return stepNum
+}
+
+// unwindAsset 以市价单将 amount 数量的 asset 换回起始资产，数量不超过账户可用余额
+// 低于最小下单量时返回nil；下单后未能成交时同时返回订单和错误
+func (e *TradeExecutor) unwindAsset(execution *TradeExecution, asset string, amount float64, stepNum int) (*ExecutedOrder, error) {
+	// 重启前的订单可能没有记录手续费，按账户实际可用余额封顶
+	balance, err := e.client.GetBalance(asset)
+	if err != nil {
+		return nil, fmt.Errorf("查询余额失败: %w", err)
+	}
+	if balance.Free < amount {
+		amount = balance.Free
+	}
+
+	req := &exchange.OrderRequest{Type: exchange.OrderTypeMarket}
+	if info := e.marketManager.GetSymbolInfo(asset + execution.StartAsset); info != nil {
+		quantity, err := e.marketManager.RoundQuantity(info.Symbol, amount)
+		if err != nil {
+			return nil, err
+		}
+		price := e.marketManager.GetBidPrice(info.Symbol)
+		if quantity <= 0 || quantity < info.MinQty || (price > 0 && quantity*price < info.MinNotional) {
+			log.Printf("%s %.8f 低于 %s 最小下单量，保留在账户中", asset, amount, info.Symbol)
+			return nil, nil
+		}
+		req.Symbol, req.Side, req.Quantity = info.Symbol, "SELL", quantity
+	} else if info := e.marketManager.GetSymbolInfo(execution.StartAsset + asset); info != nil {
+		quoteQuantity := roundToStep(amount, 0.00000001)
+		if quoteQuantity <= 0 || quoteQuantity < info.MinNotional {
+			log.Printf("%s %.8f 低于 %s 最小成交额，保留在账户中", asset, amount, info.Symbol)
+			return nil, nil
+		}
+		req.Symbol, req.Side, req.QuoteQuantity = info.Symbol, "BUY", quoteQuantity
+	} else {
+		return nil, fmt.Errorf("没有 %s 与 %s 的交易对", asset, execution.StartAsset)
+	}
+
+	log.Printf("回滚交易 %s: %s %s (%.8f %s)", execution.ID, req.Side, req.Symbol, amount, asset)
+	executedOrder, err := e.submitOrder(execution, e.client, "", req, stepNum, execution.nextAttempt(stepNum))
+	if err != nil {
+		return executedOrder, err
+	}
+	if !e.awaitFill(e.client, executedOrder, nil) {
+		return executedOrder, fmt.Errorf("订单未成交或超时")
+	}
+	return executedOrder, nil
+}
+
+// assetChanges 根据订单的成交数量计算各资产的净变化，已知的手续费从支付手续费的资产中扣除
+func (e *TradeExecutor) assetChanges(orders []*ExecutedOrder) (map[string]float64, error) {
+	if e.marketManager == nil {
+		return nil, fmt.Errorf("未设置行情管理器")
+	}
+
+	changes := make(map[string]float64)
+	for _, order := range orders {
+		info := e.marketManager.GetSymbolInfo(order.Symbol)
+		if info == nil {
+			return nil, fmt.Errorf("交易对 %s 不存在", order.Symbol)
+		}
+
+		if order.Side == "BUY" {
+			changes[info.BaseAsset] += order.ExecutedQty
+			changes[info.QuoteAsset] -= order.CummulativeQty
+		} else {
+			changes[info.BaseAsset] -= order.ExecutedQty
+			changes[info.QuoteAsset] += order.CummulativeQty
+		}
+		if order.FeeAsset != "" {
+			changes[order.FeeAsset] -= order.Fee
+		}
+	}
+	return changes, nil
+}
+
+// heldAssets 获取净变化为正的非起始资产，按名称排序
+func heldAssets(changes map[string]float64, startAsset string) []string {
+	assets := make([]string, 0, len(changes))
+	for asset, amount := range changes {
+		if asset != startAsset && amount > 0 {
+			assets = append(assets, asset)
+		}
+	}
+	sort.Strings(assets)
+	return assets
+}
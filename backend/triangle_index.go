@@ -0,0 +1,210 @@
+package arbitrage
+
+import (
+	"strings"
+	"sync"
+
+	"inarbit/exchange"
+)
+
+// TriangleLeg 三角路径中的一步
+type TriangleLeg struct {
+	Symbol string
+	Side   string // BUY: 用报价资产买入基础资产; SELL: 卖出基础资产得到报价资产
+	From   string // 付出的资产
+	To     string // 得到的资产
+}
+
+// Triangle 三角闭环
+type Triangle struct {
+	StartAsset string
+	Legs       [3]TriangleLeg
+}
+
+// Path 获取闭环经过的交易对
+func (t *Triangle) Path() []string {
+	return []string{t.Legs[0].Symbol, t.Legs[1].Symbol, t.Legs[2].Symbol}
+}
+
+// pairAssets 交易对的基础资产和报价资产
+type pairAssets struct {
+	base  string
+	quote string
+}
+
+// TriangleIndex 以资产为键的邻接索引
+// 只在交易对集合变化时重新枚举三角闭环，扫描时直接使用缓存结果
+type TriangleIndex struct {
+	mu          sync.RWMutex
+	startAssets []string
+	pairs       map[string]pairAssets               // 交易对 -> 资产
+	adjacency   map[string]map[string][]TriangleLeg // 资产 -> 相邻资产 -> 可用的交易步骤
+	triangles   []*Triangle
+	byPath      map[string]*Triangle
+	dirty       bool
+}
+
+// NewTriangleIndex 创建新的三角索引
+func NewTriangleIndex(startAssets []string) *TriangleIndex {
+	return &TriangleIndex{
+		startAssets: startAssets,
+		pairs:       make(map[string]pairAssets),
+		adjacency:   make(map[string]map[string][]TriangleLeg),
+		byPath:      make(map[string]*Triangle),
+	}
+}
+
+// Update 根据交易所信息增量更新索引，返回新增和移除的交易对数量
+func (ti *TriangleIndex) Update(symbols []*exchange.SymbolInfo) (added int, removed int) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	seen := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		if s.Status != exchange.SymbolStatusTrading || s.BaseAsset == "" || s.QuoteAsset == "" {
+			continue
+		}
+		seen[s.Symbol] = true
+
+		if _, ok := ti.pairs[s.Symbol]; ok {
+			continue
+		}
+		ti.pairs[s.Symbol] = pairAssets{base: s.BaseAsset, quote: s.QuoteAsset}
+		ti.addEdge(TriangleLeg{Symbol: s.Symbol, Side: "BUY", From: s.QuoteAsset, To: s.BaseAsset})
+		ti.addEdge(TriangleLeg{Symbol: s.Symbol, Side: "SELL", From: s.BaseAsset, To: s.QuoteAsset})
+		added++
+	}
+
+	for symbol, assets := range ti.pairs {
+		if seen[symbol] {
+			continue
+		}
+		delete(ti.pairs, symbol)
+		ti.removeEdge(assets.quote, assets.base, symbol)
+		ti.removeEdge(assets.base, assets.quote, symbol)
+		removed++
+	}
+
+	if added > 0 || removed > 0 {
+		ti.dirty = true
+	}
+	return added, removed
+}
+
+// addEdge 添加一条有向边
+func (ti *TriangleIndex) addEdge(leg TriangleLeg) {
+	neighbors, ok := ti.adjacency[leg.From]
+	if !ok {
+		neighbors = make(map[string][]TriangleLeg)
+		ti.adjacency[leg.From] = neighbors
+	}
+	neighbors[leg.To] = append(neighbors[leg.To], leg)
+}
+
+// removeEdge 移除一条有向边
+func (ti *TriangleIndex) removeEdge(from, to, symbol string) {
+	neighbors := ti.adjacency[from]
+	legs := neighbors[to]
+	for i, leg := range legs {
+		if leg.Symbol == symbol {
+			legs = append(legs[:i], legs[i+1:]...)
+			break
+		}
+	}
+
+	if len(legs) == 0 {
+		delete(neighbors, to)
+	} else {
+		neighbors[to] = legs
+	}
+	if len(neighbors) == 0 {
+		delete(ti.adjacency, from)
+	}
+}
+
+// Triangles 获取所有有效的三角闭环
+func (ti *TriangleIndex) Triangles() []*Triangle {
+	ti.mu.RLock()
+	if !ti.dirty {
+		triangles := ti.triangles
+		ti.mu.RUnlock()
+		return triangles
+	}
+	ti.mu.RUnlock()
+
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+
+	if ti.dirty {
+		ti.rebuild()
+	}
+	return ti.triangles
+}
+
+// Lookup 根据交易路径查找三角闭环
+func (ti *TriangleIndex) Lookup(path []string) *Triangle {
+	ti.Triangles()
+
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	return ti.byPath[strings.Join(path, ",")]
+}
+
+// Legs 获取索引中所有有向交易步骤
+func (ti *TriangleIndex) Legs() []TriangleLeg {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+
+	legs := make([]TriangleLeg, 0, len(ti.pairs)*2)
+	for _, neighbors := range ti.adjacency {
+		for _, list := range neighbors {
+			legs = append(legs, list...)
+		}
+	}
+	return legs
+}
+
+// SymbolCount 获取索引中的交易对数量
+func (ti *TriangleIndex) SymbolCount() int {
+	ti.mu.RLock()
+	defer ti.mu.RUnlock()
+	return len(ti.pairs)
+}
+
+// rebuild 重新枚举三角闭环（调用方需持有写锁）
+// 对每个起始资产 A，只遍历 A 的邻居 B 和 B 的邻居 C，再通过邻接表 O(1) 判断 C 能否回到 A
+func (ti *TriangleIndex) rebuild() {
+	triangles := make([]*Triangle, 0)
+	byPath := make(map[string]*Triangle)
+
+	for _, start := range ti.startAssets {
+		for mid, firstLegs := range ti.adjacency[start] {
+			for last, secondLegs := range ti.adjacency[mid] {
+				if last == start {
+					continue
+				}
+				closingLegs := ti.adjacency[last][start]
+				if len(closingLegs) == 0 {
+					continue
+				}
+
+				for _, leg1 := range firstLegs {
+					for _, leg2 := range secondLegs {
+						for _, leg3 := range closingLegs {
+							tri := &Triangle{
+								StartAsset: start,
+								Legs:       [3]TriangleLeg{leg1, leg2, leg3},
+							}
+							triangles = append(triangles, tri)
+							byPath[strings.Join(tri.Path(), ",")] = tri
+						}
+					}
+				}
+			}
+		}
+	}
+
+	ti.triangles = triangles
+	ti.byPath = byPath
+	ti.dirty = false
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"math"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,44 +15,55 @@ import (
 // ArbitrageOpportunity 套利机会
 type ArbitrageOpportunity struct {
 	ID              string
-	Path            []string      // 交易路径，例如 ["BTCUSDT", "ETHBTC", "ETHUSDT"]
-	StartAsset      string        // 起始资产，例如 "USDT"
-	InitialAmount   float64       // 初始金额
-	FinalAmount     float64       // 最终金额
-	GrossProfit     float64       // 毛利润
-	NetProfit       float64       // 净利润（扣除手续费）
-	ProfitPercent   float64       // 利润百分比
-	ConfidenceScore float64       // 信心度评分 (0-100)
-	ExecutionTime   int64         // 预计执行时间（毫秒）
-	Timestamp       time.Time     // 发现时间
-	IsValid         bool          // 是否有效
-	ErrorMessage    string        // 错误信息
+	Path            []string  // 交易路径，例如 ["BTCUSDT", "ETHBTC", "ETHUSDT"]
+	StartAsset      string    // 起始资产，例如 "USDT"
+	InitialAmount   float64   // 初始金额
+	FinalAmount     float64   // 最终金额
+	GrossProfit     float64   // 毛利润
+	NetProfit       float64   // 净利润（扣除手续费）
+	ProfitPercent   float64   // 利润百分比
+	ConfidenceScore float64   // 信心度评分 (0-100)
+	ExecutionTime   int64     // 预计执行时间（毫秒）
+	Timestamp       time.Time // 发现时间
+	IsValid         bool      // 是否有效
+	ErrorMessage    string    // 错误信息
 }
 
 // TriangularArbitrageEngine 三角套利引擎
 type TriangularArbitrageEngine struct {
-	client              *exchange.BinanceClient
+	client              exchange.Exchange
 	tickers             map[string]*exchange.Ticker
 	opportunities       []*ArbitrageOpportunity
 	minProfitPercent    float64
-	takerFeePercent     float64
+	fees                *exchange.FeeModel
 	slippagePercent     float64
 	maxConcurrentTrades int
+	startAssets         []string       // 套利起始资产
+	index               *TriangleIndex // 三角闭环索引
+	lastScanDuration    time.Duration  // 最近一次扫描耗时
+	lastScanTime        time.Time      // 最近一次扫描时间
 	mu                  sync.RWMutex
 	stopChan            chan bool
 	isRunning           bool
 }
 
+// symbolRefreshInterval 交易对信息刷新间隔
+const symbolRefreshInterval = 30 * time.Minute
+
 // NewTriangularArbitrageEngine 创建新的三角套利引擎
-func NewTriangularArbitrageEngine(client *exchange.BinanceClient, minProfitPercent float64) *TriangularArbitrageEngine {
+func NewTriangularArbitrageEngine(client exchange.Exchange, minProfitPercent float64) *TriangularArbitrageEngine {
+	startAssets := []string{"USDT"}
+
 	return &TriangularArbitrageEngine{
 		client:              client,
 		tickers:             make(map[string]*exchange.Ticker),
 		opportunities:       make([]*ArbitrageOpportunity, 0),
 		minProfitPercent:    minProfitPercent,
-		takerFeePercent:     0.1,  // Binance taker费用 0.1%
+		fees:                exchange.NewFeeModel(client),
 		slippagePercent:     0.05, // 滑点 0.05%
 		maxConcurrentTrades: 5,
+		startAssets:         startAssets,
+		index:               NewTriangleIndex(startAssets),
 		stopChan:            make(chan bool),
 		isRunning:           false,
 	}
@@ -69,15 +81,32 @@ func (tae *TriangularArbitrageEngine) Start(updateInterval time.Duration) error
 
 	log.Println("三角套利引擎启动...")
 
+	// 加载交易对信息并构建三角索引
+	if err := tae.refreshSymbols(); err != nil {
+		tae.mu.Lock()
+		tae.isRunning = false
+		tae.mu.Unlock()
+		return err
+	}
+	refreshFees(tae.fees)
+
 	// 定期更新行情
 	go func() {
 		ticker := time.NewTicker(updateInterval)
 		defer ticker.Stop()
 
+		symbolTicker := time.NewTicker(symbolRefreshInterval)
+		defer symbolTicker.Stop()
+
 		for {
 			select {
 			case <-tae.stopChan:
 				return
+			case <-symbolTicker.C:
+				if err := tae.refreshSymbols(); err != nil {
+					log.Printf("刷新交易对信息失败: %v", err)
+				}
+				refreshFees(tae.fees)
 			case <-ticker.C:
 				if err := tae.updateTickers(); err != nil {
 					log.Printf("更新行情失败: %v", err)
@@ -104,56 +133,79 @@ func (tae *TriangularArbitrageEngine) Stop() {
 	}
 }
 
+// SetFeeModel 设置手续费模型，需在 Start 之前调用
+func (tae *TriangularArbitrageEngine) SetFeeModel(fees *exchange.FeeModel) {
+	tae.fees = fees
+}
+
+// refreshSymbols 从交易所信息更新三角索引
+func (tae *TriangularArbitrageEngine) refreshSymbols() error {
+	symbols, err := tae.client.GetSymbols()
+	if err != nil {
+		return fmt.Errorf("获取交易所信息失败: %v", err)
+	}
+
+	added, removed := tae.index.Update(symbols)
+	if added > 0 || removed > 0 {
+		log.Printf("三角索引已更新: 新增 %d, 移除 %d, 共 %d 个交易对, %d 个三角闭环",
+			added, removed, tae.index.SymbolCount(), len(tae.index.Triangles()))
+	}
+
+	return nil
+}
+
 // updateTickers 更新行情数据
+// 每次构建新的行情表再整体替换，已发布的行情表不再修改，扫描时可以无锁读取
 func (tae *TriangularArbitrageEngine) updateTickers() error {
 	tickers, err := tae.client.GetAllTickers()
 	if err != nil {
 		return fmt.Errorf("获取行情失败: %v", err)
 	}
 
-	tae.mu.Lock()
-	defer tae.mu.Unlock()
-
+	snapshot := make(map[string]*exchange.Ticker, len(tickers))
 	for _, ticker := range tickers {
-		tae.tickers[ticker.Symbol] = &ticker
+		snapshot[ticker.Symbol] = ticker
 	}
 
+	tae.mu.Lock()
+	tae.tickers = snapshot
+	tae.mu.Unlock()
+
 	return nil
 }
 
+// tickerSnapshot 获取当前行情快照
+func (tae *TriangularArbitrageEngine) tickerSnapshot() map[string]*exchange.Ticker {
+	tae.mu.RLock()
+	defer tae.mu.RUnlock()
+	return tae.tickers
+}
+
 // scanOpportunities 扫描套利机会
+// 只遍历索引中的有效三角闭环，计算过程不持有引擎锁，完成后整体替换机会列表
 func (tae *TriangularArbitrageEngine) scanOpportunities() {
-	tae.mu.Lock()
-	defer tae.mu.Unlock()
-
-	// 清空旧的机会
-	tae.opportunities = make([]*ArbitrageOpportunity, 0)
-
-	// 获取所有交易对
-	symbols := make([]string, 0, len(tae.tickers))
-	for symbol := range tae.tickers {
-		symbols = append(symbols, symbol)
-	}
-
-	// 扫描所有可能的三角套利路径
-	for i := 0; i < len(symbols); i++ {
-		for j := 0; j < len(symbols); j++ {
-			for k := 0; k < len(symbols); k++ {
-				if i != j && j != k && i != k {
-					path := []string{symbols[i], symbols[j], symbols[k]}
-					opp := tae.calculateArbitrage(path, 1.0)
-					if opp != nil && opp.IsValid && opp.ProfitPercent >= tae.minProfitPercent {
-						tae.opportunities = append(tae.opportunities, opp)
-					}
-				}
-			}
+	start := time.Now()
+	tickers := tae.tickerSnapshot()
+	triangles := tae.index.Triangles()
+
+	opportunities := make([]*ArbitrageOpportunity, 0)
+	for _, tri := range triangles {
+		opp := tae.calculateTriangle(tri, tickers, 1.0)
+		if opp.IsValid && opp.ProfitPercent >= tae.minProfitPercent {
+			opportunities = append(opportunities, opp)
 		}
 	}
 
 	// 按利润排序
-	sort.Slice(tae.opportunities, func(i, j int) bool {
-		return tae.opportunities[i].NetProfit > tae.opportunities[j].NetProfit
+	sort.Slice(opportunities, func(i, j int) bool {
+		return opportunities[i].NetProfit > opportunities[j].NetProfit
 	})
+
+	tae.mu.Lock()
+	tae.opportunities = opportunities
+	tae.lastScanDuration = time.Since(start)
+	tae.lastScanTime = start
+	tae.mu.Unlock()
 }
 
 // calculateArbitrage 计算套利机会
@@ -162,63 +214,71 @@ func (tae *TriangularArbitrageEngine) calculateArbitrage(path []string, initialA
 		return nil
 	}
 
+	tri := tae.index.Lookup(path)
+	if tri == nil {
+		return &ArbitrageOpportunity{
+			ID:            fmt.Sprintf("ARB_%d", time.Now().UnixNano()),
+			Path:          path,
+			InitialAmount: initialAmount,
+			Timestamp:     time.Now(),
+			IsValid:       false,
+			ErrorMessage:  "交易路径无法形成闭环",
+		}
+	}
+
+	return tae.calculateTriangle(tri, tae.tickerSnapshot(), initialAmount)
+}
+
+// calculateTriangle 按行情快照计算三角闭环的收益
+func (tae *TriangularArbitrageEngine) calculateTriangle(tri *Triangle, tickers map[string]*exchange.Ticker, initialAmount float64) *ArbitrageOpportunity {
 	opp := &ArbitrageOpportunity{
 		ID:            fmt.Sprintf("ARB_%d", time.Now().UnixNano()),
-		Path:          path,
+		Path:          tri.Path(),
+		StartAsset:    tri.StartAsset,
 		InitialAmount: initialAmount,
 		Timestamp:     time.Now(),
 	}
 
-	// 获取交易对信息
-	ticker1, ok1 := tae.tickers[path[0]]
-	ticker2, ok2 := tae.tickers[path[1]]
-	ticker3, ok3 := tae.tickers[path[2]]
-
-	if !ok1 || !ok2 || !ok3 {
-		opp.IsValid = false
-		opp.ErrorMessage = "缺少行情数据"
-		return opp
-	}
-
-	if ticker1 == nil || ticker2 == nil || ticker3 == nil {
-		opp.IsValid = false
-		opp.ErrorMessage = "行情数据为空"
-		return opp
-	}
+	amount := initialAmount
+	for _, leg := range tri.Legs {
+		ticker, ok := tickers[leg.Symbol]
+		if !ok || ticker == nil {
+			opp.IsValid = false
+			opp.ErrorMessage = "缺少行情数据"
+			return opp
+		}
 
-	// 解析价格
-	price1, err1 := parsePrice(ticker1.AskPrice)
-	price2, err2 := parsePrice(ticker2.AskPrice)
-	price3, err3 := parsePrice(ticker3.BidPrice)
+		// BUY 按卖一价成交，SELL 按买一价成交
+		price := ticker.BidPrice
+		if leg.Side == "BUY" {
+			price = ticker.AskPrice
+		}
+		if price <= 0 {
+			opp.IsValid = false
+			opp.ErrorMessage = "价格无效"
+			return opp
+		}
 
-	if err1 != nil || err2 != nil || err3 != nil {
-		opp.IsValid = false
-		opp.ErrorMessage = "价格解析失败"
-		return opp
+		feeFactor := 1 - tae.fees.Taker(leg.Symbol)
+		if leg.Side == "BUY" {
+			amount = amount / price * feeFactor
+		} else {
+			amount = amount * price * feeFactor
+		}
 	}
 
-	// 计算交易路径
-	// 路径1: 用初始资产买入第一个交易对的基础资产
-	amount1 := initialAmount / price1 * (1 - tae.takerFeePercent/100)
-
-	// 路径2: 用第一个交易对的基础资产买入第二个交易对的基础资产
-	amount2 := amount1 / price2 * (1 - tae.takerFeePercent/100)
-
-	// 路径3: 用第二个交易对的基础资产卖出获得初始资产
-	finalAmount := amount2 * price3 * (1 - tae.takerFeePercent/100)
-
 	// 计算利润
-	opp.FinalAmount = finalAmount
-	opp.GrossProfit = finalAmount - initialAmount
+	opp.FinalAmount = amount
+	opp.GrossProfit = amount - initialAmount
 	opp.NetProfit = opp.GrossProfit - (initialAmount * tae.slippagePercent / 100)
 	opp.ProfitPercent = (opp.NetProfit / initialAmount) * 100
 
-	// 计算信心度评分
-	opp.ConfidenceScore = tae.calculateConfidenceScore(opp)
-
 	// 计算预计执行时间
 	opp.ExecutionTime = 3000 // 3秒（保守估计）
 
+	// 计算信心度评分
+	opp.ConfidenceScore = tae.calculateConfidenceScore(opp)
+
 	// 验证套利机会
 	opp.IsValid = opp.NetProfit > 0 && opp.ProfitPercent >= tae.minProfitPercent
 
@@ -293,12 +353,12 @@ func (tae *TriangularArbitrageEngine) ExecuteArbitrage(opp *ArbitrageOpportunity
 		return result, fmt.Errorf("缺少行情数据")
 	}
 
-	price1, _ := parsePrice(ticker1.AskPrice)
+	price1 := ticker1.AskPrice
 	quantity1 := initialAmount / price1
 
 	log.Printf("第一步: 买入 %s, 数量: %.8f, 价格: %.2f", opp.Path[0], quantity1, price1)
 
-	order1, err := tae.client.PlaceOrder(opp.Path[0], "BUY", quantity1, price1)
+	order1, err := tae.client.PlaceOrder(opp.Path[0], "BUY", "LIMIT", quantity1, price1)
 	if err != nil {
 		result.Status = "FAILED"
 		result.ErrorMessage = fmt.Sprintf("第一步下单失败: %v", err)
@@ -315,17 +375,15 @@ func (tae *TriangularArbitrageEngine) ExecuteArbitrage(opp *ArbitrageOpportunity
 		return result, fmt.Errorf("缺少行情数据")
 	}
 
-	price2, _ := parsePrice(ticker2.AskPrice)
+	price2 := ticker2.AskPrice
 	quantity2 := quantity1 / price2
 
 	log.Printf("第二步: 买入 %s, 数量: %.8f, 价格: %.2f", opp.Path[1], quantity2, price2)
 
-	order2, err := tae.client.PlaceOrder(opp.Path[1], "BUY", quantity2, price2)
+	order2, err := tae.client.PlaceOrder(opp.Path[1], "BUY", "LIMIT", quantity2, price2)
 	if err != nil {
-		// 撤销第一个订单
-		tae.client.CancelOrder(opp.Path[0], order1.OrderID)
 		result.Status = "FAILED"
-		result.ErrorMessage = fmt.Sprintf("第二步下单失败: %v", err)
+		result.ErrorMessage = tae.abortOrders(fmt.Sprintf("第二步下单失败: %v", err), result.Orders)
 		return result, err
 	}
 
@@ -339,24 +397,21 @@ func (tae *TriangularArbitrageEngine) ExecuteArbitrage(opp *ArbitrageOpportunity
 		return result, fmt.Errorf("缺少行情数据")
 	}
 
-	price3, _ := parsePrice(ticker3.BidPrice)
+	price3 := ticker3.BidPrice
 
 	log.Printf("第三步: 卖出 %s, 数量: %.8f, 价格: %.2f", opp.Path[2], quantity2, price3)
 
-	order3, err := tae.client.PlaceOrder(opp.Path[2], "SELL", quantity2, price3)
+	order3, err := tae.client.PlaceOrder(opp.Path[2], "SELL", "LIMIT", quantity2, price3)
 	if err != nil {
-		// 撤销前两个订单
-		tae.client.CancelOrder(opp.Path[0], order1.OrderID)
-		tae.client.CancelOrder(opp.Path[1], order2.OrderID)
 		result.Status = "FAILED"
-		result.ErrorMessage = fmt.Sprintf("第三步下单失败: %v", err)
+		result.ErrorMessage = tae.abortOrders(fmt.Sprintf("第三步下单失败: %v", err), result.Orders)
 		return result, err
 	}
 
 	result.Orders = append(result.Orders, order3)
 
 	// 计算实际利润
-	finalAmount, _ := parsePrice(order3.CummulativeQuoteQty)
+	finalAmount := order3.ExecutedQuoteQty
 	result.FinalAmount = finalAmount
 	result.Profit = finalAmount - initialAmount
 	result.ProfitPercent = (result.Profit / initialAmount) * 100
@@ -368,20 +423,52 @@ func (tae *TriangularArbitrageEngine) ExecuteArbitrage(opp *ArbitrageOpportunity
 	return result, nil
 }
 
+// abortOrders 某一步失败后撤销之前仍在挂单的订单，已成交的部分无法撤销，保留持仓并在错误信息中列出
+// 中间资产的回滚由交易执行器按回滚策略处理
+func (tae *TriangularArbitrageEngine) abortOrders(message string, orders []*exchange.Order) string {
+	held := make([]string, 0)
+	for _, order := range orders {
+		latest, err := tae.client.GetOrder(order.Symbol, order.OrderID)
+		if err != nil {
+			log.Printf("查询订单 %s 失败: %v", order.OrderID, err)
+			latest = order
+		}
+
+		switch latest.Status {
+		case exchange.OrderStatusNew, exchange.OrderStatusPartiallyFilled:
+			if canceled, err := tae.client.CancelOrder(order.Symbol, order.OrderID); err != nil {
+				log.Printf("撤销订单 %s 失败: %v", order.OrderID, err)
+			} else {
+				latest = canceled
+			}
+		}
+
+		if latest.ExecutedQty > 0 {
+			held = append(held, fmt.Sprintf("%s %s %.8f", latest.Symbol, latest.Side, latest.ExecutedQty))
+		}
+	}
+
+	if len(held) == 0 {
+		return message
+	}
+	log.Printf("✗ %s，已成交的订单未回滚: %s", message, strings.Join(held, ", "))
+	return fmt.Sprintf("%s，已成交的订单未回滚: %s", message, strings.Join(held, ", "))
+}
+
 // ArbitrageExecutionResult 套利执行结果
 type ArbitrageExecutionResult struct {
-	OpportunityID  string
-	Path           []string
-	Orders         []*exchange.Order
-	InitialAmount  float64
-	FinalAmount    float64
-	Profit         float64
-	ProfitPercent  float64
-	Status         string
-	ErrorMessage   string
-	StartTime      time.Time
-	EndTime        time.Time
-	ExecutionTime  time.Duration
+	OpportunityID string
+	Path          []string
+	Orders        []*exchange.Order
+	InitialAmount float64
+	FinalAmount   float64
+	Profit        float64
+	ProfitPercent float64
+	Status        string
+	ErrorMessage  string
+	StartTime     time.Time
+	EndTime       time.Time
+	ExecutionTime time.Duration
 }
 
 // GetExecutionTime 获取执行时间
@@ -417,17 +504,21 @@ func (tv *TriangleValidator) IsValidTriangle(path []string) bool {
 
 // QuadrangularArbitrageEngine 四角套利引擎
 type QuadrangularArbitrageEngine struct {
-	client           *exchange.BinanceClient
+	client           exchange.Exchange
 	tickers          map[string]*exchange.Ticker
 	opportunities    []*QuadrangularOpportunity
 	minProfitPercent float64
+	fees             *exchange.FeeModel
+	startAssets      []string
+	index            *TriangleIndex
 	mu               sync.RWMutex
 }
 
 // QuadrangularOpportunity 四角套利机会
 type QuadrangularOpportunity struct {
 	ID            string
-	Path          []string  // 4个交易对
+	Path          []string // 4个交易对
+	StartAsset    string
 	InitialAmount float64
 	FinalAmount   float64
 	NetProfit     float64
@@ -437,28 +528,37 @@ type QuadrangularOpportunity struct {
 }
 
 // NewQuadrangularArbitrageEngine 创建新的四角套利引擎
-func NewQuadrangularArbitrageEngine(client *exchange.BinanceClient, minProfitPercent float64) *QuadrangularArbitrageEngine {
+func NewQuadrangularArbitrageEngine(client exchange.Exchange, minProfitPercent float64) *QuadrangularArbitrageEngine {
+	startAssets := []string{"USDT"}
+
 	return &QuadrangularArbitrageEngine{
 		client:           client,
 		tickers:          make(map[string]*exchange.Ticker),
 		opportunities:    make([]*QuadrangularOpportunity, 0),
 		minProfitPercent: minProfitPercent,
+		fees:             exchange.NewFeeModel(client),
+		startAssets:      startAssets,
+		index:            NewTriangleIndex(startAssets),
 	}
 }
 
 // PentagonalArbitrageEngine 五角套利引擎
 type PentagonalArbitrageEngine struct {
-	client           *exchange.BinanceClient
+	client           exchange.Exchange
 	tickers          map[string]*exchange.Ticker
 	opportunities    []*PentagonalOpportunity
 	minProfitPercent float64
+	fees             *exchange.FeeModel
+	startAssets      []string
+	index            *TriangleIndex
 	mu               sync.RWMutex
 }
 
 // PentagonalOpportunity 五角套利机会
 type PentagonalOpportunity struct {
 	ID            string
-	Path          []string  // 5个交易对
+	Path          []string // 5个交易对
+	StartAsset    string
 	InitialAmount float64
 	FinalAmount   float64
 	NetProfit     float64
@@ -468,29 +568,27 @@ type PentagonalOpportunity struct {
 }
 
 // NewPentagonalArbitrageEngine 创建新的五角套利引擎
-func NewPentagonalArbitrageEngine(client *exchange.BinanceClient, minProfitPercent float64) *PentagonalArbitrageEngine {
+func NewPentagonalArbitrageEngine(client exchange.Exchange, minProfitPercent float64) *PentagonalArbitrageEngine {
+	startAssets := []string{"USDT"}
+
 	return &PentagonalArbitrageEngine{
 		client:           client,
 		tickers:          make(map[string]*exchange.Ticker),
 		opportunities:    make([]*PentagonalOpportunity, 0),
 		minProfitPercent: minProfitPercent,
+		fees:             exchange.NewFeeModel(client),
+		startAssets:      startAssets,
+		index:            NewTriangleIndex(startAssets),
 	}
 }
 
-// parsePrice 解析价格字符串
-func parsePrice(priceStr string) (float64, error) {
-	var price float64
-	_, err := fmt.Sscanf(priceStr, "%f", &price)
-	return price, err
-}
-
 // RiskAssessment 风险评估
 type RiskAssessment struct {
-	ExecutionRisk    float64 // 执行风险 (0-100)
-	LiquidityRisk    float64 // 流动性风险 (0-100)
-	SlippageRisk     float64 // 滑点风险 (0-100)
-	OverallRisk      float64 // 总体风险 (0-100)
-	Recommendation   string  // 建议
+	ExecutionRisk  float64 // 执行风险 (0-100)
+	LiquidityRisk  float64 // 流动性风险 (0-100)
+	SlippageRisk   float64 // 滑点风险 (0-100)
+	OverallRisk    float64 // 总体风险 (0-100)
+	Recommendation string  // 建议
 }
 
 // AssessRisk 评估风险
@@ -535,6 +633,8 @@ type Statistics struct {
 	MaxProfitPercent     float64
 	MinProfitPercent     float64
 	LastUpdateTime       time.Time
+	ScanDuration         time.Duration
+	TriangleCount        int
 }
 
 // GetStatistics 获取统计信息
@@ -544,7 +644,9 @@ func (tae *TriangularArbitrageEngine) GetStatistics() *Statistics {
 
 	stats := &Statistics{
 		TotalOpportunities: len(tae.opportunities),
-		LastUpdateTime:     time.Now(),
+		LastUpdateTime:     tae.lastScanTime,
+		ScanDuration:       tae.lastScanDuration,
+		TriangleCount:      len(tae.index.Triangles()),
 	}
 
 	if len(tae.opportunities) == 0 {
@@ -12,13 +12,13 @@ import (
 
 // WebSocketManager WebSocket连接管理器
 type WebSocketManager struct {
-	clients    map[int64]*WebSocketClient // userID -> client
-	broadcast  chan interface{}            // 广播消息通道
-	register   chan *WebSocketClient       // 注册通道
-	unregister chan *WebSocketClient       // 注销通道
-	mu         sync.RWMutex
+	clients     map[int64]*WebSocketClient // userID -> client
+	broadcast   chan interface{}           // 广播消息通道
+	register    chan *WebSocketClient      // 注册通道
+	unregister  chan *WebSocketClient      // 注销通道
+	mu          sync.RWMutex
 	authService *AuthService
-	db         *Database
+	db          *Database
 }
 
 // WebSocketClient WebSocket客户端
@@ -376,6 +376,29 @@ func (m *WebSocketManager) BroadcastBotStatus(userID int64, botID int64, isRunni
 	m.mu.RUnlock()
 }
 
+// BroadcastOpportunity 向所有客户端广播套利机会
+func (m *WebSocketManager) BroadcastOpportunity(opp *ArbitrageOpportunity) {
+	message := WebSocketMessage{
+		Type: "opportunity",
+		Payload: OpportunityPayload{
+			ID:               opp.ID,
+			Type:             opp.Type,
+			Path:             opp.Path,
+			InitialAmount:    opp.InitialAmount,
+			NetProfit:        opp.NetProfit,
+			ProfitPercentage: opp.ProfitPercentage,
+			Confidence:       opp.Confidence,
+			Timestamp:        opp.Timestamp,
+		},
+	}
+
+	select {
+	case m.broadcast <- message:
+	default:
+		log.Printf("警告：广播通道已满，丢弃套利机会 %s", opp.ID)
+	}
+}
+
 // BroadcastLog 广播日志
 func (m *WebSocketManager) BroadcastLog(userID int64, botID *int64, level string, message string) {
 	wsMsg := WebSocketMessage{
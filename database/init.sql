@@ -84,9 +84,10 @@ CREATE TABLE IF NOT EXISTS bots (
     id BIGSERIAL PRIMARY KEY,
     user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     exchange_id BIGINT NOT NULL REFERENCES exchanges(id) ON DELETE CASCADE,
+    hedge_exchange_id BIGINT REFERENCES exchanges(id) ON DELETE SET NULL, -- cross_exchange 策略比较的另一个交易所
     name VARCHAR(255) NOT NULL,
     description TEXT,
-    strategy_type VARCHAR(50) NOT NULL, -- triangular, quadrangular, pentagonal
+    strategy_type VARCHAR(50) NOT NULL, -- triangular, quadrangular, pentagonal, cross_exchange
     is_active BOOLEAN DEFAULT false,
     is_simulation BOOLEAN DEFAULT true,
     min_profit_percent FLOAT DEFAULT 0.1,
@@ -113,6 +114,13 @@ CREATE TABLE IF NOT EXISTS strategies (
     taker_fee_percent FLOAT DEFAULT 0.1,
     maker_fee_percent FLOAT DEFAULT 0.1,
     slippage_percent FLOAT DEFAULT 0.05,
+    min_profit_percent FLOAT DEFAULT 0, -- 执行前按最新行情重新定价后要求的最低利润百分比
+    min_trade_amount DECIMAL(20, 8) DEFAULT 0, -- 单笔交易的最小起始金额
+    max_trade_amount DECIMAL(20, 8) DEFAULT 0, -- 单笔交易的最大起始金额，为0时不限制
+    order_type VARCHAR(20) DEFAULT 'LIMIT', -- LIMIT, LIMIT_MAKER, MARKET
+    time_in_force VARCHAR(10) DEFAULT 'IOC', -- GTC, IOC, FOK
+    unwind_policy VARCHAR(20) DEFAULT 'market', -- 某一步失败后的回滚方式: market, retry, hold
+    unwind_cap_percent FLOAT DEFAULT 0.5, -- retry 重试剩余步骤时成交价允许变差的百分比
     is_active BOOLEAN DEFAULT true,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -128,12 +136,17 @@ CREATE TABLE IF NOT EXISTS trades (
     status VARCHAR(50) NOT NULL, -- pending, executing, completed, failed, cancelled
     strategy_type VARCHAR(50) NOT NULL,
     trading_path TEXT NOT NULL, -- JSON数组
+    start_asset VARCHAR(20),
+    trade_steps TEXT, -- JSON数组，计划的交易步骤，重启后据此恢复执行
+    unwind_policy VARCHAR(20), -- market, retry, hold
+    unwind_cap_percent FLOAT,
     initial_amount DECIMAL(20, 8) NOT NULL,
     final_amount DECIMAL(20, 8),
     gross_profit DECIMAL(20, 8),
     net_profit DECIMAL(20, 8),
     profit_percent FLOAT,
     total_fees DECIMAL(20, 8) DEFAULT 0,
+    realized_loss DECIMAL(20, 8) DEFAULT 0, -- 回滚中间资产造成的实际亏损
     execution_time_ms INT,
     orders_count INT DEFAULT 0,
     is_simulation BOOLEAN DEFAULT true,
@@ -147,7 +160,10 @@ CREATE TABLE IF NOT EXISTS trades (
 CREATE TABLE IF NOT EXISTS orders (
     id BIGSERIAL PRIMARY KEY,
     trade_id BIGINT NOT NULL REFERENCES trades(id) ON DELETE CASCADE,
-    exchange_order_id VARCHAR(100),
+    step INT NOT NULL, -- 第几步，超过计划步骤数的是回滚订单
+    attempt INT NOT NULL DEFAULT 1, -- 该步骤的第几次下单，每次下单各保存一行
+    exchange VARCHAR(100), -- 跨交易所套利下单的交易所，为空时是机器人的交易所
+    exchange_order_id VARCHAR(100), -- 客户端订单ID，由交易ID、步骤和下单次数生成，按此向交易所查询订单
     symbol VARCHAR(50) NOT NULL,
     side VARCHAR(10) NOT NULL, -- BUY, SELL
     order_type VARCHAR(20) NOT NULL, -- LIMIT, MARKET
@@ -155,12 +171,14 @@ CREATE TABLE IF NOT EXISTS orders (
     price DECIMAL(20, 8),
     executed_quantity DECIMAL(20, 8) DEFAULT 0,
     executed_price DECIMAL(20, 8),
+    executed_quote_quantity DECIMAL(20, 8) DEFAULT 0,
     commission DECIMAL(20, 8) DEFAULT 0,
     commission_asset VARCHAR(20),
-    status VARCHAR(50) NOT NULL, -- PENDING, PARTIALLY_FILLED, FILLED, CANCELLED, REJECTED
+    status VARCHAR(50) NOT NULL, -- NEW, PARTIALLY_FILLED, FILLED, CANCELED, REJECTED, EXPIRED
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP
+    deleted_at TIMESTAMP,
+    UNIQUE(trade_id, step, attempt)
 );
 
 -- 7. 机器人统计表
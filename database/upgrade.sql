@@ -0,0 +1,47 @@
+-- ============================================================================
+-- iNarbit 数据库升级脚本
+-- 功能：为按旧版 init.sql 创建的数据库补充新增的列和约束，可重复执行
+-- 使用：psql -U postgres -d inarbit_db -f upgrade.sql（在 init.sql 之后执行）
+-- ============================================================================
+
+-- 机器人：跨交易所套利比较的另一个交易所
+ALTER TABLE bots ADD COLUMN IF NOT EXISTS hedge_exchange_id BIGINT REFERENCES exchanges(id) ON DELETE SET NULL;
+
+-- 策略：交易规模、最低利润、下单类型和回滚方式
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS min_profit_percent FLOAT DEFAULT 0;
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS min_trade_amount DECIMAL(20, 8) DEFAULT 0;
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS max_trade_amount DECIMAL(20, 8) DEFAULT 0;
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS order_type VARCHAR(20) DEFAULT 'LIMIT';
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS time_in_force VARCHAR(10) DEFAULT 'IOC';
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS unwind_policy VARCHAR(20) DEFAULT 'market';
+ALTER TABLE strategies ADD COLUMN IF NOT EXISTS unwind_cap_percent FLOAT DEFAULT 0.5;
+
+-- 交易：重启后恢复执行所需的计划步骤和回滚策略
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS start_asset VARCHAR(20);
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS trade_steps TEXT;
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS unwind_policy VARCHAR(20);
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS unwind_cap_percent FLOAT;
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS realized_loss DECIMAL(20, 8) DEFAULT 0;
+
+-- 订单：每个步骤的每次下单各保存一行
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS step INT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS attempt INT DEFAULT 1;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS exchange VARCHAR(100);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS executed_quote_quantity DECIMAL(20, 8) DEFAULT 0;
+
+-- 旧版每笔交易的订单按下单时间依次编号
+UPDATE orders SET step = numbered.step
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY trade_id ORDER BY created_at, id) AS step FROM orders WHERE step IS NULL) numbered
+WHERE orders.id = numbered.id;
+UPDATE orders SET attempt = 1 WHERE attempt IS NULL;
+ALTER TABLE orders ALTER COLUMN step SET NOT NULL;
+ALTER TABLE orders ALTER COLUMN attempt SET NOT NULL;
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_trade_id_step_key;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT FROM pg_constraint WHERE conname = 'orders_trade_id_step_attempt_key') THEN
+        ALTER TABLE orders ADD CONSTRAINT orders_trade_id_step_attempt_key UNIQUE (trade_id, step, attempt);
+    END IF;
+END
+$$;
@@ -101,6 +101,7 @@ JWT_SECRET=your-super-secret-jwt-key-change-this-in-production-12345678
 BINANCE_API_KEY=your_binance_api_key_here
 BINANCE_API_SECRET=your_binance_api_secret_here
 BINANCE_TESTNET=true
+BINANCE_RECV_WINDOW=5000
 
 # 日志配置
 LOG_LEVEL=info
@@ -109,6 +110,9 @@ LOG_FILE=/root/inarbit/logs/app.log
 # WebSocket配置
 WS_READ_BUFFER_SIZE=1024
 WS_WRITE_BUFFER_SIZE=1024
+
+# 套利配置
+MAX_CYCLE_LENGTH=5
 EOF
 
 echo "9. 启动PostgreSQL服务..."
@@ -10,6 +10,7 @@ echo "=========================================="
 
 PROJECT_PATH="/root/inarbit"
 DB_INIT_SQL="$PROJECT_PATH/database/init.sql"
+DB_UPGRADE_SQL="$PROJECT_PATH/database/upgrade.sql"
 
 # 检查SQL文件是否存在
 if [ ! -f "$DB_INIT_SQL" ]; then
@@ -29,6 +30,11 @@ sleep 2
 echo "3. 初始化数据库..."
 sudo -u postgres psql -d inarbit_db -f "$DB_INIT_SQL"
 
+# 已有的数据库不会被 init.sql 修改，补充新增的列和约束
+if [ -f "$DB_UPGRADE_SQL" ]; then
+    sudo -u postgres psql -d inarbit_db -f "$DB_UPGRADE_SQL"
+fi
+
 echo "4. 验证数据库..."
 sudo -u postgres psql -d inarbit_db -c "\dt"
 